@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// SnippetOverride corrects the category or language of a snippet by hand. It matches the snippet with the Hash if it
+// has one, or else the snippet on the Page that starts on the StartLine, or every snippet on the Page if it has no
+// StartLine.
+type SnippetOverride struct {
+	Page      string `json:"page,omitempty"`
+	StartLine int    `json:"start_line,omitempty"`
+	Hash      string `json:"hash,omitempty"`
+	Category  string `json:"category,omitempty"`
+	Language  string `json:"language,omitempty"`
+}
+
+// AggregateOptions describe which run to re-aggregate, and where to write the reports. If the ProjectName or DocsURL
+// are empty, we use the ones the run recorded. The Migrations move the snippets to a newer taxonomy before we apply
+// the overrides, and the Contents are the snippets' contents by hash, for migrations that split by the contents.
+type AggregateOptions struct {
+	RunDir      string
+	OutputDir   string
+	Overrides   []SnippetOverride
+	ProjectName string
+	DocsURL     string
+	Migrations  []TaxonomyMigration
+	Contents    map[string]string
+}
+
+// AggregateSummary describes what an aggregate run did, including the overrides that didn't match any snippet, which
+// are usually for a snippet that moved or was removed. The MigratedCount is the number of snippets a migration moved
+// to another category, and the UnresolvedCount is the number it couldn't split without their contents. If any snippet
+// is unresolved, we don't write the reports, so they stay in the taxonomy version they were in.
+type AggregateSummary struct {
+	SnippetCount       int
+	OverriddenCount    int
+	UnmatchedOverrides []SnippetOverride
+	MigratedCount      int
+	UnresolvedCount    int
+}
+
+// RunAggregateCommand rebuilds the counts, rollup and language mismatch reports from a run's snippet report, without
+// asking the LLM to categorize anything
+func RunAggregateCommand(args []string) {
+	flags := flag.NewFlagSet("aggregate", flag.ExitOnError)
+	overridesFile := flags.String("overrides", "", "a JSON file of category and language corrections to apply to the snippets")
+	output := flags.String("output", "", "the directory to write the reports to (default the run directory)")
+	projectName := flags.String("project", "", "the name of the docs project (default the project the run recorded)")
+	docsURL := flags.String("docs-url", "", "the URL of the project's published docs (default the URL in the run's rollup report)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: go run . aggregate [-overrides file] [-output dir] RUN_DIR")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	options := AggregateOptions{
+		RunDir:      ResolveRunDirectory(flags.Arg(0)),
+		OutputDir:   *output,
+		ProjectName: *projectName,
+		DocsURL:     *docsURL,
+	}
+	if *overridesFile != "" {
+		overrides, err := ReadOverrides(*overridesFile)
+		if err != nil {
+			log.Fatalf("failed to read the overrides: %v", err)
+		}
+		options.Overrides = overrides
+	}
+	summary, err := AggregateRun(options)
+	if err != nil {
+		log.Fatalf("failed to aggregate the run: %v", err)
+	}
+	fmt.Printf("Aggregated %d snippets, %d of them with a manual override\n", summary.SnippetCount, summary.OverriddenCount)
+	for _, override := range summary.UnmatchedOverrides {
+		fmt.Printf("No snippet matches the override for page %q, line %d, hash %q\n", override.Page, override.StartLine, override.Hash)
+	}
+}
+
+// ReadOverrides reads a JSON array of overrides, and checks that each one can match a snippet and changes something
+func ReadOverrides(filePath string) ([]SnippetOverride, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var overrides []SnippetOverride
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("%s: %v", filePath, err)
+	}
+	for i, override := range overrides {
+		if override.Hash == "" && override.Page == "" {
+			return nil, fmt.Errorf("%s: override %d needs a hash or a page to match", filePath, i+1)
+		}
+		if override.Category == "" && override.Language == "" {
+			return nil, fmt.Errorf("%s: override %d doesn't set a category or a language", filePath, i+1)
+		}
+		if override.Category != "" && !GetTaxonomy().HasCategory(override.Category) {
+			return nil, fmt.Errorf("%s: override %d has the unknown category %q", filePath, i+1, override.Category)
+		}
+	}
+	return overrides, nil
+}
+
+func (o SnippetOverride) Matches(snippet SnippetInfo) bool {
+	if o.Hash != "" {
+		return o.Hash == snippet.Hash
+	}
+	return o.Page == snippet.Page && (o.StartLine == 0 || o.StartLine == snippet.StartLine)
+}
+
+// ApplyOverrides applies the first override that matches the snippet, and returns its index, or -1 if none match
+func ApplyOverrides(snippet SnippetInfo, overrides []SnippetOverride) (SnippetInfo, int) {
+	for i, override := range overrides {
+		if !override.Matches(snippet) {
+			continue
+		}
+		if override.Category != "" && override.Category != snippet.Category {
+			// The corrected category becomes the primary label, and the snippet keeps its other labels
+			if len(snippet.Labels) > 0 {
+				labels := []string{override.Category}
+				for _, label := range snippet.SecondaryLabels() {
+					if label != override.Category {
+						labels = append(labels, label)
+					}
+				}
+				snippet.Labels = labels
+				if len(labels) == 1 {
+					snippet.Labels = nil
+				}
+			}
+			snippet.Category = override.Category
+			// We can't tell the subcategory without the snippet's contents, which the reports don't keep
+			snippet.Subcategory = ""
+		}
+		if override.Language != "" {
+			snippet.Language = override.Language
+		}
+		snippet.Overridden = true
+		return snippet, i
+	}
+	return snippet, -1
+}
+
+// AggregateRun reads the snippets from the run directory one at a time, applies the overrides, and writes the
+// counts, rollup and language mismatch reports. If there are overrides, or the output is a different directory, it
+// also writes the snippet report, in the same form as the run's, so the reports in the output directory agree.
+func AggregateRun(options AggregateOptions) (AggregateSummary, error) {
+	var summary AggregateSummary
+	outputDir := options.OutputDir
+	if outputDir == "" {
+		outputDir = options.RunDir
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return summary, err
+	}
+	previous, err := ReadRepoReport(filepath.Join(options.RunDir, "language_category_counts.json"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return summary, err
+	}
+	projectName := options.ProjectName
+	if projectName == "" && previous.Metadata != nil {
+		projectName = previous.Metadata.ProjectName
+	}
+	if projectName == "" {
+		return summary, fmt.Errorf("the run doesn't record its project, pass -project")
+	}
+	docsBaseURL := options.DocsURL
+	if docsBaseURL == "" {
+		if rollups, err := ReadRollupReport(filepath.Join(options.RunDir, "rollups.json")); err == nil {
+			docsBaseURL = rollups.DocsBaseURL
+		}
+	}
+	docsBaseURL = DocsBaseURL(projectName, docsBaseURL)
+
+	snippetPath := SnippetReportPath(options.RunDir)
+	writeSnippets := len(options.Overrides) > 0 || len(options.Migrations) > 0 || filepath.Clean(outputDir) != filepath.Clean(options.RunDir)
+	var stream *SnippetStreamWriter
+	streamPath := filepath.Join(outputDir, SnippetStreamReportFile)
+	if writeSnippets && filepath.Ext(snippetPath) == ".ndjson" {
+		// We may be reading the file we're about to replace, so we stream to a temporary file and rename it at the end
+		stream, err = NewSnippetStreamWriter(filepath.Join(outputDir, "."+SnippetStreamReportFile+".aggregate"))
+		if err != nil {
+			return summary, err
+		}
+	}
+	source := SourceFiles
+	if previous.Metadata != nil && previous.Metadata.Source != "" {
+		source = previous.Metadata.Source
+	}
+	result := NewRunResult(docsBaseURL, source, stream)
+	result.SourceCommit = previous.SourceCommit
+	result.Metadata = previous.Metadata
+	matched := make([]bool, len(options.Overrides))
+	err = ReadSnippets(snippetPath, func(snippet SnippetInfo) error {
+		if len(options.Migrations) > 0 {
+			migrated, resolved := MigrateSnippet(snippet, options.Contents[snippet.Hash], options.Migrations)
+			if migrated.Category != snippet.Category {
+				summary.MigratedCount++
+			}
+			if !resolved {
+				summary.UnresolvedCount++
+			}
+			snippet = migrated
+		}
+		snippet, index := ApplyOverrides(snippet, options.Overrides)
+		if index >= 0 {
+			matched[index] = true
+			summary.OverriddenCount++
+		}
+		result.AddSnippet(snippet)
+		return nil
+	})
+	if err == nil && summary.UnresolvedCount > 0 {
+		err = fmt.Errorf("%d snippets need their contents to tell which category they split into, so we didn't migrate the reports", summary.UnresolvedCount)
+	}
+	if err != nil {
+		if stream != nil {
+			stream.Close()
+			os.Remove(stream.FilePath)
+		}
+		return summary, err
+	}
+	summary.SnippetCount = result.SnippetCount
+	for i, override := range options.Overrides {
+		if !matched[i] {
+			summary.UnmatchedOverrides = append(summary.UnmatchedOverrides, override)
+		}
+	}
+
+	if len(options.Migrations) > 0 && result.Metadata != nil {
+		if result.Metadata.MigratedFromTaxonomyVersion == 0 {
+			result.Metadata.MigratedFromTaxonomyVersion = options.Migrations[0].FromVersion
+		}
+		result.Metadata.TaxonomyVersion = options.Migrations[len(options.Migrations)-1].ToVersion
+	}
+	repoReport := BuildRepoReport(*result, IsDriverProject(projectName))
+	repoReport.ReportHeader = NewReportHeader(result.Metadata)
+	rollups := result.Rollups.Report()
+	rollups.ReportHeader = NewReportHeader(result.Metadata)
+	if writeSnippets {
+		if stream != nil {
+			if err := stream.Close(); err != nil {
+				return summary, err
+			}
+			if err := os.Rename(stream.FilePath, streamPath); err != nil {
+				return summary, err
+			}
+		} else {
+			if err := WriteSnippetReport(result.Snippets, result.Metadata, outputDir); err != nil {
+				return summary, err
+			}
+		}
+	}
+	if err := WriteCategoryCountsReport(repoReport, outputDir); err != nil {
+		return summary, err
+	}
+	if err := WriteRollupReport(rollups, outputDir); err != nil {
+		return summary, err
+	}
+	return summary, WriteLanguageMismatchReport(result.Mismatches, result.Metadata, outputDir)
+}
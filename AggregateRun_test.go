@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestApplyOverrides(t *testing.T) {
+	overrides := []SnippetOverride{
+		{Hash: "abc", Category: SyntaxExample},
+		{Page: "proj/page.rst", StartLine: 12, Language: SHELL},
+	}
+	byHash, index := ApplyOverrides(SnippetInfo{Page: "proj/other.rst", Category: UsageExample, Hash: "abc"}, overrides)
+	if byHash.Category != SyntaxExample || !byHash.Overridden || index != 0 {
+		t.Errorf("got %v and override %d, the hash should match the first override", byHash, index)
+	}
+	byLine, index := ApplyOverrides(SnippetInfo{Page: "proj/page.rst", StartLine: 12, Category: UsageExample, Language: TEXT}, overrides)
+	if byLine.Category != UsageExample || byLine.Language != SHELL || index != 1 {
+		t.Errorf("got %v and override %d, the page and line should match the second override", byLine, index)
+	}
+	labeled, _ := ApplyOverrides(SnippetInfo{Category: UsageExample, Labels: []string{UsageExample, ExampleReturnObject}, Hash: "abc"}, overrides)
+	if expected := []string{SyntaxExample, ExampleReturnObject}; !reflect.DeepEqual(labeled.Labels, expected) {
+		t.Errorf("got labels %q want %q, the override should become the primary label", labeled.Labels, expected)
+	}
+	_, index = ApplyOverrides(SnippetInfo{Page: "proj/page.rst", StartLine: 40}, overrides)
+	if index != -1 {
+		t.Errorf("got override %d want -1 for a snippet on another line", index)
+	}
+}
+
+func TestAggregateRun(t *testing.T) {
+	runDir := t.TempDir()
+	metadata := &RunMetadata{ProjectName: "proj", Model: MODEL}
+	snippets := []SnippetInfo{
+		{Page: "proj/a.txt", Category: UsageExample, Language: PYTHON, LLMCategorized: true, Hash: "one"},
+		{Page: "proj/b.txt", Category: UsageExample, Language: PYTHON, LLMCategorized: true, Hash: "two"},
+		{Page: "proj/c.txt", Category: SyntaxExample, Language: SHELL, Hash: "three"},
+	}
+	WriteSnippetReport(snippets, metadata, runDir)
+	WriteCategoryCountsReport(RepoReport{ReportHeader: NewReportHeader(metadata), TotalCodeBlocks: 3}, runDir)
+
+	summary, err := AggregateRun(AggregateOptions{
+		RunDir:    runDir,
+		Overrides: []SnippetOverride{{Hash: "two", Category: ExampleReturnObject}, {Hash: "gone", Category: SyntaxExample}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedSummary := AggregateSummary{SnippetCount: 3, OverriddenCount: 1, UnmatchedOverrides: []SnippetOverride{{Hash: "gone", Category: SyntaxExample}}}
+	if !reflect.DeepEqual(summary, expectedSummary) {
+		t.Errorf("got %v want %v", summary, expectedSummary)
+	}
+
+	repoReport, err := ReadRepoReport(filepath.Join(runDir, "language_category_counts.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repoReport.CategoryLanguageCounts[ExampleReturnObject][PYTHON] != 1 || repoReport.CategoryLanguageCounts[UsageExample][PYTHON] != 1 {
+		t.Errorf("got counts %v, the override should move one snippet to %s", repoReport.CategoryLanguageCounts, ExampleReturnObject)
+	}
+	expectedDetails := CategorizationDetails{LLMCategorizedCount: 1, StringMatchedCount: 1, OverriddenCount: 1, AccuracyEstimate: (1 + 1 + 0.65) / 3 * 100}
+	if repoReport.CategorizationDetails != expectedDetails {
+		t.Errorf("got %v want %v", repoReport.CategorizationDetails, expectedDetails)
+	}
+	if repoReport.Metadata == nil || repoReport.Metadata.ProjectName != "proj" {
+		t.Errorf("got metadata %v, the report should keep the run's metadata", repoReport.Metadata)
+	}
+
+	updated, err := ReadSnippetReport(filepath.Join(runDir, SnippetReportFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated[1].Category != ExampleReturnObject || !updated[1].Overridden {
+		t.Errorf("got %v, the snippet report should have the override", updated[1])
+	}
+	if _, err := os.Stat(filepath.Join(runDir, "rollups.json")); err != nil {
+		t.Errorf("the rollup report should exist: %v", err)
+	}
+}
+
+func TestReadOverridesRejectsUnknownCategories(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "overrides.json")
+	data, _ := json.Marshal([]SnippetOverride{{Page: "proj/a.txt", Category: "Not a category"}})
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadOverrides(filePath); err == nil {
+		t.Errorf("got no error for an unknown category")
+	}
+}
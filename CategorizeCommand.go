@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// RunCategorizeCommand categorizes one snippet from a file or stdin, and prints the result as JSON, in the same form
+// as the HTTP API, so pre-commit hooks and editor commands can categorize a snippet without a snippet tree
+func RunCategorizeCommand(args []string) {
+	flags := flag.NewFlagSet("categorize", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: go run . categorize [-lang tag] [-project name] [-project-type driver] [FILE]")
+		fmt.Fprintln(flags.Output(), "Reads the snippet from stdin if there's no FILE, or the FILE is -")
+		flags.PrintDefaults()
+	}
+	request, err := ParseSnippetRequest(flags, args, os.Stdin)
+	if err != nil {
+		log.Fatalf("failed to read the snippet: %v", err)
+	}
+
+	llm, err := NewOllamaLLM()
+	if err != nil {
+		log.Fatalf("failed to connect to ollama: %v", err)
+	}
+	// The categorizer's diagnostics go to stderr, so stdout is only the JSON result
+	LoadTokenEncoding(os.Stderr)
+	response, err := CategorizeRequestedSnippet(request, llm, context.Background())
+	if err != nil {
+		log.Fatalf("failed to categorize the snippet: %v", err)
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(response); err != nil {
+		log.Fatalf("failed to write the result: %v", err)
+	}
+}
+
+// ParseSnippetRequest adds the flags that the categorize and explain commands share to the flag set, parses the args,
+// and reads the snippet from the FILE arg or stdin. Add the command's own flags to the flag set before you call it.
+func ParseSnippetRequest(flags *flag.FlagSet, args []string, stdin io.Reader) (CategorizeRequest, error) {
+	lang := flags.String("lang", "", "the snippet's language or code-block tag, such as sh (default from the file name, or detected from the contents)")
+	project := flags.String("project", "", "the docs project the snippet is from, to look up whether it's a driver project")
+	projectType := flags.String("project-type", "", "\"driver\" to categorize the snippet as driver docs, or any other value for other docs (default from -project)")
+	if err := flags.Parse(args); err != nil {
+		return CategorizeRequest{}, err
+	}
+	if flags.NArg() > 1 {
+		return CategorizeRequest{}, fmt.Errorf("got %d files, pass one FILE, or the snippet on stdin", flags.NArg())
+	}
+	request, err := NewCategorizeRequest(stdin, flags.Arg(0), *lang)
+	if err != nil {
+		return request, err
+	}
+	request.Project = *project
+	request.ProjectType = *projectType
+	return request, nil
+}
+
+// NewCategorizeRequest reads the snippet from the file, or from stdin if the path is empty or -. If the language is
+// empty, we look it up from the file name, and if that doesn't work either, the categorizer detects it.
+func NewCategorizeRequest(stdin io.Reader, path string, lang string) (CategorizeRequest, error) {
+	var contents []byte
+	var err error
+	if path == "" || path == "-" {
+		contents, err = io.ReadAll(stdin)
+	} else {
+		contents, err = os.ReadFile(path)
+		if lang == "" {
+			lang = GetLanguageRegistry().LanguageForFile(path)
+		}
+	}
+	if err != nil {
+		return CategorizeRequest{}, err
+	}
+	if strings.TrimSpace(string(contents)) == "" {
+		return CategorizeRequest{}, errors.New("the snippet is empty")
+	}
+	return CategorizeRequest{Contents: string(contents), Language: lang}, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCategorizeSnippetFromFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "list-clusters.sh")
+	if err := os.WriteFile(filePath, []byte("atlas clusters list\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	request, err := NewCategorizeRequest(strings.NewReader(""), filePath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// String matches never reach the LLM, so we don't need one
+	got, err := CategorizeRequestedSnippet(request, nil, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := CategorizeResponse{Category: AtlasCLICommand, Subcategory: SubcategoryUsage, Language: SHELL, Path: PathStringMatch, Rule: `starts with "atlas "`, Confidence: 1}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v want %v", got, expected)
+	}
+}
+
+func TestNewCategorizeRequestFromStdin(t *testing.T) {
+	got, err := NewCategorizeRequest(strings.NewReader("db.movies.find()"), "-", "js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := CategorizeRequest{Contents: "db.movies.find()", Language: "js"}
+	if got != expected {
+		t.Errorf("got %v want %v", got, expected)
+	}
+	if _, err := NewCategorizeRequest(strings.NewReader(" \n"), "", ""); err == nil {
+		t.Errorf("got no error for an empty snippet")
+	}
+}
+
+func TestParseSnippetRequest(t *testing.T) {
+	flags := flag.NewFlagSet("explain", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "")
+	got, err := ParseSnippetRequest(flags, []string{"-json", "-lang", "sh", "-project-type", "driver", "-"}, strings.NewReader("mongosh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := CategorizeRequest{Contents: "mongosh", Language: "sh", ProjectType: "driver"}
+	if got != expected || !*asJSON {
+		t.Errorf("got %v and -json %t want %v and the command's own flag parsed", got, *asJSON, expected)
+	}
+	if _, err := ParseSnippetRequest(flag.NewFlagSet("categorize", flag.ContinueOnError), []string{"a.sh", "b.sh"}, strings.NewReader("")); err == nil {
+		t.Errorf("got no error for two files")
+	}
+}
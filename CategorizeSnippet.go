@@ -4,15 +4,20 @@ import (
 	"context"
 	"fmt"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/ollama"
 	"github.com/tmc/langchaingo/prompts"
 	"log"
 	"regexp"
 	"strings"
 )
 
-func HasStringMatchPrefix(contents string, langCategory string) (string, bool) {
-	// These prefixes related to syntax examples
+// HasStringMatchPrefix returns the category for the prefix the contents start with, and a description of the rule
+// that matched
+func HasStringMatchPrefix(contents string, langCategory string) (string, string, bool) {
+	return hasStringMatchPrefix(contents, langCategory, nil)
+}
+
+func hasStringMatchPrefix(contents string, langCategory string, trace *CategorizationTrace) (string, string, bool) {
+	// These prefixes run MongoDB command-line tools
 	atlasCli := "atlas "
 	mongosh := "mongosh "
 
@@ -45,50 +50,42 @@ func HasStringMatchPrefix(contents string, langCategory string) (string, bool) {
 	syft := "syft "
 	choco := "choco "
 
-	syntaxExamplePrefixes := []string{atlasCli, mongosh}
 	usageExamplePrefixes := []string{importPrefix, fromPrefix, namespacePrefix, packagePrefix, usingPrefix, mongoConnectionStringPrefix, alternoConnectionStringPrefix}
 	nonMongoPrefixes := []string{mkdir, cd, docker, dockerCompose, dockerCompose, brew, yum, apt, npm, pip, goRun, node, dotnet, export, jq, vi, cmake, syft, choco}
 
+	// We check the groups in order, and the first prefix that matches decides the category
+	var groups []stringMatchGroup
 	if langCategory == SHELL {
-		for _, prefix := range syntaxExamplePrefixes {
-			if strings.HasPrefix(contents, prefix) {
-				return SyntaxExample, true
-			}
-		}
-		for _, prefix := range nonMongoPrefixes {
-			if strings.HasPrefix(contents, prefix) {
-				return NonMongoCommand, true
-			}
-		}
-		return "Uncategorized", false
+		groups = []stringMatchGroup{{AtlasCLICommand, []string{atlasCli}}, {MongoshCommand, []string{mongosh}}, {NonMongoCommand, nonMongoPrefixes}}
 	} else if langCategory == TEXT {
-		for _, prefix := range syntaxExamplePrefixes {
-			if strings.HasPrefix(contents, prefix) {
-				return SyntaxExample, true
-			}
-		}
-		for _, prefix := range nonMongoPrefixes {
-			if strings.HasPrefix(contents, prefix) {
-				return NonMongoCommand, true
-			}
-		}
-		for _, prefix := range usageExamplePrefixes {
-			if strings.HasPrefix(contents, prefix) {
-				return UsageExample, true
-			}
-		}
-		return "Uncategorized", false
+		groups = []stringMatchGroup{{AtlasCLICommand, []string{atlasCli}}, {MongoshCommand, []string{mongosh}}, {NonMongoCommand, nonMongoPrefixes}, {UsageExample, usageExamplePrefixes}}
 	} else {
-		for _, prefix := range usageExamplePrefixes {
-			if strings.HasPrefix(contents, prefix) {
-				return UsageExample, true
+		groups = []stringMatchGroup{{UsageExample, usageExamplePrefixes}}
+	}
+	for _, group := range groups {
+		for _, prefix := range group.patterns {
+			matched := strings.HasPrefix(contents, prefix)
+			trace.AddRule(RuleStartsWith, prefix, group.category, matched, 0)
+			if matched {
+				return group.category, fmt.Sprintf("%s %q", RuleStartsWith, prefix), true
 			}
 		}
-		return "Uncategorized", false
 	}
+	return "Uncategorized", "", false
+}
+
+// stringMatchGroup is the category for contents that start with or contain any of the patterns
+type stringMatchGroup struct {
+	category string
+	patterns []string
 }
 
-func ContainsString(contents string) (string, bool) {
+// ContainsString returns the category for a string the contents contain, and a description of the rule that matched
+func ContainsString(contents string) (string, string, bool) {
+	return findExampleString(contents, nil)
+}
+
+func findExampleString(contents string, trace *CategorizationTrace) (string, string, bool) {
 	// These strings are typically included in usage examples
 	aggregationExample := ".aggregate"
 	mongoConnectionStringPrefix := "mongodb://"
@@ -108,37 +105,17 @@ func ContainsString(contents string) (string, bool) {
 	returnObjectStringsToEvaluate := []string{warningString, deprecatedString, idString}
 	nonMongoDBStringsToEvaluate := []string{cmake}
 
+	substring := contents
 	if substringLengthToCheck < len(contents) {
-		substring := contents[:substringLengthToCheck]
-		for _, exampleString := range usageExampleSubstringsToEvaluate {
-			if strings.Contains(substring, exampleString) {
-				return UsageExample, true
-			}
-		}
-		for _, exampleString := range returnObjectStringsToEvaluate {
-			if strings.Contains(substring, exampleString) {
-				return ExampleReturnObject, true
-			}
-		}
-		for _, exampleString := range nonMongoDBStringsToEvaluate {
-			if strings.Contains(substring, exampleString) {
-				return NonMongoCommand, true
-			}
-		}
-	} else {
-		for _, exampleString := range usageExampleSubstringsToEvaluate {
-			if strings.Contains(contents, exampleString) {
-				return UsageExample, true
-			}
-		}
-		for _, exampleString := range returnObjectStringsToEvaluate {
-			if strings.Contains(contents, exampleString) {
-				return ExampleReturnObject, true
-			}
-		}
-		for _, exampleString := range nonMongoDBStringsToEvaluate {
-			if strings.Contains(contents, exampleString) {
-				return NonMongoCommand, true
+		substring = contents[:substringLengthToCheck]
+	}
+	groups := []stringMatchGroup{{UsageExample, usageExampleSubstringsToEvaluate}, {ExampleReturnObject, returnObjectStringsToEvaluate}, {NonMongoCommand, nonMongoDBStringsToEvaluate}}
+	for _, group := range groups {
+		for _, exampleString := range group.patterns {
+			position := strings.Index(substring, exampleString)
+			trace.AddRule(RuleContains, exampleString, group.category, position >= 0, position)
+			if position >= 0 {
+				return group.category, fmt.Sprintf("%s %q", RuleContains, exampleString), true
 			}
 		}
 	}
@@ -159,55 +136,84 @@ func ContainsString(contents string) (string, bool) {
 	if err != nil {
 		log.Fatal("Error compiling the regexp for the agg pipeline: ", err)
 	}
-	regExpMatches := re.FindStringSubmatch(contents)
-	matchLength := len(regExpMatches)
-	if matchLength > 1 {
-		if regExpMatches[1] != "" {
-			return SyntaxExample, true
-		} else {
-			return UsageExample, true
-		}
-	} else {
-		return "Uncategorized", false
+	matchIndexes := re.FindStringSubmatchIndex(contents)
+	if matchIndexes == nil {
+		trace.AddRule(RuleMatches, aggPipeline, UsageExample+" or "+SyntaxExample, false, -1)
+		return "Uncategorized", "", false
+	}
+	// The capture group's start is -1 if the pipeline has no placeholder
+	if matchIndexes[2] >= 0 {
+		trace.AddRule(RuleMatches, aggPipeline, SyntaxExample, true, matchIndexes[2])
+		return SyntaxExample, "aggregation pipeline with a <placeholder>", true
 	}
+	trace.AddRule(RuleMatches, aggPipeline, UsageExample, true, matchIndexes[0])
+	return UsageExample, "aggregation pipeline", true
 }
 
 // CheckForStringMatch The bool we return from this func represents whether the string matching was successful.
 // If the string match was successful, we don't need to move on to LLM matching.
-func CheckForStringMatch(contents string, langCategory string) (string, bool) {
+func CheckForStringMatch(contents string, langCategory string) (string, string, bool) {
+	return checkForStringMatch(contents, langCategory, nil)
+}
+
+func checkForStringMatch(contents string, langCategory string, trace *CategorizationTrace) (string, string, bool) {
 	// Prefix matching should be fastest as it only has to search the first N characters of a string to determine whether it's
 	// a match. So first, try to match prefixes.
-	category, hasPrefix := HasStringMatchPrefix(contents, langCategory)
+	category, rule, hasPrefix := hasStringMatchPrefix(contents, langCategory, trace)
 	if hasPrefix {
-		return category, hasPrefix
+		return category, rule, hasPrefix
 	} else {
 		// If the prefix matching doesn't work, try the slower string matching.
-		thisCategory, containsExampleString := ContainsString(contents)
+		thisCategory, rule, containsExampleString := findExampleString(contents, trace)
 		if containsExampleString {
-			return thisCategory, containsExampleString
+			return thisCategory, rule, containsExampleString
 		} else {
-			return "Uncategorized", false
+			return "Uncategorized", "", false
 		}
 	}
 }
 
-func ProcessSnippet(contents string, lang string, llm *ollama.LLM, ctx context.Context, isDriverProject bool) (string, bool) {
-	var category string
-	validCategories := []string{ExampleReturnObject, ExampleConfigurationObject, NonMongoCommand, SyntaxExample, UsageExample}
+const (
+	PathStringMatch = "string-match"
+	PathLLM         = "llm"
+)
+
+// Categorization is a snippet's category, and how we got it: the Path is PathStringMatch or PathLLM, the Rule is the
+// string match that applied, and the Confidence is how likely the category is to be right, from 0 to 1. Commands in
+// the CommandCategories also have a Subcategory. With a multi-label taxonomy, the Labels are every category the LLM
+// gave, starting with the Category, when it gave more than one.
+type Categorization struct {
+	Category    string
+	Labels      []string
+	Subcategory string
+	Path        string
+	Rule        string
+	Confidence  float64
+	Fit         SnippetFit
+}
+
+// LLMCategorized is true if the LLM picked the category, rather than a string match
+func (c Categorization) LLMCategorized() bool {
+	return c.Path == PathLLM
+}
 
+// ProcessSnippet categorizes the snippet, and describes how we did it. It returns an error if the LLM fails to answer,
+// so the caller decides whether that stops a run or fails a single request.
+func ProcessSnippet(contents string, lang string, llm llms.Model, ctx context.Context, isDriverProject bool) (Categorization, error) {
 	/* If the start characters of the code example match a pattern we have defined for a given category,
 	 * return the category - no need to get the LLM involved.
 	 */
 	langCategory := GetLanguageCategory(lang)
-	category, stringMatchSuccessful := CheckForStringMatch(contents, langCategory)
+	trace := CategorizationTraceFrom(ctx)
+	category, rule, stringMatchSuccessful := checkForStringMatch(contents, langCategory, trace)
 	if stringMatchSuccessful {
-		/* The bool we are returning from this func represents whether the LLM categorized the snippet
-		 * If we have successfully used string matching to categorize the snippet, the LLM does not process it, so we
-		 * return false here
-		 */
-		return category, false
+		return Categorization{Category: category, Subcategory: CommandSubcategory(category, contents), Path: PathStringMatch, Rule: rule, Confidence: StringMatchConfidence}, nil
 	} else {
-		category = LLMAssignCategory(contents, langCategory, llm, ctx, isDriverProject)
+		category, fit, err := LLMAssignCategory(contents, langCategory, llm, ctx, isDriverProject)
+		if err != nil {
+			return Categorization{}, err
+		}
+		trace.RecordLLMAnswer(category)
 
 		/* I initially implemented this loop to ask the LLM to try again to categorize code examples that it couldn't categorize
 		 * I found that even after retrying, the LLM cannot categorize "uncategorized" examples based on our current definitions
@@ -222,192 +228,120 @@ func ProcessSnippet(contents string, lang string, llm *ollama.LLM, ctx context.C
 		//	}
 		//}
 		//return "Uncategorized", attemptCounter
-		if containsString(validCategories, category) {
-			return category, true
+		if labels := GetTaxonomy().ParseCategoryAnswer(category); len(labels) > 0 {
+			categorization := Categorization{Category: labels[0], Subcategory: CommandSubcategory(labels[0], contents), Path: PathLLM, Confidence: LLMConfidence(isDriverProject), Fit: fit}
+			if len(labels) > 1 {
+				categorization.Labels = labels
+			}
+			return categorization, nil
 		} else {
-			return "Uncategorized", true
+			return Categorization{Category: "Uncategorized", Path: PathLLM, Fit: fit}, nil
 		}
 	}
 }
 
-func GetLanguageCategory(lang string) string {
-	jsonLike := []string{JSON, XML, YAML}
-	driversLanguagesMinusJS := []string{C, CPP, CSHARP, GO, JAVA, KOTLIN, PHP, PYTHON, RUBY, RUST, SCALA, SWIFT, TYPESCRIPT}
-	if containsString([]string{SHELL}, lang) {
-		return SHELL
-	} else if containsString(jsonLike, lang) {
-		return JSON_LIKE
-	} else if containsString(driversLanguagesMinusJS, lang) {
-		return DRIVERS_MINUS_JS
-	} else if lang == JAVASCRIPT {
-		return JAVASCRIPT
-	} else if lang == TEXT {
-		return TEXT
-	} else {
-		return "Unknown language"
+const (
+	SubcategorySyntax = "syntax"
+	SubcategoryUsage  = "usage"
+)
+
+// CommandCategories are the categories for commands that run MongoDB command-line tools, which we break down into
+// commands that show the syntax and complete commands you can run
+var CommandCategories = []string{AtlasCLICommand, MongoshCommand}
+
+// commandSyntaxPattern matches a placeholder, such as `<clusterName>`, or an optional part of a command in brackets,
+// such as `[options]` or `[--projectId projectId]`
+var commandSyntaxPattern = regexp.MustCompile(`<[^<>\n]+>|\[(?:-|options|flags)[^\[\]\n]*\]`)
+
+// CommandSubcategory is SubcategorySyntax for a command with placeholders or optional parts, or SubcategoryUsage for a
+// complete command. Categories that aren't in the CommandCategories don't have a subcategory.
+func CommandSubcategory(category string, contents string) string {
+	if !containsString(CommandCategories, category) {
+		return ""
+	}
+	if commandSyntaxPattern.MatchString(contents) {
+		return SubcategorySyntax
 	}
+	return SubcategoryUsage
 }
 
-func LLMAssignCategory(contents string, langCategory string, llm *ollama.LLM, ctx context.Context, isDriverProject bool) string {
-	var category string
-	if langCategory == JSON_LIKE {
-		category = CategorizeJsonLikeSnippet(contents, llm, ctx)
-	} else if langCategory == DRIVERS_MINUS_JS {
-		category = CategorizeDriverLanguageSnippet(contents, llm, ctx)
-	} else if langCategory == JAVASCRIPT || langCategory == TEXT {
-		if isDriverProject {
-			category = CategorizeDriverLanguageSnippet(contents, llm, ctx)
-		} else {
-			category = CategorizeTextSnippet(contents, llm, ctx)
-		}
-	} else if langCategory == SHELL {
-		category = CategorizeShellSnippet(contents, llm, ctx)
+// LLMConfidence is how often we estimate the LLM picks the right category. It does better with driver code.
+func LLMConfidence(isDriverProject bool) float64 {
+	if isDriverProject {
+		return DriverLLMConfidence
 	}
-	return category
+	return OtherLLMConfidence
 }
 
-func CategorizeJsonLikeSnippet(contents string, llm *ollama.LLM, ctx context.Context) string {
-	// To tweak the prompt for accuracy, edit this question
-	const questionTemplate = `I need to sort code examples into one of these categories:
-	%s
-	%s
-	Use these definitions for each category to help categorize the code example:
-	%s: An example object, typically represented in JSON, enumerating fields in a return object and their types. Typically includes an '_id' field and represents one or more example documents. Many pieces of JSON that look similar or repetitive in structure.
-	%s: Example configuration object, typically represented in JSON or YAML, enumerating required/optional parameters and their types. If it shows an '_id' field, it is a return object, not a configuration object.
-	Using these definitions, which category applies to this code example? Don't list an explanation, only list the category name.`
-	question := fmt.Sprintf(questionTemplate,
-		ExampleReturnObject,
-		ExampleConfigurationObject,
-		ExampleReturnObject,
-		ExampleConfigurationObject,
-	)
-	template := prompts.NewPromptTemplate(
-		`Use the following pieces of context to answer the question at the end.
-			Context: {{.contents}}
-			Question: {{.question}}`,
-		[]string{"contents", "question"},
-	)
-	prompt, err := template.Format(map[string]any{
-		"contents": contents,
-		"question": question,
-	})
-	if err != nil {
-		log.Fatalf("failed to create a prompt from the template: %q\n, %q\n, %q\n, %q\n", template, contents, question, err)
-	}
-	completion, err := llms.GenerateFromSinglePrompt(ctx, llm, prompt)
-	if err != nil {
-		log.Fatalf("failed to generate a response from the given prompt: %q", prompt)
+// GetLanguageCategory looks up the language category in the language registry, so adding a language or moving it
+// to a different category is a change to the registry file rather than to this func
+func GetLanguageCategory(lang string) string {
+	category := GetLanguageRegistry().CategoryForLanguage(lang)
+	if category == "" {
+		return "Unknown language"
 	}
-	return completion
+	return category
 }
 
-func CategorizeShellSnippet(contents string, llm *ollama.LLM, ctx context.Context) string {
-	// To tweak the prompt for accuracy, edit this question
-	const questionTemplate = `I need to sort code examples into one of these categories:
-	%s
-	%s
-	%s
-	%s
-	Use these definitions for each category to help categorize the code example:
-	%s: One line or only a few lines of code that demonstrate popular command-line commands, such as 'docker ', 'go run', 'jq ', 'vi ', 'mkdir ', 'npm ', 'cd ' or other common command-line command invocations. If it starts with 'atlas ' it does not belong in this category - it is an Atlas CLI Command. If it starts with 'mongosh ' it does not belong in this category - it is a 'mongosh command'.
-	%s: One-line or only a few lines of code that shows the syntax of a command or a method call, but not the initialization of arguments or parameters passed into a command or method call. It demonstrates syntax but is not usable code on its own.
-	%s: Two variants: one is an example object, typically represented in JSON, enumerating fields in the return object and their types. Typically includes an '_id' field and represents one or more example documents. Many pieces of JSON that look similar or repetitive in structure. The second variant looks like text that has been logged to console, such as an error message or status information. May resemble "Backup completed." "Restore completed." or other short status messages.
-	%s: Example object, typically represented in JSON or YAML, enumerating required/optional parameters and their types. If it shows an '_id' field, it is a return object, not a configuration object.
-	Using these definitions, which category applies to this code example? Don't list an explanation, only list the category name.`
-	question := fmt.Sprintf(questionTemplate,
-		NonMongoCommand,
-		SyntaxExample,
-		ExampleReturnObject,
-		ExampleConfigurationObject,
-		NonMongoCommand,
-		SyntaxExample,
-		ExampleReturnObject,
-		ExampleConfigurationObject,
-	)
-	template := prompts.NewPromptTemplate(
-		`Use the following pieces of context to answer the question at the end.
-			Context: {{.contents}}
-			Question: {{.question}}`,
-		[]string{"contents", "question"},
-	)
-	prompt, err := template.Format(map[string]any{
-		"contents": contents,
-		"question": question,
-	})
-	if err != nil {
-		log.Fatalf("failed to create a prompt from the template: %q\n, %q\n, %q\n, %q\n", template, contents, question, err)
+// LLMAssignCategory asks the LLM the taxonomy's question for the language category. If no category in the taxonomy
+// applies to the language category, we don't ask, and the snippet is uncategorized.
+func LLMAssignCategory(contents string, langCategory string, llm llms.Model, ctx context.Context, isDriverProject bool) (string, SnippetFit, error) {
+	question := GetTaxonomy().Question(PromptLanguageCategory(langCategory, isDriverProject))
+	if question == "" {
+		return "", SnippetFit{}, nil
 	}
-	completion, err := llms.GenerateFromSinglePrompt(ctx, llm, prompt)
-	if err != nil {
-		log.Fatalf("failed to generate a response from the given prompt: %q", prompt)
+	return AskForCategory(contents, question, llm, ctx)
+}
+
+// PromptLanguageCategory is the language category whose prompt we use. Driver docs use JavaScript and text for driver
+// code, so we ask about those snippets the same way we ask about the other driver languages.
+func PromptLanguageCategory(langCategory string, isDriverProject bool) string {
+	if isDriverProject && (langCategory == JAVASCRIPT || langCategory == TEXT) {
+		return DRIVERS_MINUS_JS
 	}
-	return completion
+	return langCategory
 }
 
-func CategorizeTextSnippet(contents string, llm *ollama.LLM, ctx context.Context) string {
-	// To tweak the prompt for accuracy, edit this question
-	const questionTemplate = `I need to sort code examples into one of these categories:
-	%s
-	%s
-	%s
-	%s
-	%s
-	%s
-	%s
-	Use these definitions for each category to help categorize the code example:
-	%s: One line or only a few lines of code that demonstrate popular command-line commands, such as 'docker ', 'go run', 'jq ', 'vi ', 'mkdir ', 'npm ', 'cd ' or other common command-line command invocations. If it starts with 'atlas ' it does not belong in this category - it is an Atlas CLI Command. If it starts with 'mongosh ' it does not belong in this category - it is a 'mongosh command'.
-	%s: One-line or only a few lines of code that shows the syntax of a command or a method call, but not the initialization of arguments or parameters passed into a command or method call. It demonstrates syntax but is not usable code on its own.	
-	%s: Two variants: one is an example object, typically represented in JSON, enumerating fields in the return object and their types. Typically includes an '_id' field and represents one or more example documents. Many pieces of JSON that look similar or repetitive in structure. The second variant looks like text that has been logged to console, such as an error message or status information. May resemble "Backup completed." "Restore completed." or other short status messages.
-	%s: Example object, typically represented in JSON or YAML, enumerating required/optional parameters and their types. If it shows an '_id' field, it is a return object, not a configuration object.
-	%s: Longer code snippet that establishes parameters, performs basic set up code, and includes the larger context to demonstrate how to accomplish a task. If an example shows parameters but does not show initializing parameters, it is a syntax example, not a usage example.
-	Using these definitions, which category applies to this code example? Don't list an explanation, only list the category name.`
-	question := fmt.Sprintf(questionTemplate,
-		NonMongoCommand,
-		SyntaxExample,
-		ExampleReturnObject,
-		ExampleConfigurationObject,
-		UsageExample,
-		NonMongoCommand,
-		SyntaxExample,
-		ExampleReturnObject,
-		ExampleConfigurationObject,
-		UsageExample,
-	)
-	template := prompts.NewPromptTemplate(
-		`Use the following pieces of context to answer the question at the end.
-			Context: {{.contents}}
-			Question: {{.question}}`,
-		[]string{"contents", "question"},
-	)
-	prompt, err := template.Format(map[string]any{
-		"contents": contents,
-		"question": question,
-	})
-	if err != nil {
-		log.Fatalf("failed to create a prompt from the template: %q\n, %q\n, %q\n, %q\n", template, contents, question, err)
+// AskForCategory asks the LLM the question about the contents. If the contents don't fit in the context with the
+// question, we apply the OversizedSnippetStrategy rather than let ollama silently cut off the prompt.
+func AskForCategory(contents string, question string, llm llms.Model, ctx context.Context) (string, SnippetFit, error) {
+	fit := SnippetFit{
+		Tokens: CountTokens(contents),
+		Budget: SnippetTokenBudget(question, CountTokens),
 	}
-	completion, err := llms.GenerateFromSinglePrompt(ctx, llm, prompt)
-	if err != nil {
-		log.Fatalf("failed to generate a response from the given prompt: %q", prompt)
+	if fit.Tokens <= fit.Budget {
+		answer, err := GenerateCategory(contents, question, llm, ctx)
+		return answer, fit, err
+	}
+	fit.Strategy = OversizedSnippetStrategy
+	switch OversizedSnippetStrategy {
+	case StrategyChunkAndVote:
+		chunks := ChunkSnippet(contents, fit.Budget, CountTokens)
+		sampled := SampleChunks(chunks, MaxSnippetChunks)
+		// If we skip chunks, the LLM never sees part of the snippet
+		fit.Truncated = len(sampled) < len(chunks)
+		var votes []string
+		for _, chunk := range sampled {
+			vote, err := GenerateCategory(chunk, question, llm, ctx)
+			if err != nil {
+				return "", fit, err
+			}
+			votes = append(votes, vote)
+		}
+		return VoteForAnswer(votes, GetTaxonomy()), fit, nil
+	case StrategyStructuralSummary:
+		fit.Truncated = true
+		answer, err := GenerateCategory(SummarizeSnippetStructure(contents, fit.Budget, CountTokens), question, llm, ctx)
+		return answer, fit, err
+	default:
+		fit.Truncated = true
+		answer, err := GenerateCategory(HeadTailExcerpt(contents, fit.Budget, CountTokens), question, llm, ctx)
+		return answer, fit, err
 	}
-	return completion
 }
 
-func CategorizeDriverLanguageSnippet(contents string, llm *ollama.LLM, ctx context.Context) string {
-	// To tweak the prompt for accuracy, edit this question
-	const questionTemplate = `I need to sort code examples into one of these categories:
-		%s
-		%s
-		Use these definitions for each category to help categorize the code example:
-		%s: One-line or only a few lines of code that shows the syntax of a command or a method call, but not the initialization of arguments or parameters passed into a command or method call. It demonstrates syntax but is not usable code on its own.
-		%s: Longer code snippet that establishes parameters, performs basic set up code, and includes the larger context to demonstrate how to accomplish a task. If an example shows parameters but does not show initializing parameters, it is a syntax example, not a usage example.
-		Using these definitions, which category applies to this code example? Don't list an explanation, only list the category name.`
-	question := fmt.Sprintf(questionTemplate,
-		SyntaxExample,
-		UsageExample,
-		SyntaxExample,
-		UsageExample,
-	)
+// BuildCategoryPrompt puts the contents and the question in the prompt template
+func BuildCategoryPrompt(contents string, question string) string {
 	template := prompts.NewPromptTemplate(
 		`Use the following pieces of context to answer the question at the end.
 			Context: {{.contents}}
@@ -421,9 +355,20 @@ func CategorizeDriverLanguageSnippet(contents string, llm *ollama.LLM, ctx conte
 	if err != nil {
 		log.Fatalf("failed to create a prompt from the template: %q\n, %q\n, %q\n, %q\n", template, contents, question, err)
 	}
+	return prompt
+}
+
+// GenerateCategory asks the LLM the question about the contents, and returns its answer. If the caller gave up, such
+// as an API client that disconnected, the error is the context's error.
+func GenerateCategory(contents string, question string, llm llms.Model, ctx context.Context) (string, error) {
+	prompt := BuildCategoryPrompt(contents, question)
 	completion, err := llms.GenerateFromSinglePrompt(ctx, llm, prompt)
 	if err != nil {
-		log.Fatalf("failed to generate a response from the given prompt: %q", prompt)
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("failed to generate a response from the LLM: %w", err)
 	}
-	return completion
+	CategorizationTraceFrom(ctx).AddLLMCall(prompt, completion)
+	return completion, nil
 }
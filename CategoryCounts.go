@@ -0,0 +1,161 @@
+package main
+
+import "math"
+
+// CategoryCounts is the number of snippets in each category and in each language, with the totals, and each one's
+// share of all the snippets as a percentage rounded to two decimal places
+type CategoryCounts struct {
+	Total      int                      `json:"total"`
+	Categories map[string]CategoryTotal `json:"categories"`
+	Languages  map[string]LanguageTotal `json:"languages"`
+}
+
+// CategoryTotal is the number of snippets in a category, broken down by language, and for the CommandCategories, by
+// subcategory. The Total only counts snippets whose primary label is the category. A category with children in the
+// taxonomy also has the TotalWithChildren, which adds the snippets in its descendants, and SecondaryLabels is the
+// number of snippets that have the category as a label other than their primary one.
+type CategoryTotal struct {
+	Parent            string         `json:"parent,omitempty"`
+	Total             int            `json:"total"`
+	TotalWithChildren int            `json:"total_with_children,omitempty"`
+	Percentage        float64        `json:"percentage"`
+	Languages         map[string]int `json:"languages"`
+	Subcategories     map[string]int `json:"subcategories,omitempty"`
+	SecondaryLabels   int            `json:"secondary_labels,omitempty"`
+}
+
+// LanguageTotal is the number of snippets in a language, across every category
+type LanguageTotal struct {
+	Total      int     `json:"total"`
+	Percentage float64 `json:"percentage"`
+}
+
+// NewCategoryCounts totals the category and language counts. It doesn't change the counts it's given.
+func NewCategoryCounts(counts map[string]map[string]int) CategoryCounts {
+	categoryCounts := CategoryCounts{
+		Categories: make(map[string]CategoryTotal),
+		Languages:  make(map[string]LanguageTotal),
+	}
+	languageTotals := make(map[string]int)
+	for category, languageCounts := range counts {
+		categoryTotal := CategoryTotal{Languages: make(map[string]int)}
+		for language, count := range languageCounts {
+			categoryTotal.Languages[language] = count
+			categoryTotal.Total += count
+			languageTotals[language] += count
+		}
+		categoryCounts.Categories[category] = categoryTotal
+		categoryCounts.Total += categoryTotal.Total
+	}
+	for category, categoryTotal := range categoryCounts.Categories {
+		categoryTotal.Percentage = percentageOf(categoryTotal.Total, categoryCounts.Total)
+		categoryCounts.Categories[category] = categoryTotal
+	}
+	for language, total := range languageTotals {
+		categoryCounts.Languages[language] = LanguageTotal{Total: total, Percentage: percentageOf(total, categoryCounts.Total)}
+	}
+	return categoryCounts
+}
+
+// AddCategories adds the categories that don't have any snippets, so the report has a row for every category
+func (c *CategoryCounts) AddCategories(names []string) {
+	for _, name := range names {
+		if _, exists := c.Categories[name]; !exists {
+			c.Categories[name] = CategoryTotal{Languages: make(map[string]int)}
+		}
+	}
+}
+
+// AddSubcategories adds the number of snippets in each subcategory of each category
+func (c *CategoryCounts) AddSubcategories(subcategoryCounts map[string]map[string]int) {
+	for category, counts := range subcategoryCounts {
+		categoryTotal, exists := c.Categories[category]
+		if !exists {
+			continue
+		}
+		categoryTotal.Subcategories = make(map[string]int)
+		for subcategory, count := range counts {
+			categoryTotal.Subcategories[subcategory] = count
+		}
+		c.Categories[category] = categoryTotal
+	}
+}
+
+// AddHierarchy records each category's parent, and totals the snippets in each category with children along with
+// the snippets in its descendants
+func (c *CategoryCounts) AddHierarchy(taxonomy *Taxonomy) {
+	descendantTotals := make(map[string]int)
+	for category, categoryTotal := range c.Categories {
+		for _, ancestor := range taxonomy.Ancestors(category) {
+			descendantTotals[ancestor] += categoryTotal.Total
+		}
+		categoryTotal.Parent = taxonomy.Parent(category)
+		c.Categories[category] = categoryTotal
+	}
+	for _, definition := range taxonomy.Categories {
+		if definition.Parent == "" {
+			continue
+		}
+		parentTotal, exists := c.Categories[definition.Parent]
+		if !exists {
+			continue
+		}
+		parentTotal.TotalWithChildren = parentTotal.Total + descendantTotals[definition.Parent]
+		c.Categories[definition.Parent] = parentTotal
+	}
+}
+
+// AddSecondaryLabels adds the number of snippets with each category as a secondary label
+func (c *CategoryCounts) AddSecondaryLabels(labelCounts map[string]int) {
+	for label, count := range labelCounts {
+		c.AddCategories([]string{label})
+		categoryTotal := c.Categories[label]
+		categoryTotal.SecondaryLabels = count
+		c.Categories[label] = categoryTotal
+	}
+}
+
+// CategoryCountsFromLegacy totals the category_language_counts from a report, leaving out the "totals" that
+// GetCategorySums adds to each category. We use it for reports from before we wrote the typed counts.
+func CategoryCountsFromLegacy(legacyCounts map[string]map[string]int) CategoryCounts {
+	counts := make(map[string]map[string]int)
+	for category, languageCounts := range legacyCounts {
+		counts[category] = make(map[string]int)
+		for language, count := range languageCounts {
+			if language != LegacyTotalsKey {
+				counts[category][language] = count
+			}
+		}
+	}
+	return NewCategoryCounts(counts)
+}
+
+// LegacyTotalsKey is the key GetCategorySums adds to each category for the category's total
+const LegacyTotalsKey = "totals"
+
+// GetCategorySums returns a copy of the counts with each category's total under LegacyTotalsKey, which is how the
+// category_language_counts in the report have always looked. A language named "totals" would collide with the sum,
+// so new readers should use the typed counts instead.
+func GetCategorySums(counts map[string]map[string]int) map[string]map[string]int {
+	sums := make(map[string]map[string]int)
+	for category, languageCounts := range counts {
+		sums[category] = make(map[string]int)
+		// Initialize a sum variable for the current category
+		sum := 0
+		// Iterate over each language in the inner map
+		for language, count := range languageCounts {
+			sums[category][language] = count
+			// Accumulate the total count
+			sum += count
+		}
+		sums[category][LegacyTotalsKey] = sum
+	}
+	return sums
+}
+
+func percentageOf(count int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(count)/float64(total)*10000) / 100
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestNewCategoryCounts(t *testing.T) {
+	counts := map[string]map[string]int{
+		UsageExample:  {PYTHON: 2, "totals": 1},
+		SyntaxExample: {SHELL: 1},
+	}
+	got := NewCategoryCounts(counts)
+	expected := CategoryCounts{
+		Total: 4,
+		Categories: map[string]CategoryTotal{
+			UsageExample:  {Total: 3, Percentage: 75, Languages: map[string]int{PYTHON: 2, "totals": 1}},
+			SyntaxExample: {Total: 1, Percentage: 25, Languages: map[string]int{SHELL: 1}},
+		},
+		Languages: map[string]LanguageTotal{
+			PYTHON:   {Total: 2, Percentage: 50},
+			"totals": {Total: 1, Percentage: 25},
+			SHELL:    {Total: 1, Percentage: 25},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v want %v, a language named totals is just another language", got, expected)
+	}
+}
+
+func TestGetCategorySumsDoesNotChangeItsInput(t *testing.T) {
+	counts := map[string]map[string]int{UsageExample: {PYTHON: 2, GO: 1}}
+	got := GetCategorySums(counts)
+	expected := map[string]map[string]int{UsageExample: {PYTHON: 2, GO: 1, LegacyTotalsKey: 3}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v want %v", got, expected)
+	}
+	if _, exists := counts[UsageExample][LegacyTotalsKey]; exists {
+		t.Errorf("got %v, GetCategorySums shouldn't add the totals to its input", counts)
+	}
+}
+
+func TestTypedCountsFromLegacyReport(t *testing.T) {
+	report := RepoReport{CategoryLanguageCounts: map[string]map[string]int{UsageExample: {PYTHON: 1, GO: 1, LegacyTotalsKey: 2}}}
+	got := report.TypedCounts()
+	if got.Total != 2 || got.Categories[UsageExample].Total != 2 || len(got.Languages) != 2 {
+		t.Errorf("got %v, the legacy totals shouldn't count as a language", got)
+	}
+}
+
+func TestRepoReportCountsSubcategories(t *testing.T) {
+	result := NewRunResult("", SourceFiles, nil)
+	for _, contents := range []string{"atlas clusters create <clusterName> [options]", "atlas clusters create myCluster --tier M10", "atlas clusters list"} {
+		categorization, err := ProcessSnippet(contents, SHELL, nil, context.Background(), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result.AddSnippet(SnippetInfo{Page: "proj/a.txt", Category: categorization.Category, Subcategory: categorization.Subcategory, Language: SHELL})
+	}
+	repoReport := BuildRepoReport(*result, false)
+	got := repoReport.Counts.Categories[AtlasCLICommand]
+	expected := CategoryTotal{Total: 3, Percentage: 100, Languages: map[string]int{SHELL: 3}, Subcategories: map[string]int{SubcategorySyntax: 1, SubcategoryUsage: 2}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v want %v", got, expected)
+	}
+	if _, exists := repoReport.Counts.Categories[MongoshCommand]; !exists {
+		t.Errorf("got categories %v, the report should have a row for every category in the taxonomy", repoReport.Counts.Categories)
+	}
+}
+
+func TestCategoryCountsHierarchyAndSecondaryLabels(t *testing.T) {
+	taxonomy := DefaultTaxonomy()
+	taxonomy.Categories = append(taxonomy.Categories, CategoryDefinition{Name: "Tutorial step", Parent: UsageExample, Definition: "One step of a tutorial.", LanguageCategories: []string{DRIVERS_MINUS_JS}})
+	counts := NewCategoryCounts(map[string]map[string]int{UsageExample: {PYTHON: 2}, "Tutorial step": {PYTHON: 3}})
+	counts.AddCategories(taxonomy.CategoryNames())
+	counts.AddSecondaryLabels(map[string]int{SyntaxExample: 4})
+	counts.AddHierarchy(taxonomy)
+	if got := counts.Categories[UsageExample]; got.Total != 2 || got.TotalWithChildren != 5 {
+		t.Errorf("got %v want a total of 2, and 5 with its children", got)
+	}
+	if got := counts.Categories["Tutorial step"]; got.Parent != UsageExample || got.TotalWithChildren != 0 {
+		t.Errorf("got %v want the parent %q and no total with children", got, UsageExample)
+	}
+	if got := counts.Categories[SyntaxExample]; got.Total != 0 || got.SecondaryLabels != 4 {
+		t.Errorf("got %v want 4 secondary labels that don't count toward the total", got)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// CountDelta is a count in two runs, and how much it changed
+type CountDelta struct {
+	Old   int `json:"old"`
+	New   int `json:"new"`
+	Delta int `json:"delta"`
+}
+
+// RunComparison describes how the snippets changed from an old run to a new run
+type RunComparison struct {
+	ReportHeader
+	OldRun          string                 `json:"old_run"`
+	NewRun          string                 `json:"new_run"`
+	TotalCodeBlocks CountDelta             `json:"total_code_blocks"`
+	Categories      map[string]CountDelta  `json:"categories"`
+	Languages       map[string]CountDelta  `json:"languages"`
+	AddedSnippets   []SnippetInfo          `json:"added_snippets"`
+	RemovedSnippets []SnippetInfo          `json:"removed_snippets"`
+	Recategorized   []RecategorizedSnippet `json:"recategorized_snippets"`
+}
+
+// RunCompareCommand compares the reports in two run directories. For a report directory, such as output/atlas-cli@v1.19,
+// we compare its latest run.
+func RunCompareCommand(args []string) {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	output := flags.String("output", "", "where to write the comparison report (default comparison.json in the new run directory)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: go run . compare [-output file] OLD_RUN_DIR NEW_RUN_DIR")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	oldDir, newDir := ResolveRunDirectory(flags.Arg(0)), ResolveRunDirectory(flags.Arg(1))
+	comparison, err := CompareRunDirectories(oldDir, newDir)
+	if err != nil {
+		log.Fatalf("failed to compare the runs: %v", err)
+	}
+	LogComparisonToConsole(comparison)
+	filePath := *output
+	if filePath == "" {
+		filePath = filepath.Join(newDir, "comparison.json")
+	}
+	if err := WriteComparisonReport(comparison, filePath); err != nil {
+		log.Fatalf("failed to write the comparison report: %v", err)
+	}
+}
+
+// CompareRunDirectories reads the snippet and category counts reports from each run directory and compares them
+func CompareRunDirectories(oldDir string, newDir string) (RunComparison, error) {
+	var comparison RunComparison
+	oldSnippets, err := ReadSnippetReport(SnippetReportPath(oldDir))
+	if err != nil {
+		return comparison, err
+	}
+	newSnippets, err := ReadSnippetReport(SnippetReportPath(newDir))
+	if err != nil {
+		return comparison, err
+	}
+	oldReport, err := ReadRepoReport(filepath.Join(oldDir, "language_category_counts.json"))
+	if err != nil {
+		return comparison, err
+	}
+	newReport, err := ReadRepoReport(filepath.Join(newDir, "language_category_counts.json"))
+	if err != nil {
+		return comparison, err
+	}
+	if oldVersion, newVersion := oldReport.TaxonomyVersion(), newReport.TaxonomyVersion(); oldVersion != newVersion {
+		fmt.Printf("The old run uses taxonomy version %d and the new run uses version %d, so some categories may not compare. Run `go run . migrate` on the older run first.\n", oldVersion, newVersion)
+	}
+	comparison = CompareRuns(oldReport, newReport, oldSnippets, newSnippets)
+	comparison.OldRun = oldDir
+	comparison.NewRun = newDir
+	return comparison, nil
+}
+
+// CompareRuns computes the count deltas from the category counts reports, and matches the snippets on each page to
+// find the ones that were added, removed or recategorized
+func CompareRuns(oldReport RepoReport, newReport RepoReport, oldSnippets []SnippetInfo, newSnippets []SnippetInfo) RunComparison {
+	oldCategories, oldLanguages := categoryAndLanguageTotals(oldReport.TypedCounts())
+	newCategories, newLanguages := categoryAndLanguageTotals(newReport.TypedCounts())
+	// Every page is a changed page, so BuildSnippetDelta pairs up the snippets on all of them
+	pages := make(map[string]bool)
+	for _, snippet := range append(append([]SnippetInfo{}, oldSnippets...), newSnippets...) {
+		pages[snippet.Page] = true
+	}
+	delta, _ := BuildSnippetDelta(oldSnippets, newSnippets, pages)
+	added := []SnippetInfo{}
+	for _, snippets := range delta.NewSnippets {
+		added = append(added, snippets...)
+	}
+	sort.SliceStable(added, func(i, j int) bool {
+		if added[i].Page != added[j].Page {
+			return added[i].Page < added[j].Page
+		}
+		return added[i].StartLine < added[j].StartLine
+	})
+	return RunComparison{
+		ReportHeader:    NewReportHeader(nil),
+		TotalCodeBlocks: newCountDelta(oldReport.TotalCodeBlocks, newReport.TotalCodeBlocks),
+		Categories:      compareCounts(oldCategories, newCategories),
+		Languages:       compareCounts(oldLanguages, newLanguages),
+		AddedSnippets:   added,
+		RemovedSnippets: delta.Removed,
+		Recategorized:   delta.Recategorized,
+	}
+}
+
+// categoryAndLanguageTotals picks the total for each category and each language out of the counts
+func categoryAndLanguageTotals(counts CategoryCounts) (map[string]int, map[string]int) {
+	categories := make(map[string]int)
+	languages := make(map[string]int)
+	for category, total := range counts.Categories {
+		categories[category] = total.Total
+	}
+	for language, total := range counts.Languages {
+		languages[language] = total.Total
+	}
+	return categories, languages
+}
+
+func compareCounts(oldCounts map[string]int, newCounts map[string]int) map[string]CountDelta {
+	deltas := make(map[string]CountDelta)
+	for key, count := range oldCounts {
+		deltas[key] = newCountDelta(count, newCounts[key])
+	}
+	for key, count := range newCounts {
+		if _, exists := oldCounts[key]; !exists {
+			deltas[key] = newCountDelta(0, count)
+		}
+	}
+	return deltas
+}
+
+func newCountDelta(oldCount int, newCount int) CountDelta {
+	return CountDelta{Old: oldCount, New: newCount, Delta: newCount - oldCount}
+}
+
+func LogComparisonToConsole(comparison RunComparison) {
+	fmt.Printf("Comparing %s with %s\n", comparison.OldRun, comparison.NewRun)
+	fmt.Printf("Total code blocks: %d -> %d (%+d)\n", comparison.TotalCodeBlocks.Old, comparison.TotalCodeBlocks.New, comparison.TotalCodeBlocks.Delta)
+	categories := make([]string, 0, len(comparison.Categories))
+	for category := range comparison.Categories {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		delta := comparison.Categories[category]
+		fmt.Printf("  %s: %d -> %d (%+d)\n", category, delta.Old, delta.New, delta.Delta)
+	}
+	fmt.Printf("%d snippets added, %d removed, %d recategorized\n", len(comparison.AddedSnippets), len(comparison.RemovedSnippets), len(comparison.Recategorized))
+}
+
+func WriteComparisonReport(comparison RunComparison, filePath string) error {
+	fmt.Println("Writing comparison report")
+	comparisonJsonData, marshallingErr := json.MarshalIndent(comparison, "", "  ")
+	if marshallingErr != nil {
+		return fmt.Errorf("failed to marshal the report: %v", marshallingErr)
+	}
+	writeReportErr := WriteFileAtomically(filePath, comparisonJsonData)
+	if writeReportErr != nil {
+		return writeReportErr
+	}
+	fmt.Println("Comparison report successfully written to", filePath)
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareRuns(t *testing.T) {
+	oldReport := RepoReport{
+		TotalCodeBlocks: 3,
+		CategoryLanguageCounts: map[string]map[string]int{
+			UsageExample:  {PYTHON: 2, "totals": 2},
+			SyntaxExample: {SHELL: 1, "totals": 1},
+		},
+	}
+	newReport := RepoReport{
+		TotalCodeBlocks: 3,
+		CategoryLanguageCounts: map[string]map[string]int{
+			UsageExample:        {PYTHON: 1, GO: 1, "totals": 2},
+			ExampleReturnObject: {SHELL: 1, "totals": 1},
+		},
+	}
+	oldSnippets := []SnippetInfo{
+		{Page: "proj/a.txt", Category: UsageExample, Language: PYTHON, Hash: "one"},
+		{Page: "proj/b.txt", Category: UsageExample, Language: PYTHON, Hash: "two"},
+		{Page: "proj/c.txt", Category: SyntaxExample, Language: SHELL, Hash: "three"},
+	}
+	newSnippets := []SnippetInfo{
+		{Page: "proj/a.txt", Category: UsageExample, Language: PYTHON, Hash: "one"},
+		{Page: "proj/c.txt", Category: ExampleReturnObject, Language: SHELL, Hash: "three"},
+		{Page: "proj/d.txt", Category: UsageExample, Language: GO, Hash: "four"},
+	}
+	got := CompareRuns(oldReport, newReport, oldSnippets, newSnippets)
+
+	expectedCategories := map[string]CountDelta{
+		UsageExample:        {Old: 2, New: 2, Delta: 0},
+		SyntaxExample:       {Old: 1, New: 0, Delta: -1},
+		ExampleReturnObject: {Old: 0, New: 1, Delta: 1},
+	}
+	if !reflect.DeepEqual(got.Categories, expectedCategories) {
+		t.Errorf("got %v want %v", got.Categories, expectedCategories)
+	}
+	expectedLanguages := map[string]CountDelta{
+		PYTHON: {Old: 2, New: 1, Delta: -1},
+		SHELL:  {Old: 1, New: 1, Delta: 0},
+		GO:     {Old: 0, New: 1, Delta: 1},
+	}
+	if !reflect.DeepEqual(got.Languages, expectedLanguages) {
+		t.Errorf("got %v want %v", got.Languages, expectedLanguages)
+	}
+	if len(got.AddedSnippets) != 1 || got.AddedSnippets[0].Page != "proj/d.txt" {
+		t.Errorf("got %v want the snippet on d.txt as the only added snippet", got.AddedSnippets)
+	}
+	if len(got.RemovedSnippets) != 1 || got.RemovedSnippets[0].Page != "proj/b.txt" {
+		t.Errorf("got %v want the snippet on b.txt as the only removed snippet", got.RemovedSnippets)
+	}
+	expectedRecategorized := []RecategorizedSnippet{
+		{Page: "proj/c.txt", Language: SHELL, PreviousCategory: SyntaxExample, Category: ExampleReturnObject},
+	}
+	if !reflect.DeepEqual(got.Recategorized, expectedRecategorized) {
+		t.Errorf("got %v want %v", got.Recategorized, expectedRecategorized)
+	}
+}
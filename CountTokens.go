@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// TokenCounter counts the tokens in a piece of text
+type TokenCounter func(text string) int
+
+var (
+	tokenEncoding     *tiktoken.Tiktoken
+	tokenEncodingOnce sync.Once
+)
+
+// LoadTokenEncoding loads the tiktoken encoding in `constants.go` the first time it's called. By default, tiktoken
+// downloads the encoding without a timeout, so we load it from the copy bundled with tiktoken-go-loader instead, which
+// also works offline. If it still fails, we print a warning to the diagnostics writer, and CountTokens falls back to an
+// approximate count.
+func LoadTokenEncoding(diagnostics io.Writer) {
+	tokenEncodingOnce.Do(func() {
+		tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+		encoding, err := tiktoken.GetEncoding(TokenizerEncoding)
+		if err != nil {
+			fmt.Fprintf(diagnostics, "failed to load the %s token encoding, approximating token counts instead: %v\n", TokenizerEncoding, err)
+			return
+		}
+		tokenEncoding = encoding
+	})
+}
+
+// CountTokens counts the tokens in the text with the tiktoken encoding in `constants.go`. The model's own tokenizer
+// differs a little, which is why we leave headroom in the context. Some commands stream their results to stdout, so
+// we print any warning about the encoding to stderr.
+func CountTokens(text string) int {
+	LoadTokenEncoding(os.Stderr)
+	if tokenEncoding == nil {
+		return ApproximateTokens(text)
+	}
+	return len(tokenEncoding.Encode(text, nil, nil))
+}
+
+// ApproximateTokens assumes a token is about four characters, which is close enough for code
+func ApproximateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
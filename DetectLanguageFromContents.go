@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// These command names identify a line as a shell invocation when they appear at the start of the line. Words that
+// also start statements in other languages, such as `export`, `echo` and `go`, only count after a `$ ` prompt.
+var shellCommandPrefixes = []string{"atlas ", "mongosh ", "mongod ", "mongos ", "mongodump ", "mongorestore ",
+	"mongoimport ", "mongoexport ", "mkdir ", "cd ", "docker ", "docker-compose ", "brew ", "yum ", "apt ", "apt-get ",
+	"npm ", "npx ", "pip ", "pip3 ", "node ", "dotnet ", "jq ", "vi ", "cmake ", "syft ", "choco ", "curl ", "wget ",
+	"sudo ", "tar ", "git ", "kubectl ", "helm ", "mv ", "chmod ", "ls "}
+
+// languageSignals maps a language to patterns that strongly suggest a snippet is written in that language. A snippet
+// must match at least minimumSignalMatches distinct patterns for a language before we consider it detected.
+var languageSignals = map[string][]*regexp.Regexp{
+	GO: {
+		regexp.MustCompile(`(?m)^package \w+\s*$`),
+		regexp.MustCompile(`(?m)^func (\(\w+ \*?\w+\) )?\w+\(`),
+		regexp.MustCompile(`\w+ := `),
+		regexp.MustCompile(`(?m)^import \($`),
+		regexp.MustCompile(`if err != nil`),
+	},
+	PYTHON: {
+		regexp.MustCompile(`(?m)^\s*def \w+\(.*\):\s*$`),
+		regexp.MustCompile(`(?m)^from [\w.]+ import `),
+		regexp.MustCompile(`(?m)^import \w+(\.\w+)*\s*$`),
+		regexp.MustCompile(`\bprint\(`),
+		regexp.MustCompile(`(?m)^\s*(if|for|with|while) .*:\s*$`),
+	},
+	JAVA: {
+		regexp.MustCompile(`(?m)^import [\w.]+(\.\*)?;`),
+		regexp.MustCompile(`\bpublic (static )?(final )?(class|void|interface) `),
+		regexp.MustCompile(`System\.out\.print`),
+		regexp.MustCompile(`\bnew \w+(<.*>)?\(`),
+		regexp.MustCompile(`(?m)^package [\w.]+;`),
+	},
+	KOTLIN: {
+		regexp.MustCompile(`(?m)^\s*fun \w+\(`),
+		regexp.MustCompile(`(?m)^\s*val \w+ = `),
+		regexp.MustCompile(`\bprintln\(`),
+		regexp.MustCompile(`(?m)^import [\w.]+\s*$`),
+		regexp.MustCompile(`\bdata class `),
+	},
+	CSHARP: {
+		regexp.MustCompile(`(?m)^using [\w.]+;`),
+		regexp.MustCompile(`(?m)^namespace [\w.]+`),
+		regexp.MustCompile(`Console\.Write(Line)?\(`),
+		regexp.MustCompile(`\bvar \w+ = new `),
+		regexp.MustCompile(`\bpublic (static )?(async )?(class|void|Task) `),
+	},
+	JAVASCRIPT: {
+		regexp.MustCompile(`\b(const|let) \w+ = `),
+		regexp.MustCompile(`\brequire\(['"]`),
+		regexp.MustCompile(`(?m)^import .* from ['"]`),
+		regexp.MustCompile(`\bconsole\.log\(`),
+		regexp.MustCompile(`\basync function\b|=> \{`),
+	},
+	TYPESCRIPT: {
+		regexp.MustCompile(`\b(interface|type) \w+ (= )?\{`),
+		regexp.MustCompile(`\b(const|let) \w+: \w+`),
+		regexp.MustCompile(`(?m)^import .* from ['"]`),
+		regexp.MustCompile(`\): (Promise<\w+>|void|string|number)`),
+	},
+	PHP: {
+		regexp.MustCompile(`<\?php`),
+		regexp.MustCompile(`\$\w+ = `),
+		regexp.MustCompile(`\$\w+->\w+\(`),
+		regexp.MustCompile(`\becho `),
+	},
+	RUBY: {
+		regexp.MustCompile(`(?m)^require ['"]`),
+		regexp.MustCompile(`(?m)^\s*def \w+(\(.*\))?\s*$`),
+		regexp.MustCompile(`(?m)^\s*end\s*$`),
+		regexp.MustCompile(`\bputs `),
+		regexp.MustCompile(`\bdo \|\w+\|`),
+	},
+	RUST: {
+		regexp.MustCompile(`(?m)^\s*(pub )?fn \w+`),
+		regexp.MustCompile(`\blet (mut )?\w+`),
+		regexp.MustCompile(`(?m)^use [\w:]+`),
+		regexp.MustCompile(`println!\(`),
+		regexp.MustCompile(`\.await\?`),
+	},
+	C: {
+		regexp.MustCompile(`(?m)^#include <\w+\.h>`),
+		regexp.MustCompile(`\bprintf\(`),
+		regexp.MustCompile(`(?m)^int main\(`),
+		regexp.MustCompile(`\bbson_\w+\(|\bmongoc_\w+\(`),
+	},
+	CPP: {
+		regexp.MustCompile(`(?m)^#include <\w+>`),
+		regexp.MustCompile(`std::`),
+		regexp.MustCompile(`\bauto \w+ = `),
+		regexp.MustCompile(`mongocxx::|bsoncxx::`),
+	},
+	SWIFT: {
+		regexp.MustCompile(`(?m)^import (Foundation|MongoSwift\w*)`),
+		regexp.MustCompile(`\blet \w+ = try `),
+		regexp.MustCompile(`(?m)^\s*func \w+\(.*\) (async )?(throws )?(-> \w+ )?\{`),
+		regexp.MustCompile(`\bguard let `),
+	},
+	SCALA: {
+		regexp.MustCompile(`(?m)^\s*object \w+`),
+		regexp.MustCompile(`(?m)^\s*def \w+(\(.*\))?: \w+ = `),
+		regexp.MustCompile(`\bval \w+ = `),
+		regexp.MustCompile(`(?m)^import [\w.]+\._`),
+	},
+}
+
+const minimumSignalMatches = 2
+
+var (
+	xmlPattern       = regexp.MustCompile(`(?s)^\s*(<\?xml|<([a-zA-Z][\w:.-]*)[^>]*>.*</[a-zA-Z][\w:.-]*>\s*$)`)
+	yamlKeyPattern   = regexp.MustCompile(`^\s*(- )?[\w.-]+:(\s+\S.*)?$`)
+	shellShebang     = regexp.MustCompile(`^#!\s*/(usr/)?bin/(env )?(ba|z)?sh`)
+	shellPromptStart = regexp.MustCompile(`^\$ \S`)
+)
+
+// DetectLanguageFromContents infers the language of a snippet from its contents rather than its declared language.
+// It returns an empty string when the contents don't contain enough evidence to make a confident guess, so callers
+// should fall back to the declared language.
+func DetectLanguageFromContents(contents string) string {
+	trimmed := strings.TrimSpace(contents)
+	if trimmed == "" {
+		return ""
+	}
+	if isJson(trimmed) {
+		return JSON
+	}
+	if xmlPattern.MatchString(trimmed) {
+		return XML
+	}
+	if isShell(trimmed) {
+		return SHELL
+	}
+	if isYaml(trimmed) {
+		return YAML
+	}
+
+	bestLang := ""
+	bestScore := 0
+	tie := false
+	for lang, signals := range languageSignals {
+		score := 0
+		for _, signal := range signals {
+			if signal.MatchString(contents) {
+				score++
+			}
+		}
+		if score > bestScore {
+			bestLang = lang
+			bestScore = score
+			tie = false
+		} else if score == bestScore {
+			tie = true
+		}
+	}
+	if bestScore < minimumSignalMatches || tie {
+		return ""
+	}
+	return bestLang
+}
+
+// isJson reports whether the contents are one or more complete JSON objects or arrays. Return objects in the docs are
+// often several documents printed one after the other, so we decode values until the input is exhausted.
+func isJson(contents string) bool {
+	if !strings.HasPrefix(contents, "{") && !strings.HasPrefix(contents, "[") {
+		return false
+	}
+	decoder := json.NewDecoder(strings.NewReader(contents))
+	for {
+		var value json.RawMessage
+		err := decoder.Decode(&value)
+		if err == io.EOF {
+			return true
+		}
+		if err != nil {
+			return false
+		}
+		value = bytes.TrimSpace(value)
+		if len(value) == 0 || (value[0] != '{' && value[0] != '[') {
+			return false
+		}
+	}
+}
+
+func isShell(contents string) bool {
+	if shellShebang.MatchString(contents) {
+		return true
+	}
+	firstLine := contents
+	if newline := strings.Index(contents, "\n"); newline != -1 {
+		firstLine = contents[:newline]
+	}
+	firstLine = strings.TrimSpace(firstLine)
+	if shellPromptStart.MatchString(firstLine) {
+		return true
+	}
+	for _, prefix := range shellCommandPrefixes {
+		if strings.HasPrefix(firstLine, prefix) || firstLine == strings.TrimSpace(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isYaml requires most non-comment lines to look like `key: value` pairs or list items, which keeps prose and code
+// containing the odd colon from being detected as YAML
+func isYaml(contents string) bool {
+	keyLines := 0
+	otherLines := 0
+	for _, line := range strings.Split(contents, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" || trimmed == "..." {
+			continue
+		}
+		if strings.HasSuffix(trimmed, ";") || strings.HasSuffix(trimmed, "{") || strings.HasSuffix(trimmed, "(") {
+			return false
+		}
+		if yamlKeyPattern.MatchString(line) || strings.HasPrefix(trimmed, "- ") {
+			keyLines++
+		} else {
+			otherLines++
+		}
+	}
+	return keyLines >= 2 && keyLines > otherLines*3
+}
+
+// ResolveLanguage picks the language we use to route a snippet to a language category. A confident content-based
+// detection wins over the declared language, because the declared language comes from a file extension or code-block
+// tag that docs writers sometimes get wrong.
+func ResolveLanguage(declaredLang string, detectedLang string) string {
+	if detectedLang != "" {
+		return detectedLang
+	}
+	if declaredLang != "" {
+		return declaredLang
+	}
+	return UNKNOWN
+}
+
+// IsLanguageMismatch reports whether the declared language disagrees with the detected language. Languages that
+// share a syntax, such as JavaScript and TypeScript, don't count as a mismatch.
+func IsLanguageMismatch(declaredLang string, detectedLang string) bool {
+	if detectedLang == "" || declaredLang == detectedLang {
+		return false
+	}
+	compatible := map[string][]string{
+		JAVASCRIPT: {TYPESCRIPT},
+		TYPESCRIPT: {JAVASCRIPT},
+		C:          {CPP},
+		CPP:        {C},
+	}
+	return !containsString(compatible[declaredLang], detectedLang)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDetectLanguageFromContentsJsonReturnObject(t *testing.T) {
+	contents, err := os.ReadFile("examples/other/returnExample.sh")
+	if err != nil {
+		t.Fatalf("failed to read file %v", err)
+	}
+	got := DetectLanguageFromContents(string(contents))
+	if got != JSON {
+		t.Errorf("got %q want %q", got, JSON)
+	}
+}
+
+func TestDetectLanguageFromContentsAtlasCliCommand(t *testing.T) {
+	contents, err := os.ReadFile("examples/other/atlasCli.sh.go")
+	if err != nil {
+		t.Fatalf("failed to read file %v", err)
+	}
+	got := DetectLanguageFromContents(string(contents))
+	if got != SHELL {
+		t.Errorf("got %q want %q", got, SHELL)
+	}
+}
+
+func TestDetectLanguageFromContentsYaml(t *testing.T) {
+	contents, err := os.ReadFile("examples/other/configExample.yaml")
+	if err != nil {
+		t.Fatalf("failed to read file %v", err)
+	}
+	got := DetectLanguageFromContents(string(contents))
+	if got != YAML {
+		t.Errorf("got %q want %q", got, YAML)
+	}
+}
+
+func TestDetectLanguageFromContentsGo(t *testing.T) {
+	contents, err := os.ReadFile("examples/manage-indexes/drop-index.go")
+	if err != nil {
+		t.Fatalf("failed to read file %v", err)
+	}
+	got := DetectLanguageFromContents(string(contents))
+	if got != GO {
+		t.Errorf("got %q want %q", got, GO)
+	}
+}
+
+func TestDetectLanguageFromContentsAggregationIsNotJson(t *testing.T) {
+	contents, err := os.ReadFile("examples/other/aggUsageExample.js")
+	if err != nil {
+		t.Fatalf("failed to read file %v", err)
+	}
+	got := DetectLanguageFromContents(string(contents))
+	if got != "" {
+		t.Errorf("got %q want no detected language", got)
+	}
+}
+
+func TestDetectLanguageFromContentsCodeIsNotShell(t *testing.T) {
+	snippets := []string{
+		"export const x = 1;",
+		"export default function App() {\n  return null;\n}",
+		"echo \"Hello, world\";",
+		"go func() {\n\tdefer wg.Done()\n}()",
+	}
+	for _, snippet := range snippets {
+		got := DetectLanguageFromContents(snippet)
+		if got == SHELL {
+			t.Errorf("got %q for %q, want a language other than %q", got, snippet, SHELL)
+		}
+	}
+}
+
+func TestDetectLanguageFromContentsShellPrompt(t *testing.T) {
+	got := DetectLanguageFromContents("$ export MONGODB_URI=\"mongodb://localhost:27017\"")
+	if got != SHELL {
+		t.Errorf("got %q want %q", got, SHELL)
+	}
+}
+
+func TestResolveLanguagePrefersDetected(t *testing.T) {
+	got := ResolveLanguage(SHELL, JSON)
+	if got != JSON {
+		t.Errorf("got %q want %q", got, JSON)
+	}
+}
+
+func TestResolveLanguageFallsBackToUnknown(t *testing.T) {
+	got := ResolveLanguage("", "")
+	if got != UNKNOWN {
+		t.Errorf("got %q want %q", got, UNKNOWN)
+	}
+}
+
+func TestIsLanguageMismatch(t *testing.T) {
+	if !IsLanguageMismatch(SHELL, JSON) {
+		t.Errorf("expected shell declared as json detected to be a mismatch")
+	}
+	if IsLanguageMismatch(JAVASCRIPT, TYPESCRIPT) {
+		t.Errorf("expected javascript declared as typescript detected not to be a mismatch")
+	}
+	if IsLanguageMismatch(GO, "") {
+		t.Errorf("expected an undetected language not to be a mismatch")
+	}
+}
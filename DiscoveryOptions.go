@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// DiscoveryOptions control which files GetFiles passes back for categorization. Patterns are matched against the
+// slash-separated path relative to the start directory. A pattern without a slash matches a file or directory name at
+// any depth, a pattern with a slash is anchored to the start directory, and `**` matches any number of directories.
+type DiscoveryOptions struct {
+	// IncludePatterns If any are set, a file must match at least one of them to be categorized
+	IncludePatterns []string
+	// ExcludePatterns skip matching files, and skip matching directories without descending into them
+	ExcludePatterns []string
+	// IncludeHidden If false, we skip files and directories whose names start with a `.`
+	IncludeHidden bool
+	// FollowSymlinks If false, we skip symlinks. If true, we categorize the files they point to, and descend into the
+	// directories they point to unless we've already visited that directory.
+	FollowSymlinks bool
+	// SkipBinaryFiles If true, we skip files whose first bytes contain a NUL byte
+	SkipBinaryFiles bool
+	// MaxFileSize If greater than zero, we skip files larger than this many bytes
+	MaxFileSize int64
+}
+
+func DefaultDiscoveryOptions() DiscoveryOptions {
+	return DiscoveryOptions{
+		IncludePatterns: IncludePatterns,
+		ExcludePatterns: ExcludePatterns,
+		IncludeHidden:   IncludeHiddenPaths,
+		FollowSymlinks:  FollowSymlinks,
+		SkipBinaryFiles: true,
+		MaxFileSize:     MaxSnippetFileSize,
+	}
+}
+
+// ignoreRule is a single pattern from a .categorizeignore file. The base is the directory containing the ignore file,
+// relative to the start directory, because the patterns in an ignore file are relative to where the file lives.
+type ignoreRule struct {
+	base    string
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// ParseIgnoreRules parses an ignore file using a subset of the .gitignore syntax: blank lines and lines starting with
+// `#` are ignored, a leading `!` re-includes a path an earlier rule ignored, and a trailing `/` only matches directories.
+func ParseIgnoreRules(data []byte, base string) []ignoreRule {
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// IsIgnored applies the rules in order, and the last rule that matches decides, so a later `!` rule can re-include a
+// path that an earlier rule ignored
+func IsIgnored(rules []ignoreRule, relPath string, isDir bool) bool {
+	ignored := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		pathFromBase := relPath
+		if rule.base != "" {
+			if !strings.HasPrefix(relPath, rule.base+"/") {
+				continue
+			}
+			pathFromBase = strings.TrimPrefix(relPath, rule.base+"/")
+		}
+		if MatchGlob(rule.pattern, pathFromBase) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// MatchesAnyGlob reports whether the slash-separated relative path matches at least one of the patterns
+func MatchesAnyGlob(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		if MatchGlob(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchGlob matches a slash-separated relative path against a glob pattern. A pattern without a slash matches the last
+// element of the path, so `*.png` matches `images/diagram.png`. Otherwise the pattern must match the whole path.
+func MatchGlob(pattern string, relPath string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		relPath = path.Base(relPath)
+	}
+	re, err := regexp.Compile(globToRegexp(pattern))
+	if err != nil {
+		return false
+	}
+	return re.MatchString(relPath)
+}
+
+func globToRegexp(pattern string) string {
+	var builder strings.Builder
+	builder.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		char := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			// Zero or more whole directories
+			builder.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			builder.WriteString(".*")
+			i++
+		case char == '*':
+			builder.WriteString("[^/]*")
+		case char == '?':
+			builder.WriteString("[^/]")
+		case char == '\\' && i+1 < len(pattern):
+			// A backslash makes the next character literal
+			builder.WriteString(regexp.QuoteMeta(pattern[i+1 : i+2]))
+			i++
+		case char == '[':
+			class, end := bracketToRegexp(pattern[i:])
+			if end == -1 {
+				builder.WriteString(regexp.QuoteMeta(string(char)))
+			} else {
+				builder.WriteString(class)
+				i += end
+			}
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(char)))
+		}
+	}
+	builder.WriteString("$")
+	return builder.String()
+}
+
+// bracketToRegexp translates the bracket expression at the start of the pattern into a regexp character class. Like
+// gitignore, a leading `!` or `^` negates the class, a `]` right after the opening bracket is literal, and a backslash
+// makes the next character literal. A class never matches a slash. It returns the index of the closing bracket, or -1
+// if the expression isn't closed.
+func bracketToRegexp(pattern string) (string, int) {
+	var builder strings.Builder
+	builder.WriteString("[")
+	i := 1
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		builder.WriteString("^/")
+		i++
+	}
+	for start := i; i < len(pattern); i++ {
+		char := pattern[i]
+		switch {
+		case char == ']' && i > start:
+			builder.WriteString("]")
+			return builder.String(), i
+		case char == '\\' && i+1 < len(pattern) && pattern[i+1] == '-':
+			builder.WriteString(`\-`)
+			i++
+		case char == '\\' && i+1 < len(pattern):
+			builder.WriteString(regexp.QuoteMeta(pattern[i+1 : i+2]))
+			i++
+		case char == '-':
+			builder.WriteString("-")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(char)))
+		}
+	}
+	return "", -1
+}
+
+// IsBinaryFile uses the same heuristic as git: a file is binary if its first 8000 bytes contain a NUL byte. For files
+// on disk, this only reads the start of the file, so we can skip large binaries without reading them into memory.
+func IsBinaryFile(tree SnippetTree, name string) (bool, error) {
+	file, err := tree.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	buffer := make([]byte, 8000)
+	bytesRead, err := io.ReadFull(file, buffer)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return bytes.IndexByte(buffer[:bytesRead], 0) != -1, nil
+}
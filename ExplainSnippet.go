@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/tmc/langchaingo/llms"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	RuleStartsWith = "starts with"
+	RuleContains   = "contains"
+	RuleMatches    = "matches"
+)
+
+// CategorizationTrace records every decision we make while categorizing a snippet, so the explain command can show
+// why a snippet got its category. The categorizer finds the trace in the context, and its methods do nothing on a
+// nil trace, so a normal run doesn't pay for tracing.
+type CategorizationTrace struct {
+	DeclaredLanguage string           `json:"declared_language,omitempty"`
+	DetectedLanguage string           `json:"detected_language,omitempty"`
+	Language         string           `json:"language"`
+	LanguageCategory string           `json:"language_category"`
+	IsDriverProject  bool             `json:"is_driver_project"`
+	Rules            []RuleEvaluation `json:"rules"`
+	UsedLLM          bool             `json:"used_llm"`
+	LLMCalls         []LLMCall        `json:"llm_calls,omitempty"`
+	// LLMAnswer is the category the LLM gave, after any voting across chunks, before we check it's a valid category
+	LLMAnswer   string   `json:"llm_answer,omitempty"`
+	Tokens      int      `json:"tokens,omitempty"`
+	Budget      int      `json:"token_budget,omitempty"`
+	Strategy    string   `json:"oversized_snippet_strategy,omitempty"`
+	Truncated   bool     `json:"truncated"`
+	Category    string   `json:"category"`
+	Labels      []string `json:"labels,omitempty"`
+	Subcategory string   `json:"subcategory,omitempty"`
+	Path        string   `json:"path"`
+	Rule        string   `json:"rule,omitempty"`
+	Confidence  float64  `json:"confidence"`
+}
+
+// RuleEvaluation is one string match rule we checked, in the order we checked it. The Position is the byte offset of
+// the match in the snippet, or -1 if the rule didn't match.
+type RuleEvaluation struct {
+	Rule     string `json:"rule"`
+	Category string `json:"category"`
+	Matched  bool   `json:"matched"`
+	Position int    `json:"position"`
+}
+
+// LLMCall is a prompt we sent to the LLM, and the completion it sent back
+type LLMCall struct {
+	Prompt     string `json:"prompt"`
+	Completion string `json:"completion"`
+}
+
+type traceContextKey struct{}
+
+// WithCategorizationTrace returns a context that makes the categorizer record its decisions in the trace
+func WithCategorizationTrace(ctx context.Context, trace *CategorizationTrace) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, trace)
+}
+
+// CategorizationTraceFrom returns the trace in the context, or nil if we aren't tracing
+func CategorizationTraceFrom(ctx context.Context) *CategorizationTrace {
+	if ctx == nil {
+		return nil
+	}
+	trace, _ := ctx.Value(traceContextKey{}).(*CategorizationTrace)
+	return trace
+}
+
+func (t *CategorizationTrace) AddRule(kind string, pattern string, category string, matched bool, position int) {
+	if t == nil {
+		return
+	}
+	if !matched {
+		position = -1
+	}
+	rule := fmt.Sprintf("%s %q", kind, pattern)
+	if kind == RuleMatches {
+		// Quoting would double the backslashes in the regexp
+		rule = fmt.Sprintf("%s `%s`", kind, pattern)
+	}
+	t.Rules = append(t.Rules, RuleEvaluation{Rule: rule, Category: category, Matched: matched, Position: position})
+}
+
+func (t *CategorizationTrace) AddLLMCall(prompt string, completion string) {
+	if t == nil {
+		return
+	}
+	t.LLMCalls = append(t.LLMCalls, LLMCall{Prompt: prompt, Completion: completion})
+}
+
+func (t *CategorizationTrace) RecordLLMAnswer(category string) {
+	if t == nil {
+		return
+	}
+	t.UsedLLM = true
+	t.LLMAnswer = category
+}
+
+// ExplainSnippet categorizes the snippet the same way a run does, and returns the trace of how we did it
+func ExplainSnippet(request CategorizeRequest, llm llms.Model, ctx context.Context) (CategorizationTrace, error) {
+	trace := &CategorizationTrace{
+		DeclaredLanguage: GetLanguageRegistry().LanguageForTag(request.Language),
+		DetectedLanguage: DetectLanguageFromContents(request.Contents),
+		IsDriverProject:  request.IsDriverRequest(),
+	}
+	trace.Language = ResolveLanguage(trace.DeclaredLanguage, trace.DetectedLanguage)
+	trace.LanguageCategory = GetLanguageCategory(trace.Language)
+	categorization, err := ProcessSnippet(request.Contents, trace.Language, llm, WithCategorizationTrace(ctx, trace), trace.IsDriverProject)
+	if err != nil {
+		return *trace, err
+	}
+	trace.Category = categorization.Category
+	trace.Labels = categorization.Labels
+	trace.Subcategory = categorization.Subcategory
+	trace.Path = categorization.Path
+	trace.Rule = categorization.Rule
+	trace.Confidence = categorization.Confidence
+	trace.Tokens = categorization.Fit.Tokens
+	trace.Budget = categorization.Fit.Budget
+	trace.Strategy = categorization.Fit.Strategy
+	trace.Truncated = categorization.Fit.Truncated
+	return *trace, nil
+}
+
+// RunExplainCommand categorizes one snippet from a file or stdin, and prints every decision the categorizer made
+func RunExplainCommand(args []string) {
+	flags := flag.NewFlagSet("explain", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the trace as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: go run . explain [-lang tag] [-project name] [-project-type driver] [-json] [FILE]")
+		fmt.Fprintln(flags.Output(), "Reads the snippet from stdin if there's no FILE, or the FILE is -")
+		flags.PrintDefaults()
+	}
+	request, err := ParseSnippetRequest(flags, args, os.Stdin)
+	if err != nil {
+		log.Fatalf("failed to read the snippet: %v", err)
+	}
+
+	llm, err := NewOllamaLLM()
+	if err != nil {
+		log.Fatalf("failed to connect to ollama: %v", err)
+	}
+	// The categorizer's diagnostics go to stderr, so stdout is only the explanation
+	LoadTokenEncoding(os.Stderr)
+	trace, err := ExplainSnippet(request, llm, context.Background())
+	if err != nil {
+		log.Fatalf("failed to categorize the snippet: %v", err)
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(trace); err != nil {
+			log.Fatalf("failed to write the trace: %v", err)
+		}
+		return
+	}
+	trace.Write(os.Stdout)
+}
+
+// Write prints the trace for a person to read, in the order the categorizer made its decisions
+func (t CategorizationTrace) Write(writer io.Writer) {
+	orNone := func(value string) string {
+		if value == "" {
+			return "none"
+		}
+		return value
+	}
+	fmt.Fprintf(writer, "Language:          %s (declared %s, detected %s)\n", t.Language, orNone(t.DeclaredLanguage), orNone(t.DetectedLanguage))
+	fmt.Fprintf(writer, "Language category: %s\n", t.LanguageCategory)
+	fmt.Fprintf(writer, "Driver project:    %t\n", t.IsDriverProject)
+
+	fmt.Fprintf(writer, "\nString match rules, in the order we checked them:\n")
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	for _, rule := range t.Rules {
+		result := "no match"
+		if rule.Matched {
+			result = fmt.Sprintf("matched at byte %d", rule.Position)
+		}
+		fmt.Fprintf(table, "  %s\t%s\t%s\n", rule.Rule, rule.Category, result)
+	}
+	table.Flush()
+
+	if !t.UsedLLM {
+		fmt.Fprintf(writer, "\nThe string match decided the category, so we didn't ask the LLM.\n")
+	} else if len(t.LLMCalls) == 0 {
+		fmt.Fprintf(writer, "\nWe don't have an LLM prompt for the %s language category.\n", t.LanguageCategory)
+	} else {
+		fmt.Fprintf(writer, "\nThe snippet is %d tokens, and the prompt has room for %d.", t.Tokens, t.Budget)
+		if t.Strategy != "" {
+			fmt.Fprintf(writer, " We used the %s strategy to fit it.", t.Strategy)
+		}
+		fmt.Fprintln(writer)
+		for i, call := range t.LLMCalls {
+			fmt.Fprintf(writer, "\nPrompt %d:\n%s\n", i+1, indentLines(call.Prompt))
+			fmt.Fprintf(writer, "Completion %d: %q\n", i+1, call.Completion)
+		}
+		fmt.Fprintf(writer, "\nLLM answer: %q\n", t.LLMAnswer)
+	}
+
+	fmt.Fprintf(writer, "\nCategory:    %s\n", t.Category)
+	if len(t.Labels) > 0 {
+		fmt.Fprintf(writer, "Labels:      %s\n", strings.Join(t.Labels, ", "))
+	}
+	if t.Subcategory != "" {
+		fmt.Fprintf(writer, "Subcategory: %s\n", t.Subcategory)
+	}
+	fmt.Fprintf(writer, "Path:        %s\n", t.Path)
+	if t.Rule != "" {
+		fmt.Fprintf(writer, "Rule:        %s\n", t.Rule)
+	}
+	fmt.Fprintf(writer, "Confidence:  %g\n", t.Confidence)
+}
+
+func indentLines(text string) string {
+	return "    " + strings.ReplaceAll(text, "\n", "\n    ")
+}
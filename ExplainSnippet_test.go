@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExplainSnippetPrefixRules(t *testing.T) {
+	// String matches never reach the LLM, so we don't need one
+	got, err := ExplainSnippet(CategorizeRequest{Contents: "mongosh --version", Language: "sh"}, nil, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedRules := []RuleEvaluation{
+		{Rule: `starts with "atlas "`, Category: AtlasCLICommand, Matched: false, Position: -1},
+		{Rule: `starts with "mongosh "`, Category: MongoshCommand, Matched: true, Position: 0},
+	}
+	if !reflect.DeepEqual(got.Rules, expectedRules) {
+		t.Errorf("got %v want %v", got.Rules, expectedRules)
+	}
+	if got.Language != SHELL || got.DeclaredLanguage != SHELL || got.UsedLLM || got.Category != MongoshCommand || got.Subcategory != SubcategoryUsage || got.Rule != `starts with "mongosh "` {
+		t.Errorf("got %+v, the prefix should decide the category", got)
+	}
+}
+
+func TestExplainSnippetContainsRules(t *testing.T) {
+	contents := "client = MongoClient('mongodb://localhost')"
+	got, err := ExplainSnippet(CategorizeRequest{Contents: contents, Language: "python"}, nil, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := got.Rules[len(got.Rules)-1]
+	expected := RuleEvaluation{Rule: `contains "mongodb://"`, Category: UsageExample, Matched: true, Position: strings.Index(contents, "mongodb://")}
+	if last != expected {
+		t.Errorf("got %v want %v", last, expected)
+	}
+	// Every usage prefix, then the .aggregate rule, come before the match
+	if len(got.Rules) != 9 {
+		t.Errorf("got %d rules want 9: %v", len(got.Rules), got.Rules)
+	}
+}
+
+func TestCategorizationTraceIsOptional(t *testing.T) {
+	if CategorizationTraceFrom(context.Background()) != nil {
+		t.Errorf("got a trace from a context without one")
+	}
+	var trace *CategorizationTrace
+	trace.AddRule(RuleContains, "_id", ExampleReturnObject, true, 3)
+	trace.AddLLMCall("prompt", "completion")
+}
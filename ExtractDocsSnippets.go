@@ -0,0 +1,413 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	DirectiveCodeBlock      = "code-block"
+	DirectiveLiteralInclude = "literalinclude"
+	DirectiveIOInput        = "io-code-block input"
+	DirectiveIOOutput       = "io-code-block output"
+	DirectiveFencedBlock    = "fenced"
+)
+
+var (
+	rstDirectivePattern = regexp.MustCompile(`^(\s*)\.\.\s+([\w-]+)::\s*(.*?)\s*$`)
+	rstOptionPattern    = regexp.MustCompile(`^\s+:([\w-]+):\s*(.*?)\s*$`)
+	markdownFence       = regexp.MustCompile("^\\s*(`{3,}|~{3,})\\s*(.*?)\\s*$")
+	markdownTitle       = regexp.MustCompile(`(?:title|caption)="([^"]*)"`)
+)
+
+// These directive names all declare a code block whose contents are the directive content
+var rstCodeBlockDirectives = []string{"code-block", "code", "sourcecode"}
+
+// These are the docs source extensions we parse in docs mode. MongoDB docs projects keep their reStructuredText
+// sources in `.txt` files.
+var (
+	rstSourceExtensions      = []string{".rst", ".txt"}
+	markdownSourceExtensions = []string{".md", ".markdown"}
+)
+
+// rstDirective is a directive and the indented block that follows it. Line numbers are 1-based line numbers on the page.
+type rstDirective struct {
+	name         string
+	argument     string
+	options      map[string]string
+	content      []string
+	contentStart int
+	startLine    int
+	endLine      int
+}
+
+// ExtractDocsSnippets walks a docs source tree and extracts the code blocks from every reStructuredText and Markdown
+// page into memory, so we can categorize code examples without extracting them into one-file-per-snippet trees first
+func ExtractDocsSnippets(tree SnippetTree, projectName string) ([]RawSnippet, []IngestionDiagnostic) {
+	options := DefaultDiscoveryOptions()
+	if len(options.IncludePatterns) == 0 {
+		for _, ext := range append(append([]string{}, rstSourceExtensions...), markdownSourceExtensions...) {
+			options.IncludePatterns = append(options.IncludePatterns, "*"+ext)
+		}
+	}
+	files, diagnostics := DiscoverFiles(tree, options)
+
+	var rawSnippets []RawSnippet
+	for _, file := range files {
+		contents, err := tree.ReadFile(file)
+		if err != nil {
+			diagnostics = append(diagnostics, IngestionDiagnostic{Path: tree.DisplayPath(file), Reason: DiagnosticUnreadable, Detail: err.Error(), Skipped: true})
+			continue
+		}
+		page := PagePath(file, projectName)
+		ext := strings.ToLower(path.Ext(file))
+		var pageSnippets []RawSnippet
+		var pageDiagnostics []IngestionDiagnostic
+		if containsString(markdownSourceExtensions, ext) {
+			pageSnippets = ExtractMarkdownSnippets(page, string(contents))
+		} else {
+			pageSnippets, pageDiagnostics = ExtractRstSnippets(tree, page, file, string(contents))
+		}
+		diagnostics = append(diagnostics, pageDiagnostics...)
+		for _, snippet := range pageSnippets {
+			if strings.TrimSpace(snippet.Contents) == "" {
+				diagnostics = append(diagnostics, IngestionDiagnostic{
+					Path:    fmt.Sprintf("%s:%d", tree.DisplayPath(file), snippet.StartLine),
+					Reason:  DiagnosticEmpty,
+					Detail:  snippet.Directive + " contains only whitespace",
+					Skipped: true,
+				})
+				continue
+			}
+			rawSnippets = append(rawSnippets, snippet)
+		}
+	}
+	return rawSnippets, diagnostics
+}
+
+// FindDocsSourceRoot returns the closest `source` directory above the page, which is the directory that absolute
+// include paths such as `/includes/example.js` are relative to. If there isn't one, we use the root of the tree.
+func FindDocsSourceRoot(pageFile string) string {
+	for dir := path.Dir(pageFile); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if path.Base(dir) == "source" {
+			return dir
+		}
+	}
+	return "."
+}
+
+// ExtractRstSnippets finds the `code-block`, `literalinclude` and `io-code-block` directives on a reStructuredText
+// page. An `io-code-block` produces a snippet for its input and another for its output. Directives we don't handle,
+// such as `note`, can contain code blocks, so we keep scanning inside them.
+func ExtractRstSnippets(tree SnippetTree, page string, pageFile string, contents string) ([]RawSnippet, []IngestionDiagnostic) {
+	sourceRoot := FindDocsSourceRoot(pageFile)
+	lines := strings.Split(contents, "\n")
+	var rawSnippets []RawSnippet
+	var diagnostics []IngestionDiagnostic
+	for i := 0; i < len(lines); i++ {
+		directive, next, isDirective := parseRstDirective(lines, i, 0)
+		if !isDirective {
+			continue
+		}
+		switch {
+		case containsString(rstCodeBlockDirectives, directive.name):
+			rawSnippets = append(rawSnippets, RawSnippet{
+				Page:             page,
+				DeclaredLanguage: GetLanguageRegistry().LanguageForTag(directive.argument),
+				Contents:         strings.Join(directive.content, "\n"),
+				StartLine:        directive.startLine,
+				EndLine:          directive.endLine,
+				Caption:          directive.options["caption"],
+				Directive:        DirectiveCodeBlock,
+			})
+		case directive.name == "literalinclude":
+			snippet, err := includedSnippet(tree, page, pageFile, directive, sourceRoot)
+			if err != nil {
+				diagnostics = append(diagnostics, includeDiagnostic(tree, pageFile, directive, err))
+				break
+			}
+			snippet.Directive = DirectiveLiteralInclude
+			rawSnippets = append(rawSnippets, snippet)
+		case directive.name == "io-code-block":
+			ioSnippets, ioDiagnostics := extractIOCodeBlock(tree, page, pageFile, directive, sourceRoot)
+			rawSnippets = append(rawSnippets, ioSnippets...)
+			diagnostics = append(diagnostics, ioDiagnostics...)
+		default:
+			continue
+		}
+		// Skip over the block we just consumed
+		i = next - 1
+	}
+	return rawSnippets, diagnostics
+}
+
+// extractIOCodeBlock parses the nested `input` and `output` directives in the content of an `io-code-block`. Each one
+// either includes a file, when it has an argument, or contains the code inline.
+func extractIOCodeBlock(tree SnippetTree, page string, pageFile string, ioBlock rstDirective, sourceRoot string) ([]RawSnippet, []IngestionDiagnostic) {
+	var rawSnippets []RawSnippet
+	var diagnostics []IngestionDiagnostic
+	for i := 0; i < len(ioBlock.content); i++ {
+		directive, next, isDirective := parseRstDirective(ioBlock.content, i, ioBlock.contentStart-1)
+		if !isDirective || (directive.name != "input" && directive.name != "output") {
+			continue
+		}
+		kind := DirectiveIOInput
+		if directive.name == "output" {
+			kind = DirectiveIOOutput
+		}
+		var snippet RawSnippet
+		if directive.argument != "" {
+			included, err := includedSnippet(tree, page, pageFile, directive, sourceRoot)
+			if err != nil {
+				diagnostics = append(diagnostics, includeDiagnostic(tree, pageFile, directive, err))
+				i = next - 1
+				continue
+			}
+			snippet = included
+		} else {
+			snippet = RawSnippet{
+				Page:             page,
+				DeclaredLanguage: GetLanguageRegistry().LanguageForTag(directive.options["language"]),
+				Contents:         strings.Join(directive.content, "\n"),
+				StartLine:        directive.startLine,
+				EndLine:          directive.endLine,
+			}
+		}
+		snippet.Directive = kind
+		snippet.Caption = directive.options["caption"]
+		if snippet.Caption == "" {
+			snippet.Caption = ioBlock.options["caption"]
+		}
+		rawSnippets = append(rawSnippets, snippet)
+		i = next - 1
+	}
+	return rawSnippets, diagnostics
+}
+
+func includeDiagnostic(tree SnippetTree, pageFile string, directive rstDirective, err error) IngestionDiagnostic {
+	return IngestionDiagnostic{
+		Path:    fmt.Sprintf("%s:%d", tree.DisplayPath(pageFile), directive.startLine),
+		Reason:  DiagnosticUnreadable,
+		Detail:  fmt.Sprintf("%s %s: %v", directive.name, directive.argument, err),
+		Skipped: true,
+	}
+}
+
+// includedSnippet reads the file a `literalinclude`, `input` or `output` directive points to, and applies the
+// `:lines:`, `:start-after:`, `:end-before:` and `:dedent:` options. The line range is the directive's location on the
+// page, not in the included file, so writers can find the code block.
+func includedSnippet(tree SnippetTree, page string, pageFile string, directive rstDirective, sourceRoot string) (RawSnippet, error) {
+	var includeName string
+	if strings.HasPrefix(directive.argument, "/") {
+		includeName = path.Join(sourceRoot, directive.argument)
+	} else {
+		includeName = path.Join(path.Dir(pageFile), directive.argument)
+	}
+	data, err := tree.ReadFile(includeName)
+	if err != nil {
+		return RawSnippet{}, err
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if lineSpec, exists := directive.options["lines"]; exists {
+		lines, err = selectLines(lines, lineSpec)
+		if err != nil {
+			return RawSnippet{}, err
+		}
+	}
+	if startAfter, exists := directive.options["start-after"]; exists {
+		for i, line := range lines {
+			if strings.Contains(line, startAfter) {
+				lines = lines[i+1:]
+				break
+			}
+		}
+	}
+	if endBefore, exists := directive.options["end-before"]; exists {
+		for i, line := range lines {
+			if strings.Contains(line, endBefore) {
+				lines = lines[:i]
+				break
+			}
+		}
+	}
+	if _, exists := directive.options["dedent"]; exists {
+		lines = dedentLines(lines)
+	}
+	lang := GetLanguageRegistry().LanguageForTag(directive.options["language"])
+	if lang == "" {
+		lang = GetLangFromFilename(includeName)
+	}
+	return RawSnippet{
+		Page:             page,
+		DeclaredLanguage: lang,
+		Contents:         strings.Join(lines, "\n"),
+		StartLine:        directive.startLine,
+		EndLine:          directive.endLine,
+		Caption:          directive.options["caption"],
+		IncludePath:      includeName,
+	}, nil
+}
+
+// selectLines applies a Sphinx-style `:lines:` option, such as `1-3,5,10-`, using 1-based line numbers
+func selectLines(lines []string, lineSpec string) ([]string, error) {
+	var selected []string
+	for _, part := range strings.Split(lineSpec, ",") {
+		part = strings.TrimSpace(part)
+		start, end := part, part
+		if dash := strings.Index(part, "-"); dash != -1 {
+			start, end = part[:dash], part[dash+1:]
+		}
+		startLine, err := strconv.Atoi(strings.TrimSpace(start))
+		if err != nil {
+			return nil, fmt.Errorf("invalid lines option %q", lineSpec)
+		}
+		endLine := len(lines)
+		if strings.TrimSpace(end) != "" {
+			endLine, err = strconv.Atoi(strings.TrimSpace(end))
+			if err != nil {
+				return nil, fmt.Errorf("invalid lines option %q", lineSpec)
+			}
+		}
+		for lineNumber := startLine; lineNumber <= endLine && lineNumber <= len(lines); lineNumber++ {
+			if lineNumber >= 1 {
+				selected = append(selected, lines[lineNumber-1])
+			}
+		}
+	}
+	return selected, nil
+}
+
+// parseRstDirective parses the directive at lines[i], if there is one. The lineOffset converts an index into lines
+// into a line number on the page, for directives nested inside another directive's content. It returns the index of
+// the first line after the directive's block.
+func parseRstDirective(lines []string, i int, lineOffset int) (rstDirective, int, bool) {
+	match := rstDirectivePattern.FindStringSubmatch(lines[i])
+	if match == nil {
+		return rstDirective{}, i + 1, false
+	}
+	indent := len(match[1])
+	directive := rstDirective{
+		name:      match[2],
+		argument:  match[3],
+		options:   make(map[string]string),
+		startLine: i + 1 + lineOffset,
+		endLine:   i + 1 + lineOffset,
+	}
+
+	next := i + 1
+	for next < len(lines) {
+		option := rstOptionPattern.FindStringSubmatch(lines[next])
+		if option == nil || indentation(lines[next]) <= indent {
+			break
+		}
+		directive.options[option[1]] = option[2]
+		directive.endLine = next + 1 + lineOffset
+		next++
+	}
+
+	// The block continues until the first non-blank line that isn't indented past the directive
+	var blockLines []string
+	blockStart := next
+	for next < len(lines) {
+		line := lines[next]
+		if strings.TrimSpace(line) != "" && indentation(line) <= indent {
+			break
+		}
+		blockLines = append(blockLines, line)
+		next++
+	}
+	// Leading and trailing blank lines aren't part of the content
+	first := 0
+	for first < len(blockLines) && strings.TrimSpace(blockLines[first]) == "" {
+		first++
+	}
+	last := len(blockLines)
+	for last > first && strings.TrimSpace(blockLines[last-1]) == "" {
+		last--
+	}
+	if first < last {
+		directive.content = dedentLines(blockLines[first:last])
+		directive.contentStart = blockStart + first + 1 + lineOffset
+		directive.endLine = blockStart + last + lineOffset
+	}
+	return directive, next, true
+}
+
+// ExtractMarkdownSnippets finds the fenced code blocks on a Markdown page. The language is the first word of the info
+// string, and we use a `title="..."` or `caption="..."` attribute in the info string as the caption.
+func ExtractMarkdownSnippets(page string, contents string) []RawSnippet {
+	lines := strings.Split(contents, "\n")
+	var rawSnippets []RawSnippet
+	for i := 0; i < len(lines); i++ {
+		match := markdownFence.FindStringSubmatch(lines[i])
+		if match == nil {
+			continue
+		}
+		fence := match[1]
+		info := match[2]
+		end := i + 1
+		for end < len(lines) {
+			trimmed := strings.TrimSpace(lines[end])
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				break
+			}
+			end++
+		}
+		lang := ""
+		if fields := strings.Fields(strings.Trim(info, "{}")); len(fields) > 0 {
+			lang = GetLanguageRegistry().LanguageForTag(strings.TrimPrefix(fields[0], "."))
+		}
+		caption := ""
+		if title := markdownTitle.FindStringSubmatch(info); title != nil {
+			caption = title[1]
+		}
+		contentLines := []string{}
+		if i+1 < end {
+			contentLines = lines[i+1 : end]
+		}
+		rawSnippets = append(rawSnippets, RawSnippet{
+			Page:             page,
+			DeclaredLanguage: lang,
+			Contents:         strings.Join(dedentLines(contentLines), "\n"),
+			StartLine:        i + 1,
+			EndLine:          min(end+1, len(lines)),
+			Caption:          caption,
+			Directive:        DirectiveFencedBlock,
+		})
+		i = end
+	}
+	return rawSnippets
+}
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// dedentLines removes the indentation that all the non-blank lines share
+func dedentLines(lines []string) []string {
+	common := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if lineIndent := indentation(line); common == -1 || lineIndent < common {
+			common = lineIndent
+		}
+	}
+	if common == -1 {
+		common = 0
+	}
+	dedented := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= common {
+			dedented[i] = line[common:]
+		} else {
+			// Only blank lines can be shorter than the common indentation
+			dedented[i] = ""
+		}
+	}
+	return dedented
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testRstPage = `=============
+Create a Thing
+=============
+
+.. code-block:: sh
+   :caption: Install the tools
+   :copyable: true
+
+   brew install mongosh
+
+.. note::
+
+   You can also run this in Node.js:
+
+   .. code-block:: javascript
+
+      const client = new MongoClient(uri);
+
+.. literalinclude:: /includes/example.py
+   :language: python
+   :start-after: start-insert
+   :end-before: end-insert
+
+.. io-code-block::
+   :caption: Find a document
+
+   .. input::
+      :language: javascript
+
+      db.things.findOne()
+
+   .. output:: /includes/output.json
+      :language: json
+`
+
+func TestExtractRstSnippets(t *testing.T) {
+	sourceRoot := t.TempDir()
+	includes := map[string]string{
+		"includes/example.py":  "import pymongo\n# start-insert\ncollection.insert_one({})\n# end-insert\nclient.close()\n",
+		"includes/output.json": "{ \"_id\": 1 }\n",
+	}
+	for relPath, contents := range includes {
+		fullPath := filepath.Join(sourceRoot, relPath)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("failed to create directory %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte(contents), 0644); err != nil {
+			t.Fatalf("failed to write file %v", err)
+		}
+	}
+	got, diagnostics := ExtractRstSnippets(NewDirTree(sourceRoot), "project/create.txt", "create.txt", testRstPage)
+	if len(diagnostics) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diagnostics)
+	}
+	expected := []RawSnippet{
+		{Page: "project/create.txt", DeclaredLanguage: SHELL, Contents: "brew install mongosh", StartLine: 5, EndLine: 9, Caption: "Install the tools", Directive: DirectiveCodeBlock},
+		{Page: "project/create.txt", DeclaredLanguage: JAVASCRIPT, Contents: "const client = new MongoClient(uri);", StartLine: 15, EndLine: 17, Directive: DirectiveCodeBlock},
+		{Page: "project/create.txt", DeclaredLanguage: PYTHON, Contents: "collection.insert_one({})", StartLine: 19, EndLine: 22, Directive: DirectiveLiteralInclude, IncludePath: "includes/example.py"},
+		{Page: "project/create.txt", DeclaredLanguage: JAVASCRIPT, Contents: "db.things.findOne()", StartLine: 27, EndLine: 30, Caption: "Find a document", Directive: DirectiveIOInput},
+		{Page: "project/create.txt", DeclaredLanguage: JSON, Contents: "{ \"_id\": 1 }", StartLine: 32, EndLine: 33, Caption: "Find a document", Directive: DirectiveIOOutput, IncludePath: "includes/output.json"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %+v\nwant %+v", got, expected)
+	}
+}
+
+func TestExtractRstSnippetsMissingInclude(t *testing.T) {
+	page := ".. literalinclude:: /includes/missing.js\n"
+	got, diagnostics := ExtractRstSnippets(NewDirTree(t.TempDir()), "project/page.txt", "page.txt", page)
+	if len(got) != 0 {
+		t.Errorf("expected no snippets, got %v", got)
+	}
+	if len(diagnostics) != 1 || diagnostics[0].Reason != DiagnosticUnreadable {
+		t.Errorf("expected one unreadable diagnostic, got %v", diagnostics)
+	}
+}
+
+func TestExtractMarkdownSnippets(t *testing.T) {
+	page := "# Title\n\n```go title=\"Connect\"\nclient, err := mongo.Connect(ctx)\n```\n\nSome text.\n\n~~~\nplain text\n~~~\n"
+	got := ExtractMarkdownSnippets("project/page.md", page)
+	expected := []RawSnippet{
+		{Page: "project/page.md", DeclaredLanguage: GO, Contents: "client, err := mongo.Connect(ctx)", StartLine: 3, EndLine: 5, Caption: "Connect", Directive: DirectiveFencedBlock},
+		{Page: "project/page.md", DeclaredLanguage: "", Contents: "plain text", StartLine: 9, EndLine: 11, Directive: DirectiveFencedBlock},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %+v\nwant %+v", got, expected)
+	}
+}
+
+func TestSelectLines(t *testing.T) {
+	lines := []string{"one", "two", "three", "four", "five"}
+	got, err := selectLines(lines, "1,3-4,5-")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := []string{"one", "three", "four", "five"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v want %v", got, expected)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// StrategyHeadTail sends the beginning and end of the snippet, which usually hold the setup and the result
+	StrategyHeadTail = "head-tail"
+	// StrategyStructuralSummary sends the imports, declarations and top-level lines of the snippet, and drops the bodies
+	StrategyStructuralSummary = "structural-summary"
+	// StrategyChunkAndVote asks the LLM about each chunk of the snippet, and picks the category most chunks got
+	StrategyChunkAndVote = "chunk-and-vote"
+)
+
+// These lines carry the shape of a snippet in most of the languages we see, so the structural summary keeps them
+var structuralLinePattern = regexp.MustCompile(`^\s*(import|from|package|using|namespace|require|#include|func|def|fn|class|struct|interface|enum|impl|module|public|private|protected|internal|static|async|function|const|let|var|val|export|pub)\b`)
+
+// SnippetFit records how much of the context a snippet needed, and what we did if it didn't fit
+type SnippetFit struct {
+	Tokens    int
+	Budget    int
+	Strategy  string
+	Truncated bool
+}
+
+// SnippetTokenBudget is the number of tokens left for the snippet after the question, the prompt template and the
+// model's answer
+func SnippetTokenBudget(question string, count TokenCounter) int {
+	budget := ModelContextTokens - count(BuildCategoryPrompt("", question)) - ResponseTokenReserve
+	// Even if someone configures a tiny context, send the model something to categorize
+	return max(budget, 64)
+}
+
+// HeadTailExcerpt keeps as many whole lines from the start and end of the contents as fit in the budget, and replaces
+// the lines in between with a marker. The head gets two thirds of the budget.
+func HeadTailExcerpt(contents string, budget int, count TokenCounter) string {
+	lines := strings.Split(contents, "\n")
+	markerBudget := count(fmt.Sprintf("... %d lines omitted ...\n", len(lines)))
+	headBudget := (budget - markerBudget) * 2 / 3
+	tailBudget := budget - markerBudget - headBudget
+
+	var head []string
+	used := 0
+	for _, line := range lines {
+		lineTokens := count(line + "\n")
+		if used+lineTokens > headBudget {
+			break
+		}
+		head = append(head, line)
+		used += lineTokens
+	}
+	if len(head) == 0 {
+		// The first line alone is too long, which happens with minified JSON
+		head = append(head, TruncateToTokens(lines[0], headBudget, count))
+	}
+
+	var tail []string
+	used = 0
+	for i := len(lines) - 1; i >= len(head); i-- {
+		lineTokens := count(lines[i] + "\n")
+		if used+lineTokens > tailBudget {
+			break
+		}
+		tail = append([]string{lines[i]}, tail...)
+		used += lineTokens
+	}
+	omitted := len(lines) - len(head) - len(tail)
+	if omitted <= 0 {
+		return strings.Join(append(head, tail...), "\n")
+	}
+	return strings.Join(head, "\n") + fmt.Sprintf("\n... %d lines omitted ...\n", omitted) + strings.Join(tail, "\n")
+}
+
+// SummarizeSnippetStructure keeps the lines that show the shape of the snippet: the least indented lines, and the
+// imports and declarations. It collapses each run of lines it drops into a `...` line. If the summary still doesn't
+// fit, we excerpt its head and tail.
+func SummarizeSnippetStructure(contents string, budget int, count TokenCounter) string {
+	lines := strings.Split(contents, "\n")
+	minIndent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if indent := indentation(line); minIndent == -1 || indent < minIndent {
+			minIndent = indent
+		}
+	}
+	var summary []string
+	dropping := false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if indentation(line) == minIndent || structuralLinePattern.MatchString(line) {
+			summary = append(summary, line)
+			dropping = false
+			continue
+		}
+		if !dropping {
+			summary = append(summary, line[:indentation(line)]+"...")
+			dropping = true
+		}
+	}
+	summarized := strings.Join(summary, "\n")
+	if count(summarized) > budget {
+		return HeadTailExcerpt(summarized, budget, count)
+	}
+	return summarized
+}
+
+// ChunkSnippet splits the contents into chunks of whole lines that each fit in the budget. We split lines that don't
+// fit on their own.
+func ChunkSnippet(contents string, budget int, count TokenCounter) []string {
+	var chunks []string
+	var chunk []string
+	used := 0
+	for _, line := range strings.Split(contents, "\n") {
+		lineTokens := count(line + "\n")
+		if used+lineTokens > budget && len(chunk) > 0 {
+			chunks = append(chunks, strings.Join(chunk, "\n"))
+			chunk = nil
+			used = 0
+		}
+		for count(line) > budget {
+			piece := TruncateToTokens(line, budget, count)
+			chunks = append(chunks, piece)
+			line = line[len(piece):]
+			lineTokens = count(line + "\n")
+		}
+		chunk = append(chunk, line)
+		used += lineTokens
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, strings.Join(chunk, "\n"))
+	}
+	return chunks
+}
+
+// SampleChunks picks evenly spaced chunks, always including the first and last, so a long snippet costs at most
+// limit LLM calls
+func SampleChunks(chunks []string, limit int) []string {
+	if len(chunks) <= limit {
+		return chunks
+	}
+	if limit == 1 {
+		return chunks[:1]
+	}
+	sampled := make([]string, 0, limit)
+	for i := 0; i < limit; i++ {
+		sampled = append(sampled, chunks[i*(len(chunks)-1)/(limit-1)])
+	}
+	return sampled
+}
+
+// VoteForCategory returns the category most chunks got. If there's a tie, the category that got its first vote
+// earliest wins, because the start of a snippet usually says the most about it.
+func VoteForCategory(votes []string) string {
+	counts := make(map[string]int)
+	firstVote := make(map[string]int)
+	for i, vote := range votes {
+		if _, exists := counts[vote]; !exists {
+			firstVote[vote] = i
+		}
+		counts[vote]++
+	}
+	categories := make([]string, 0, len(counts))
+	for category := range counts {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if counts[categories[i]] != counts[categories[j]] {
+			return counts[categories[i]] > counts[categories[j]]
+		}
+		return firstVote[categories[i]] < firstVote[categories[j]]
+	})
+	if len(categories) == 0 {
+		return ""
+	}
+	return categories[0]
+}
+
+// VoteForAnswer combines the LLM's answers for the chunks of a snippet into one answer. The chunks vote on their
+// primary labels, and with MultiLabel, the answer lists the winner first, followed by the secondary labels any chunk
+// gave, in the order they came up. An answer that isn't a category votes as it is, so if most chunks don't get a
+// category, the snippet doesn't either.
+func VoteForAnswer(answers []string, taxonomy *Taxonomy) string {
+	var primaryLabels []string
+	var secondaryLabels []string
+	for _, answer := range answers {
+		labels := taxonomy.ParseCategoryAnswer(answer)
+		if len(labels) == 0 {
+			primaryLabels = append(primaryLabels, answer)
+			continue
+		}
+		primaryLabels = append(primaryLabels, labels[0])
+		for _, label := range labels[1:] {
+			if !containsString(secondaryLabels, label) {
+				secondaryLabels = append(secondaryLabels, label)
+			}
+		}
+	}
+	winner := VoteForCategory(primaryLabels)
+	if !taxonomy.MultiLabel || !taxonomy.HasCategory(winner) {
+		return winner
+	}
+	labels := []string{winner}
+	for _, label := range secondaryLabels {
+		if label != winner {
+			labels = append(labels, label)
+		}
+	}
+	return strings.Join(labels, ", ")
+}
+
+// TruncateToTokens returns the longest prefix of the text that fits in the budget, cut at a rune boundary
+func TruncateToTokens(text string, budget int, count TokenCounter) string {
+	runes := []rune(text)
+	low, high := 0, len(runes)
+	for low < high {
+		middle := (low + high + 1) / 2
+		if count(string(runes[:middle])) <= budget {
+			low = middle
+		} else {
+			high = middle - 1
+		}
+	}
+	// Always make progress, even if a single rune is over the budget
+	return string(runes[:max(low, 1)])
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// countWords is a predictable token counter for the tests, where each word is a token
+func countWords(text string) int {
+	return len(strings.Fields(text))
+}
+
+func numberedLines(count int) string {
+	var lines []string
+	for i := 1; i <= count; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestCountTokensLoadsTheBundledEncoding(t *testing.T) {
+	var diagnostics strings.Builder
+	LoadTokenEncoding(&diagnostics)
+	if tokenEncoding == nil {
+		t.Fatalf("expected the bundled %s encoding to load, got %q", TokenizerEncoding, diagnostics.String())
+	}
+	got := CountTokens("hello world")
+	if got != 2 {
+		t.Errorf("got %d want 2", got)
+	}
+}
+
+func TestHeadTailExcerpt(t *testing.T) {
+	// Each line is two tokens and the marker is five, so a budget of 17 leaves 8 tokens for the head and 4 for the tail
+	got := HeadTailExcerpt(numberedLines(20), 17, countWords)
+	expected := "line 1\nline 2\nline 3\nline 4\n... 14 lines omitted ...\nline 19\nline 20"
+	if got != expected {
+		t.Errorf("got %q want %q", got, expected)
+	}
+}
+
+func TestHeadTailExcerptFits(t *testing.T) {
+	contents := numberedLines(3)
+	got := HeadTailExcerpt(contents, 100, countWords)
+	if got != contents {
+		t.Errorf("got %q want %q", got, contents)
+	}
+}
+
+func TestSummarizeSnippetStructure(t *testing.T) {
+	contents := `import pymongo
+
+def find_movies(collection):
+    query = { "year": 1999 }
+    for movie in collection.find(query):
+        print(movie)
+
+client = pymongo.MongoClient()`
+	got := SummarizeSnippetStructure(contents, 100, countWords)
+	expected := `import pymongo
+def find_movies(collection):
+    ...
+client = pymongo.MongoClient()`
+	if got != expected {
+		t.Errorf("got %q want %q", got, expected)
+	}
+}
+
+func TestChunkSnippet(t *testing.T) {
+	got := ChunkSnippet(numberedLines(5), 4, countWords)
+	expected := []string{"line 1\nline 2", "line 3\nline 4", "line 5"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q want %q", got, expected)
+	}
+}
+
+func TestChunkSnippetSplitsLongLines(t *testing.T) {
+	got := ChunkSnippet("a b c d e", 2, countWords)
+	for _, chunk := range got {
+		if countWords(chunk) > 2 {
+			t.Errorf("chunk %q is over the budget", chunk)
+		}
+	}
+	if joined := strings.Join(got, ""); joined != "a b c d e" {
+		t.Errorf("got %q want the chunks to add up to the contents", joined)
+	}
+}
+
+func TestSampleChunks(t *testing.T) {
+	chunks := []string{"0", "1", "2", "3", "4", "5", "6", "7", "8"}
+	got := SampleChunks(chunks, 3)
+	expected := []string{"0", "4", "8"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v want %v", got, expected)
+	}
+}
+
+func TestVoteForCategory(t *testing.T) {
+	got := VoteForCategory([]string{SyntaxExample, UsageExample, UsageExample, SyntaxExample, ExampleReturnObject})
+	// Syntax example and usage example tie, and the first chunk voted for a syntax example
+	if got != SyntaxExample {
+		t.Errorf("got %q want %q", got, SyntaxExample)
+	}
+}
+
+func TestVoteForAnswer(t *testing.T) {
+	taxonomy := DefaultTaxonomy()
+	if got := VoteForAnswer([]string{UsageExample, SyntaxExample, SyntaxExample}, taxonomy); got != SyntaxExample {
+		t.Errorf("got %q want %q", got, SyntaxExample)
+	}
+	if got := VoteForAnswer([]string{"I don't know", "I don't know", UsageExample}, taxonomy); got != "I don't know" {
+		t.Errorf("got %q, the snippet shouldn't get a category most chunks didn't get", got)
+	}
+
+	taxonomy.MultiLabel = true
+	answers := []string{
+		UsageExample + ", " + SyntaxExample,
+		SyntaxExample,
+		UsageExample + ", " + ExampleReturnObject + ", " + SyntaxExample,
+		"Not a category",
+	}
+	expected := UsageExample + ", " + SyntaxExample + ", " + ExampleReturnObject
+	if got := VoteForAnswer(answers, taxonomy); got != expected {
+		t.Errorf("got %q want %q", got, expected)
+	}
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
-	"log"
-	"os"
-	"path/filepath"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
 )
 
 // GetFiles traverses directories recursively from the startDirPath and adds file paths to an array of strings that it
@@ -12,23 +14,148 @@ func GetFiles() []string {
 	// To traverse a different directory on your file system, change the path in `constants.go`
 	// or create a new path variable that isn't relative to the root of this repo
 	fullFilePath := SnippetsStartDirectory + ProjectName
-	startDirPath, _ := filepath.Abs(fullFilePath)
+	files, _ := GetFilesWithOptions(fullFilePath, DefaultDiscoveryOptions())
+	return files
+}
 
-	fileList := make([]string, 0)
-	e := filepath.Walk(startDirPath, func(path string, f os.FileInfo, err error) error {
-		if err != nil {
-			log.Fatalf("failed to traverse the file path: %v", err)
-			return err
-		}
-		if !f.IsDir() {
-			fileList = append(fileList, path)
-		}
-		return nil
+// GetFilesWithOptions discovers the files in a directory on disk, and returns their absolute paths
+func GetFilesWithOptions(startDir string, options DiscoveryOptions) ([]string, []IngestionDiagnostic) {
+	tree := NewDirTree(startDir)
+	names, diagnostics := DiscoverFiles(tree, options)
+	files := make([]string, 0, len(names))
+	for _, name := range names {
+		files = append(files, tree.DisplayPath(name))
+	}
+	return files, diagnostics
+}
+
+// DiscoverFiles applies the include and exclude patterns, .categorizeignore files, and hidden file, symlink, size and
+// binary file policies while it walks the tree, so we never read the contents of a file we're going to skip. It
+// doesn't stop at paths it can't read. Instead, it returns a diagnostic for each path it couldn't read or skipped.
+// The files it returns are paths in the tree.
+func DiscoverFiles(tree SnippetTree, options DiscoveryOptions) ([]string, []IngestionDiagnostic) {
+	walker := fileWalker{
+		options:  options,
+		tree:     tree,
+		visited:  make(map[string]bool),
+		fileList: make([]string, 0),
+	}
+	walker.visit(".", nil)
+	return walker.fileList, walker.diagnostics
+}
+
+type fileWalker struct {
+	options     DiscoveryOptions
+	tree        SnippetTree
+	visited     map[string]bool
+	fileList    []string
+	diagnostics []IngestionDiagnostic
+}
+
+func (w *fileWalker) skip(name string, reason string, detail string) {
+	w.diagnostics = append(w.diagnostics, IngestionDiagnostic{
+		Path:    w.tree.DisplayPath(name),
+		Reason:  reason,
+		Detail:  detail,
+		Skipped: true,
 	})
+}
 
-	if e != nil {
-		log.Fatalf("failed to traverse the file path: %v", e)
+func (w *fileWalker) visit(dirName string, rules []ignoreRule) {
+	realPath, err := w.tree.RealPath(dirName)
+	if err != nil {
+		w.skip(dirName, DiagnosticUnreadable, err.Error())
+		return
+	}
+	// A symlink can point back up the tree, so only visit each real directory once
+	if w.visited[realPath] {
+		return
 	}
+	w.visited[realPath] = true
 
-	return fileList
+	// ReadDir returns the entries it could read along with the error, so we still visit those
+	entries, err := w.tree.ReadDir(dirName)
+	if err != nil {
+		w.skip(dirName, DiagnosticUnreadable, err.Error())
+	}
+	ignoreFileName := path.Join(dirName, IgnoreFileName)
+	ignoreFile, err := w.tree.ReadFile(ignoreFileName)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		w.skip(ignoreFileName, DiagnosticUnreadable, err.Error())
+	}
+	base := dirName
+	if base == "." {
+		base = ""
+	}
+	// Copy the parent rules so sibling directories don't see each other's ignore files
+	rules = append(append([]ignoreRule{}, rules...), ParseIgnoreRules(ignoreFile, base)...)
+
+	for _, entry := range entries {
+		entryName := path.Join(dirName, entry.Name())
+		if entry.Name() == IgnoreFileName {
+			continue
+		}
+		if !w.options.IncludeHidden && strings.HasPrefix(entry.Name(), ".") {
+			w.skip(entryName, DiagnosticSkipped, "hidden path")
+			continue
+		}
+		isDir := entry.IsDir()
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if !w.options.FollowSymlinks {
+				w.skip(entryName, DiagnosticSkipped, "symlink")
+				continue
+			}
+			target, err := w.tree.Stat(entryName)
+			if err != nil {
+				w.skip(entryName, DiagnosticUnreadable, err.Error())
+				continue
+			}
+			isDir = target.IsDir()
+		}
+		if IsIgnored(rules, entryName, isDir) {
+			w.skip(entryName, DiagnosticSkipped, "matches a pattern in "+IgnoreFileName)
+			continue
+		}
+		if MatchesAnyGlob(w.options.ExcludePatterns, entryName) {
+			w.skip(entryName, DiagnosticSkipped, "matches an exclude pattern")
+			continue
+		}
+		if isDir {
+			w.visit(entryName, rules)
+			continue
+		}
+		if len(w.options.IncludePatterns) > 0 && !MatchesAnyGlob(w.options.IncludePatterns, entryName) {
+			w.skip(entryName, DiagnosticSkipped, "doesn't match any include pattern")
+			continue
+		}
+		info, err := w.tree.Stat(entryName)
+		if err != nil {
+			w.skip(entryName, DiagnosticUnreadable, err.Error())
+			continue
+		}
+		if w.options.MaxFileSize > 0 && info.Size() > w.options.MaxFileSize {
+			w.skip(entryName, DiagnosticOversized, fmt.Sprintf("%d bytes is larger than the %d byte limit", info.Size(), w.options.MaxFileSize))
+			continue
+		}
+		if w.options.SkipBinaryFiles {
+			isBinary, err := IsBinaryFile(w.tree, entryName)
+			if err != nil {
+				w.skip(entryName, DiagnosticUnreadable, err.Error())
+				continue
+			}
+			if isBinary {
+				w.skip(entryName, DiagnosticBinary, "contains a NUL byte")
+				continue
+			}
+		}
+		if GetLangFromFilename(entryName) == "" {
+			// We still categorize these, because we may be able to detect the language from the contents
+			w.diagnostics = append(w.diagnostics, IngestionDiagnostic{
+				Path:   w.tree.DisplayPath(entryName),
+				Reason: DiagnosticUnknownExtension,
+				Detail: "no language in the registry matches " + entry.Name(),
+			})
+		}
+		w.fileList = append(w.fileList, entryName)
+	}
 }
@@ -3,6 +3,9 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
 )
@@ -55,3 +58,176 @@ func TestStringArrayDoesNotContainDirectoryPath(t *testing.T) {
 		t.Error("found a directory in the array of paths")
 	}
 }
+
+// writeTestTree creates each of the relative paths under a temp directory, with the given contents
+func writeTestTree(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+	for relPath, contents := range files {
+		fullPath := filepath.Join(root, relPath)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("failed to create directory %v", err)
+		}
+		if err := os.WriteFile(fullPath, []byte(contents), 0644); err != nil {
+			t.Fatalf("failed to write file %v", err)
+		}
+	}
+	return root
+}
+
+// discoverRelativePaths returns the sorted paths GetFilesWithOptions finds, relative to the root
+func discoverRelativePaths(t *testing.T, root string, options DiscoveryOptions) []string {
+	paths, _ := GetFilesWithOptions(root, options)
+	var relPaths []string
+	for _, path := range paths {
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			t.Fatalf("failed to get relative path %v", err)
+		}
+		relPaths = append(relPaths, filepath.ToSlash(relPath))
+	}
+	sort.Strings(relPaths)
+	return relPaths
+}
+
+func TestGetFilesWithOptionsAppliesIgnoreFiles(t *testing.T) {
+	root := writeTestTree(t, map[string]string{
+		"page/example.go":              "package main",
+		"page/example.png":             "not really a png",
+		"page/.categorizeignore":       "*.png\nnested/\n",
+		"page/nested/skipped.go":       "package main",
+		"other/example.png":            "not really a png",
+		"other/keep/.categorizeignore": "# re-include this one\n!important.png\n*.png\n!important.png\n",
+		"other/keep/important.png":     "not really a png",
+		"other/keep/dropped.png":       "not really a png",
+	})
+	got := discoverRelativePaths(t, root, DiscoveryOptions{})
+	expected := []string{"other/example.png", "other/keep/important.png", "page/example.go"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v want %v", got, expected)
+	}
+}
+
+func TestGetFilesWithOptionsIncludeAndExcludePatterns(t *testing.T) {
+	root := writeTestTree(t, map[string]string{
+		"a/example.go":        "package main",
+		"a/example.js":        "console.log(1)",
+		"a/generated/gen.go":  "package gen",
+		"b/deeper/example.go": "package main",
+	})
+	options := DiscoveryOptions{
+		IncludePatterns: []string{"**/*.go"},
+		ExcludePatterns: []string{"generated"},
+	}
+	got := discoverRelativePaths(t, root, options)
+	expected := []string{"a/example.go", "b/deeper/example.go"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v want %v", got, expected)
+	}
+}
+
+func TestGetFilesWithOptionsHiddenAndBinaryFiles(t *testing.T) {
+	root := writeTestTree(t, map[string]string{
+		"example.go":        "package main",
+		".DS_Store":         "hidden",
+		".hidden/secret.go": "package secret",
+		"image.bin":         "binary\x00contents",
+	})
+	got := discoverRelativePaths(t, root, DiscoveryOptions{SkipBinaryFiles: true})
+	expected := []string{"example.go"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v want %v", got, expected)
+	}
+	got = discoverRelativePaths(t, root, DiscoveryOptions{IncludeHidden: true})
+	expected = []string{".DS_Store", ".hidden/secret.go", "example.go", "image.bin"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v want %v", got, expected)
+	}
+}
+
+func TestGetFilesWithOptionsSymlinks(t *testing.T) {
+	root := writeTestTree(t, map[string]string{
+		"real/example.go": "package main",
+	})
+	if err := os.Symlink(filepath.Join(root, "real"), filepath.Join(root, "linked")); err != nil {
+		t.Skipf("symlinks aren't supported here: %v", err)
+	}
+	// A link back up the tree must not loop forever
+	if err := os.Symlink(root, filepath.Join(root, "real", "loop")); err != nil {
+		t.Fatalf("failed to create symlink %v", err)
+	}
+	got := discoverRelativePaths(t, root, DiscoveryOptions{})
+	expected := []string{"real/example.go"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v want %v", got, expected)
+	}
+	got = discoverRelativePaths(t, root, DiscoveryOptions{FollowSymlinks: true})
+	if len(got) != 1 {
+		t.Errorf("got %v want the example file once", got)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.png", "images/diagram.png", true},
+		{"**/*.go", "example.go", true},
+		{"**/*.go", "a/b/example.go", true},
+		{"a/*.go", "a/b/example.go", false},
+		{"/a/**", "a/b/example.go", true},
+		{"a/**/example.go", "a/example.go", true},
+		{".DS_Store", "page/.DS_Store", true},
+		{"[!abc]*.txt", "page/d.txt", true},
+		{"[!abc]*.txt", "page/a.txt", false},
+		{"docs/[!_]*.txt", "docs/index.txt", true},
+		{"docs/[!_]*.txt", "docs/_draft.txt", false},
+		{"docs/[^_]*.txt", "docs/_draft.txt", false},
+		{"docs/?[!/]*.txt", "docs/a/b.txt", false},
+		{`docs/[\]]*.txt`, "docs/]a.txt", true},
+		{`docs/[a\-c]*.txt`, "docs/-.txt", true},
+		{`docs/[a\-c]*.txt`, "docs/b.txt", false},
+		{`docs/\[draft\].txt`, "docs/[draft].txt", true},
+	}
+	for _, c := range cases {
+		got := MatchGlob(c.pattern, c.path)
+		if got != c.want {
+			t.Errorf("MatchGlob(%q, %q) got %v want %v", c.pattern, c.path, got, c.want)
+		}
+	}
+}
+
+func TestGetFilesWithOptionsReturnsDiagnostics(t *testing.T) {
+	root := writeTestTree(t, map[string]string{
+		"example.go":      "package main",
+		"notes.unknown":   "some notes",
+		"large.json":      "{\"a\": \"this file is larger than the limit\"}",
+		"image.bin":       "binary\x00contents",
+		".hidden/file.go": "package hidden",
+	})
+	options := DiscoveryOptions{SkipBinaryFiles: true, MaxFileSize: 20}
+	files, diagnostics := GetFilesWithOptions(filepath.Join(root, "missing"), options)
+	if len(files) != 0 || len(diagnostics) != 1 || diagnostics[0].Reason != DiagnosticUnreadable {
+		t.Errorf("expected a missing start directory to produce one unreadable diagnostic, got %v", diagnostics)
+	}
+
+	files, diagnostics = GetFilesWithOptions(root, options)
+	got := make(map[string]string)
+	for _, diagnostic := range diagnostics {
+		got[filepath.Base(diagnostic.Path)] = diagnostic.Reason
+	}
+	expected := map[string]string{
+		".hidden":       DiagnosticSkipped,
+		"image.bin":     DiagnosticBinary,
+		"large.json":    DiagnosticOversized,
+		"notes.unknown": DiagnosticUnknownExtension,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v want %v", got, expected)
+	}
+	// Files with an unknown extension are reported, but still categorized
+	if len(files) != 2 {
+		t.Errorf("got %v want example.go and notes.unknown", files)
+	}
+}
@@ -4,48 +4,40 @@ const (
 	C                = "c"
 	CPP              = "cpp"
 	CSHARP           = "csharp"
+	DOCKERFILE       = "dockerfile"
 	GO               = "go"
+	INI              = "ini"
 	JAVA             = "java"
 	JAVASCRIPT       = "javascript"
 	JSON             = "json"
 	KOTLIN           = "kotlin"
+	MARKDOWN         = "markdown"
 	PHP              = "php"
+	POWERSHELL       = "powershell"
 	PYTHON           = "python"
+	RST              = "rst"
 	RUBY             = "ruby"
 	RUST             = "rust"
 	SCALA            = "scala"
 	SHELL            = "shell"
+	SQL              = "sql"
 	SWIFT            = "swift"
 	TEXT             = "text"
+	TOML             = "toml"
 	TYPESCRIPT       = "typescript"
 	XML              = "xml"
 	YAML             = "yaml"
+	UNKNOWN          = "unknown"
 	DRIVERS_MINUS_JS = "drivers_minus_js"
 	JSON_LIKE        = "json_like"
 )
 
 func GetLangFromExtension(ext string) string {
-	extensionLangMap := map[string]string{
-		".c":     C,
-		".cpp":   CPP,
-		".cs":    CSHARP,
-		".go":    GO,
-		".java":  JAVA,
-		".js":    JAVASCRIPT,
-		".json":  JSON,
-		".kt":    KOTLIN,
-		".php":   PHP,
-		".py":    PYTHON,
-		".rb":    RUBY,
-		".rs":    RUST,
-		".scala": SCALA,
-		".sh":    SHELL,
-		".swift": SWIFT,
-		".txt":   TEXT,
-		".ts":    TYPESCRIPT,
-		".xml":   XML,
-		".yaml":  YAML,
-	}
+	return GetLanguageRegistry().LanguageForExtension(ext)
+}
 
-	return extensionLangMap[ext]
+// GetLangFromFilename uses the whole file name rather than only the final extension, so it can recognize compound
+// extensions and filename patterns from the language registry
+func GetLangFromFilename(path string) string {
+	return GetLanguageRegistry().LanguageForFile(path)
 }
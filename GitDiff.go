@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	FileAdded    = "added"
+	FileModified = "modified"
+	FileRemoved  = "removed"
+)
+
+// ChangedFile is a file that differs between two commits. The Path is relative to the root directory we diffed.
+type ChangedFile struct {
+	Path   string
+	Status string
+}
+
+// DiffGitRefs lists the files under the root directory that were added, modified or removed between the base and head
+// refs. We turn off rename detection, so a renamed file shows up as a removed file and an added file, which is how the
+// snippet reports see it anyway.
+func DiffGitRefs(repoDir string, baseRef string, headRef string, root string) ([]ChangedFile, error) {
+	baseCommit, err := ResolveGitCommit(repoDir, baseRef)
+	if err != nil {
+		return nil, err
+	}
+	headCommit, err := ResolveGitCommit(repoDir, headRef)
+	if err != nil {
+		return nil, err
+	}
+	root = strings.Trim(path.Clean("/"+root), "/")
+	args := []string{"diff", "--name-status", "--no-renames", "-z", baseCommit, headCommit}
+	if root != "" {
+		args = append(args, "--", root)
+	}
+	output, err := runGit(repoDir, args...)
+	if err != nil {
+		return nil, err
+	}
+	return ParseNameStatus(output, root)
+}
+
+// ParseNameStatus parses the output of `git diff --name-status -z`, which alternates between a status letter and a
+// path, each terminated by a NUL byte
+func ParseNameStatus(output string, root string) ([]ChangedFile, error) {
+	fields := strings.Split(strings.TrimRight(output, "\x00"), "\x00")
+	var changes []ChangedFile
+	for i := 0; i+1 < len(fields); i += 2 {
+		var status string
+		switch fields[i][0] {
+		case 'A':
+			status = FileAdded
+		case 'M', 'T':
+			status = FileModified
+		case 'D':
+			status = FileRemoved
+		default:
+			return nil, fmt.Errorf("unexpected git diff status %q for %s", fields[i], fields[i+1])
+		}
+		name := fields[i+1]
+		if root != "" {
+			name = strings.TrimPrefix(name, root+"/")
+		}
+		changes = append(changes, ChangedFile{Path: name, Status: status})
+	}
+	return changes, nil
+}
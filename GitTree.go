@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitTree is a SnippetTree that reads a directory in a git commit straight from the object database, without checking
+// the commit out. We list the tree once with `git ls-tree`, and read blobs through a long-running `git cat-file --batch`
+// process, so reading thousands of snippets doesn't start thousands of processes.
+type GitTree struct {
+	RepoDir string
+	Ref     string
+	Commit  string
+	// Root is the directory in the repository that the tree starts at, or "" for the root of the repository
+	Root string
+
+	entries  map[string]gitEntry
+	children map[string][]string
+	catFile  *gitCatFile
+}
+
+type gitEntry struct {
+	objectName string
+	size       int64
+	isDir      bool
+	isSymlink  bool
+}
+
+const maxSymlinkHops = 40
+
+// NewGitTree resolves the ref to a commit and lists the files under the root directory in that commit
+func NewGitTree(repoDir string, ref string, root string) (*GitTree, error) {
+	commit, err := ResolveGitCommit(repoDir, ref)
+	if err != nil {
+		return nil, err
+	}
+	root = strings.Trim(path.Clean("/"+root), "/")
+	tree := &GitTree{
+		RepoDir:  repoDir,
+		Ref:      ref,
+		Commit:   commit,
+		Root:     root,
+		entries:  map[string]gitEntry{".": {isDir: true}},
+		children: make(map[string][]string),
+	}
+	args := []string{"ls-tree", "-r", "-t", "-l", "-z", commit}
+	if root != "" {
+		args = append(args, "--", root)
+	}
+	output, err := runGit(repoDir, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range strings.Split(strings.TrimRight(output, "\x00"), "\x00") {
+		if record == "" {
+			continue
+		}
+		// Each record looks like `<mode> <type> <object> <size>\t<path>`
+		tab := strings.Index(record, "\t")
+		fields := strings.Fields(record[:tab])
+		repoPath := record[tab+1:]
+		if len(fields) != 4 || fields[1] == "commit" {
+			// Submodules point at commits in other repositories, so there's nothing here for us to read
+			continue
+		}
+		name := repoPath
+		if root != "" {
+			// With -t, ls-tree also lists the directories above a nested root, which aren't in the tree
+			if !strings.HasPrefix(repoPath, root+"/") {
+				continue
+			}
+			name = strings.TrimPrefix(repoPath, root+"/")
+		}
+		size, _ := strconv.ParseInt(fields[3], 10, 64)
+		tree.entries[name] = gitEntry{
+			objectName: fields[2],
+			size:       size,
+			isDir:      fields[1] == "tree",
+			isSymlink:  fields[0] == "120000",
+		}
+		parent := path.Dir(name)
+		tree.children[parent] = append(tree.children[parent], path.Base(name))
+	}
+	if root != "" && len(tree.entries) == 1 {
+		return nil, fmt.Errorf("%s doesn't exist in %s (%s)", root, ref, commit)
+	}
+	for _, names := range tree.children {
+		sort.Strings(names)
+	}
+	return tree, nil
+}
+
+// ResolveGitCommit returns the full SHA of the commit a branch, tag or SHA points to
+func ResolveGitCommit(repoDir string, ref string) (string, error) {
+	output, err := runGit(repoDir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("%q isn't a commit, branch or tag in %s: %v", ref, repoDir, err)
+	}
+	return strings.TrimSpace(output), nil
+}
+
+func runGit(repoDir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", repoDir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return string(output), nil
+}
+
+// resolve follows symlinks in every element of the path, so a path under a symlinked directory leads to the entry in
+// the directory the symlink points to
+func (t *GitTree) resolve(name string, hops int) (string, gitEntry, error) {
+	if !fs.ValidPath(name) {
+		return "", gitEntry{}, fs.ErrInvalid
+	}
+	if name == "." {
+		return ".", t.entries["."], nil
+	}
+	resolved := "."
+	elements := strings.Split(name, "/")
+	for i, element := range elements {
+		current := path.Join(resolved, element)
+		entry, exists := t.entries[current]
+		if !exists {
+			return "", gitEntry{}, fs.ErrNotExist
+		}
+		if entry.isSymlink {
+			if hops >= maxSymlinkHops {
+				return "", gitEntry{}, errors.New("too many levels of symbolic links")
+			}
+			target, err := t.readObject(entry.objectName)
+			if err != nil {
+				return "", gitEntry{}, err
+			}
+			// Symlink targets are relative to the directory containing the link. Targets outside the tree don't exist.
+			targetName := path.Join(path.Dir(current), string(target))
+			if strings.HasPrefix(targetName, "../") || targetName == ".." || path.IsAbs(string(target)) {
+				return "", gitEntry{}, fs.ErrNotExist
+			}
+			current, entry, err = t.resolve(targetName, hops+1)
+			if err != nil {
+				return "", gitEntry{}, err
+			}
+		}
+		resolved = current
+		if i == len(elements)-1 {
+			return resolved, entry, nil
+		}
+	}
+	return "", gitEntry{}, fs.ErrNotExist
+}
+
+func (t *GitTree) Open(name string) (fs.File, error) {
+	resolved, entry, err := t.resolve(name, 0)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	info := gitFileInfo{name: path.Base(name), entry: entry}
+	if entry.isDir {
+		return &gitDir{info: info, tree: t, name: resolved}, nil
+	}
+	data, err := t.readObject(entry.objectName)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &gitFile{Reader: bytes.NewReader(data), info: info}, nil
+}
+
+func (t *GitTree) ReadFile(name string) ([]byte, error) {
+	_, entry, err := t.resolve(name, 0)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+	if entry.isDir {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	return t.readObject(entry.objectName)
+}
+
+// ReadDir lists the entries in a directory without following symlinks in the entries themselves, like os.ReadDir
+func (t *GitTree) ReadDir(name string) ([]fs.DirEntry, error) {
+	resolved, entry, err := t.resolve(name, 0)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	if !entry.isDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	var dirEntries []fs.DirEntry
+	for _, child := range t.children[resolved] {
+		childEntry := t.entries[path.Join(resolved, child)]
+		dirEntries = append(dirEntries, fs.FileInfoToDirEntry(gitFileInfo{name: child, entry: childEntry}))
+	}
+	return dirEntries, nil
+}
+
+func (t *GitTree) Stat(name string) (fs.FileInfo, error) {
+	_, entry, err := t.resolve(name, 0)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return gitFileInfo{name: path.Base(name), entry: entry}, nil
+}
+
+func (t *GitTree) DisplayPath(name string) string {
+	return t.Commit[:12] + ":" + path.Join(t.Root, name)
+}
+
+func (t *GitTree) RealPath(name string) (string, error) {
+	resolved, _, err := t.resolve(name, 0)
+	return resolved, err
+}
+
+// Close stops the `git cat-file` process, if we started one
+func (t *GitTree) Close() error {
+	if t.catFile == nil {
+		return nil
+	}
+	return t.catFile.close()
+}
+
+func (t *GitTree) readObject(objectName string) ([]byte, error) {
+	if t.catFile == nil {
+		catFile, err := startGitCatFile(t.RepoDir)
+		if err != nil {
+			return nil, err
+		}
+		t.catFile = catFile
+	}
+	return t.catFile.read(objectName)
+}
+
+// gitCatFile talks to a `git cat-file --batch` process, which reads object names from stdin and writes a header line
+// followed by the object contents to stdout
+type gitCatFile struct {
+	mutex  sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+func startGitCatFile(repoDir string) (*gitCatFile, error) {
+	cmd := exec.Command("git", "-C", repoDir, "cat-file", "--batch")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &gitCatFile{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout)}, nil
+}
+
+func (c *gitCatFile) read(objectName string) ([]byte, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, err := fmt.Fprintln(c.stdin, objectName); err != nil {
+		return nil, err
+	}
+	header, err := c.stdout.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	// The header is `<object> <type> <size>`, or `<object> missing`
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("failed to read git object %s: %s", objectName, strings.TrimSpace(header))
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, err
+	}
+	// The contents are followed by a newline that isn't part of the object
+	data := make([]byte, size+1)
+	if _, err := io.ReadFull(c.stdout, data); err != nil {
+		return nil, err
+	}
+	return data[:size], nil
+}
+
+func (c *gitCatFile) close() error {
+	c.stdin.Close()
+	return c.cmd.Wait()
+}
+
+type gitFileInfo struct {
+	name  string
+	entry gitEntry
+}
+
+func (i gitFileInfo) Name() string       { return i.name }
+func (i gitFileInfo) Size() int64        { return i.entry.size }
+func (i gitFileInfo) ModTime() time.Time { return time.Time{} }
+func (i gitFileInfo) IsDir() bool        { return i.entry.isDir }
+func (i gitFileInfo) Sys() any           { return nil }
+func (i gitFileInfo) Mode() fs.FileMode {
+	switch {
+	case i.entry.isDir:
+		return fs.ModeDir | 0755
+	case i.entry.isSymlink:
+		return fs.ModeSymlink | 0777
+	default:
+		return 0644
+	}
+}
+
+type gitFile struct {
+	*bytes.Reader
+	info gitFileInfo
+}
+
+func (f *gitFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *gitFile) Close() error               { return nil }
+
+type gitDir struct {
+	info gitFileInfo
+	tree *GitTree
+	name string
+}
+
+func (d *gitDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *gitDir) Close() error               { return nil }
+func (d *gitDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+func (d *gitDir) ReadDir(int) ([]fs.DirEntry, error) { return d.tree.ReadDir(d.name) }
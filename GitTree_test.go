@@ -0,0 +1,161 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// initTestRepo creates a git repository with one commit containing the files, and returns the repository path
+func initTestRepo(t *testing.T, files map[string]string) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	repo := writeTestTree(t, files)
+	runTestGit(t, repo, "init", "-q")
+	runTestGit(t, repo, "add", "-A")
+	runTestGit(t, repo, "commit", "-q", "-m", "first")
+	return repo
+}
+
+func runTestGit(t *testing.T, repo string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v %s", args, err, output)
+	}
+	return string(output)
+}
+
+func TestGitTreeReadsCommittedFiles(t *testing.T) {
+	repo := initTestRepo(t, map[string]string{
+		"docs/page/example.go": "package main",
+		"docs/page/.hidden.go": "package hidden",
+		"docs/other/run.sh":    "atlas list",
+		"README.md":            "# Readme",
+	})
+	runTestGit(t, repo, "tag", "v1")
+	// Changes after the tag must not show up when we read the tag
+	if err := os.WriteFile(filepath.Join(repo, "docs/page/example.go"), []byte("package changed"), 0644); err != nil {
+		t.Fatalf("failed to write file %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "docs/page/new.go"), []byte("package new"), 0644); err != nil {
+		t.Fatalf("failed to write file %v", err)
+	}
+	runTestGit(t, repo, "add", "-A")
+	runTestGit(t, repo, "commit", "-q", "-m", "second")
+
+	tree, err := NewGitTree(repo, "v1", "docs")
+	if err != nil {
+		t.Fatalf("failed to open git tree %v", err)
+	}
+	defer tree.Close()
+	files, _ := DiscoverFiles(tree, DiscoveryOptions{})
+	expected := []string{"other/run.sh", "page/example.go"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("got %v want %v", files, expected)
+	}
+	contents, err := tree.ReadFile("page/example.go")
+	if err != nil {
+		t.Fatalf("failed to read file %v", err)
+	}
+	if string(contents) != "package main" {
+		t.Errorf("got %q want %q", contents, "package main")
+	}
+	if len(tree.Commit) != 40 {
+		t.Errorf("expected a full commit SHA, got %q", tree.Commit)
+	}
+}
+
+func TestGitTreeNestedRoot(t *testing.T) {
+	repo := initTestRepo(t, map[string]string{
+		"content/docs/page/example.go": "package main",
+		"content/other.go":             "package other",
+	})
+	tree, err := NewGitTree(repo, "HEAD", "content/docs")
+	if err != nil {
+		t.Fatalf("failed to open git tree %v", err)
+	}
+	defer tree.Close()
+	entries, err := tree.ReadDir(".")
+	if err != nil {
+		t.Fatalf("failed to read the root %v", err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	expected := []string{"page"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v want %v, the directories above the root shouldn't be in the tree", got, expected)
+	}
+}
+
+func TestGitTreeFollowsSymlinks(t *testing.T) {
+	repo := initTestRepo(t, map[string]string{
+		"real/example.go": "package main",
+	})
+	if err := os.Symlink("real", filepath.Join(repo, "linked")); err != nil {
+		t.Skipf("symlinks aren't supported here: %v", err)
+	}
+	runTestGit(t, repo, "add", "-A")
+	runTestGit(t, repo, "commit", "-q", "-m", "add a symlink")
+
+	tree, err := NewGitTree(repo, "HEAD", "")
+	if err != nil {
+		t.Fatalf("failed to open git tree %v", err)
+	}
+	defer tree.Close()
+	contents, err := tree.ReadFile("linked/example.go")
+	if err != nil || string(contents) != "package main" {
+		t.Errorf("got %q, %v want the contents of real/example.go", contents, err)
+	}
+	files, _ := DiscoverFiles(tree, DiscoveryOptions{FollowSymlinks: true})
+	if len(files) != 1 {
+		t.Errorf("got %v want the example file once", files)
+	}
+}
+
+func TestNewGitTreeUnknownRef(t *testing.T) {
+	repo := initTestRepo(t, map[string]string{"example.go": "package main"})
+	if _, err := NewGitTree(repo, "does-not-exist", ""); err == nil {
+		t.Errorf("expected an error for an unknown ref")
+	}
+}
+
+func TestDiffGitRefs(t *testing.T) {
+	repo := initTestRepo(t, map[string]string{
+		"source/changed.go": "package main",
+		"source/removed.go": "package removed",
+		"source/same.go":    "package same",
+		"outside.go":        "package outside",
+	})
+	runTestGit(t, repo, "tag", "base")
+	if err := os.WriteFile(filepath.Join(repo, "source/changed.go"), []byte("package changed"), 0644); err != nil {
+		t.Fatalf("failed to write file %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "source/added.go"), []byte("package added"), 0644); err != nil {
+		t.Fatalf("failed to write file %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "outside.go"), []byte("package changed"), 0644); err != nil {
+		t.Fatalf("failed to write file %v", err)
+	}
+	runTestGit(t, repo, "rm", "-q", "source/removed.go")
+	runTestGit(t, repo, "add", "-A")
+	runTestGit(t, repo, "commit", "-q", "-m", "second")
+
+	got, err := DiffGitRefs(repo, "base", "HEAD", "source")
+	if err != nil {
+		t.Fatalf("failed to diff %v", err)
+	}
+	expected := []ChangedFile{
+		{Path: "added.go", Status: FileAdded},
+		{Path: "changed.go", Status: FileModified},
+		{Path: "removed.go", Status: FileRemoved},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v want %v", got, expected)
+	}
+}
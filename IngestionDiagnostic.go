@@ -0,0 +1,43 @@
+package main
+
+const (
+	DiagnosticUnreadable       = "unreadable"
+	DiagnosticEmpty            = "empty"
+	DiagnosticBinary           = "binary"
+	DiagnosticOversized        = "oversized"
+	DiagnosticUnknownExtension = "unknown-extension"
+	DiagnosticSkipped          = "skipped"
+)
+
+// IngestionDiagnostic records a file we couldn't read, chose to skip, or categorized with a caveat. Skipped is false
+// for files we still categorized, such as a file with an unknown extension whose language we detected from its
+// contents.
+type IngestionDiagnostic struct {
+	Path    string `json:"path"`
+	Reason  string `json:"reason"`
+	Detail  string `json:"detail,omitempty"`
+	Skipped bool   `json:"skipped"`
+}
+
+type IngestionReport struct {
+	ReportHeader
+	TotalDiagnostics int                   `json:"total_diagnostics"`
+	ReasonCounts     map[string]int        `json:"reason_counts"`
+	Diagnostics      []IngestionDiagnostic `json:"diagnostics"`
+}
+
+func NewIngestionReport(diagnostics []IngestionDiagnostic, metadata *RunMetadata) IngestionReport {
+	reasonCounts := make(map[string]int)
+	for _, diagnostic := range diagnostics {
+		reasonCounts[diagnostic.Reason]++
+	}
+	if diagnostics == nil {
+		diagnostics = []IngestionDiagnostic{}
+	}
+	return IngestionReport{
+		ReportHeader:     NewReportHeader(metadata),
+		TotalDiagnostics: len(diagnostics),
+		ReasonCounts:     reasonCounts,
+		Diagnostics:      diagnostics,
+	}
+}
@@ -0,0 +1,637 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Job is a categorization run that we queue, run in the background, and keep a record of. Each job is a JSON file in
+// the jobs directory, so the queue and the job history survive restarts.
+type Job struct {
+	ID          string      `json:"id"`
+	Status      string      `json:"status"`
+	Options     RunOptions  `json:"options"`
+	SubmittedAt time.Time   `json:"submitted_at"`
+	StartedAt   *time.Time  `json:"started_at,omitempty"`
+	FinishedAt  *time.Time  `json:"finished_at,omitempty"`
+	Attempts    int         `json:"attempts"`
+	Progress    JobProgress `json:"progress"`
+	// Worker is the worker process running the job, and HeartbeatAt is when it last showed it's still running it
+	Worker      string     `json:"worker,omitempty"`
+	HeartbeatAt *time.Time `json:"heartbeat_at,omitempty"`
+	// RunDir is the run directory with the job's reports, once the run finishes
+	RunDir string `json:"run_dir,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// JobProgress is how many of the run's snippets we've categorized so far
+type JobProgress struct {
+	Processed int `json:"processed"`
+	Total     int `json:"total"`
+}
+
+// OwnedBy reports whether the job is still the worker's attempt at running it. Once another worker requeues the job,
+// or claims it again, the earlier attempt doesn't own it anymore.
+func (job Job) OwnedBy(worker string, attempt int) bool {
+	return job.Status == JobRunning && job.Worker == worker && job.Attempts == attempt
+}
+
+func (p JobProgress) String() string {
+	if p.Total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d/%d (%.0f%%)", p.Processed, p.Total, float64(p.Processed)/float64(p.Total)*100)
+}
+
+// JobStore reads and writes the job files in a directory. It serializes changes from the workers in every process with
+// a lock on a file in the directory, and writes each file atomically, so a reader never sees a partial job.
+type JobStore struct {
+	Dir string
+	// WorkerID names this process in the jobs it claims
+	WorkerID string
+	mutex    sync.Mutex
+}
+
+func NewJobStore(dir string) *JobStore {
+	hostname, _ := os.Hostname()
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+	return &JobStore{Dir: dir, WorkerID: fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(suffix))}
+}
+
+// lock takes an advisory lock on the jobs directory's lock file, and returns the function that releases it. The
+// operating system releases the lock when the process that holds it stops, however it stops, so a lock is never stale.
+func (s *JobStore) lock() (func(), error) {
+	s.mutex.Lock()
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		s.mutex.Unlock()
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(s.Dir, ".lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		s.mutex.Unlock()
+		return nil, err
+	}
+	for {
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+		if !errors.Is(err, syscall.EINTR) {
+			break
+		}
+	}
+	if err != nil {
+		file.Close()
+		s.mutex.Unlock()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+		s.mutex.Unlock()
+	}, nil
+}
+
+func (s *JobStore) jobPath(id string) string {
+	return filepath.Join(s.Dir, id+".json")
+}
+
+// LogPath is the file the job's run writes its output to
+func (s *JobStore) LogPath(id string) string {
+	return filepath.Join(s.Dir, id+".log")
+}
+
+// NewJobID names a job for the time it was submitted, with a random suffix so two jobs submitted in the same second
+// get different IDs
+func NewJobID(submittedAt time.Time) (string, error) {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return submittedAt.UTC().Format(RunDirectoryTimeFormat) + "-" + hex.EncodeToString(suffix), nil
+}
+
+// Submit queues a run with the options
+func (s *JobStore) Submit(options RunOptions, submittedAt time.Time) (Job, error) {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return Job{}, err
+	}
+	id, err := NewJobID(submittedAt)
+	if err != nil {
+		return Job{}, err
+	}
+	job := Job{ID: id, Status: JobQueued, Options: options, SubmittedAt: submittedAt.UTC()}
+	unlock, err := s.lock()
+	if err != nil {
+		return Job{}, err
+	}
+	defer unlock()
+	return job, s.save(job)
+}
+
+func (s *JobStore) Load(id string) (Job, error) {
+	var job Job
+	data, err := os.ReadFile(s.jobPath(id))
+	if err != nil {
+		return job, err
+	}
+	if err := json.Unmarshal(data, &job); err != nil {
+		return job, fmt.Errorf("%s: %v", s.jobPath(id), err)
+	}
+	return job, nil
+}
+
+func (s *JobStore) save(job Job) error {
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomically(s.jobPath(job.ID), data)
+}
+
+// Update reads the job, changes it, and writes it back
+func (s *JobStore) Update(id string, change func(job *Job)) (Job, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return Job{}, err
+	}
+	defer unlock()
+	job, err := s.Load(id)
+	if err != nil {
+		return job, err
+	}
+	change(&job)
+	return job, s.save(job)
+}
+
+// UpdateClaim changes the job like Update, but only while the worker's attempt owns it. It returns false, and leaves
+// the job alone, if the job was requeued or claimed again since the attempt started.
+func (s *JobStore) UpdateClaim(id string, worker string, attempt int, change func(job *Job)) (Job, bool, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return Job{}, false, err
+	}
+	defer unlock()
+	job, err := s.Load(id)
+	if err != nil || !job.OwnedBy(worker, attempt) {
+		return job, false, err
+	}
+	change(&job)
+	return job, true, s.save(job)
+}
+
+// List reads every job, oldest first. A store with no jobs directory yet has no jobs.
+func (s *JobStore) List() ([]Job, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var jobs []Job
+	for _, entry := range entries {
+		// The dot files are WriteFileAtomically's temporary files
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" || strings.HasPrefix(name, ".") {
+			continue
+		}
+		job, err := s.Load(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].SubmittedAt.Equal(jobs[j].SubmittedAt) {
+			return jobs[i].SubmittedAt.Before(jobs[j].SubmittedAt)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+	return jobs, nil
+}
+
+// ClaimNext marks the oldest queued job as running in this worker and returns it, or returns false if no job is queued
+func (s *JobStore) ClaimNext(startedAt time.Time) (Job, bool, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return Job{}, false, err
+	}
+	defer unlock()
+	jobs, err := s.List()
+	if err != nil {
+		return Job{}, false, err
+	}
+	for _, job := range jobs {
+		if job.Status != JobQueued {
+			continue
+		}
+		started := startedAt.UTC()
+		job.Status = JobRunning
+		job.StartedAt = &started
+		job.Worker = s.WorkerID
+		job.HeartbeatAt = &started
+		job.Attempts++
+		job.Progress = JobProgress{}
+		job.Error = ""
+		return job, true, s.save(job)
+	}
+	return Job{}, false, nil
+}
+
+// Heartbeat records that this worker is still running its attempt at the job. It leaves a job that another worker took
+// over alone.
+func (s *JobStore) Heartbeat(id string, attempt int, at time.Time) error {
+	_, _, err := s.UpdateClaim(id, s.WorkerID, attempt, func(job *Job) {
+		heartbeatAt := at.UTC()
+		job.HeartbeatAt = &heartbeatAt
+	})
+	return err
+}
+
+// RequeueAbandoned puts the running jobs whose worker stopped back in the queue, so they run again from the start. A
+// worker that hasn't written a heartbeat for JobHeartbeatTimeoutSeconds has stopped, so we leave the jobs that other
+// live workers are running alone.
+func (s *JobStore) RequeueAbandoned(now time.Time) ([]Job, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	jobs, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var requeued []Job
+	for _, job := range jobs {
+		if job.Status != JobRunning {
+			continue
+		}
+		if job.HeartbeatAt != nil && now.Sub(*job.HeartbeatAt) < JobHeartbeatTimeoutSeconds*time.Second {
+			continue
+		}
+		requeueJob(&job)
+		if err := s.save(job); err != nil {
+			return requeued, err
+		}
+		requeued = append(requeued, job)
+	}
+	return requeued, nil
+}
+
+// requeueJob puts a running job back in the queue, so a worker runs it again from the start
+func requeueJob(job *Job) {
+	job.Status = JobQueued
+	job.StartedAt = nil
+	job.Worker = ""
+	job.HeartbeatAt = nil
+	job.Progress = JobProgress{}
+}
+
+// AttachRunToJob makes a run record its progress and its run directory in the job the worker started it for. We
+// don't stop the run if we can't update the job, since the reports are what matter. A run whose worker stopped can
+// outlive it, so we only update the job while the worker's attempt still owns it, and a run that finds another attempt
+// owns its job stops, so it doesn't make its reports the latest run.
+func AttachRunToJob(store *JobStore, options *RunOptions) {
+	id, worker, attempt := options.JobID, options.JobWorker, options.JobAttempt
+	var lastWrite time.Time
+	options.OnProgress = func(processed int, total int) {
+		if processed != 0 && processed != total && time.Since(lastWrite) < JobProgressSeconds*time.Second {
+			return
+		}
+		lastWrite = time.Now()
+		_, owned, err := store.UpdateClaim(id, worker, attempt, func(job *Job) {
+			job.Progress = JobProgress{Processed: processed, Total: total}
+		})
+		if err != nil {
+			fmt.Println("Error recording the progress of job", id, err)
+		} else if !owned {
+			log.Fatalf("job %s was requeued since attempt %d started, so we stopped the run", id, attempt)
+		}
+	}
+	options.OnFinish = func(runDir string, snippetCount int) {
+		if absolutePath, err := filepath.Abs(runDir); err == nil {
+			runDir = absolutePath
+		}
+		_, owned, err := store.UpdateClaim(id, worker, attempt, func(job *Job) {
+			job.RunDir = runDir
+			job.Progress.Processed = snippetCount
+		})
+		if err != nil {
+			fmt.Println("Error recording the run directory of job", id, err)
+		} else if !owned {
+			fmt.Printf("Job %s was requeued since attempt %d started, so we didn't record the run directory\n", id, attempt)
+		}
+	}
+}
+
+// JobWorker runs queued jobs. Execute runs one job to completion, and returns an error if the run failed. It stops
+// the run if the context is cancelled.
+type JobWorker struct {
+	Store   *JobStore
+	Execute func(ctx context.Context, job Job) error
+}
+
+// NewJobWorker runs each job as a separate process of this program, so a run that fails with log.Fatalf fails its
+// job instead of stopping the worker
+func NewJobWorker(store *JobStore) JobWorker {
+	return JobWorker{Store: store, Execute: func(ctx context.Context, job Job) error { return ExecuteJobRun(ctx, store, job) }}
+}
+
+// RunNext claims the oldest queued job and runs it. It returns false if no job is queued. If the context is cancelled
+// because the worker is stopping, it puts the job back in the queue.
+func (w JobWorker) RunNext(ctx context.Context) (bool, error) {
+	job, claimed, err := w.Store.ClaimNext(time.Now())
+	if err != nil || !claimed {
+		return false, err
+	}
+	fmt.Printf("Running job %s for %s\n", job.ID, job.Options.ProjectName)
+	stopHeartbeat := make(chan struct{})
+	heartbeatStopped := make(chan struct{})
+	go func() {
+		defer close(heartbeatStopped)
+		ticker := time.NewTicker(JobHeartbeatSeconds * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopHeartbeat:
+				return
+			case now := <-ticker.C:
+				if err := w.Store.Heartbeat(job.ID, job.Attempts, now); err != nil {
+					fmt.Println("Error recording the heartbeat of job", job.ID, err)
+				}
+			}
+		}
+	}()
+	runErr := w.Execute(ctx, job)
+	close(stopHeartbeat)
+	<-heartbeatStopped
+	// If another worker requeued the job when our heartbeat stopped, its attempt's result is the one to keep
+	job, owned, err := w.Store.UpdateClaim(job.ID, w.Store.WorkerID, job.Attempts, func(job *Job) {
+		if runErr != nil && ctx.Err() != nil {
+			requeueJob(job)
+			return
+		}
+		finished := time.Now().UTC()
+		job.FinishedAt = &finished
+		if runErr != nil {
+			job.Status = JobFailed
+			job.Error = runErr.Error()
+		} else {
+			job.Status = JobSucceeded
+		}
+	})
+	if err != nil {
+		return true, err
+	}
+	if !owned {
+		fmt.Printf("Job %s was requeued while this worker ran it, so we didn't record the result\n", job.ID)
+		return true, nil
+	}
+	fmt.Printf("Job %s %s\n", job.ID, job.Status)
+	return true, nil
+}
+
+// RequeueAbandoned requeues the jobs of the workers that stopped
+func (w JobWorker) RequeueAbandoned() {
+	requeued, err := w.Store.RequeueAbandoned(time.Now())
+	if err != nil {
+		fmt.Println("Error requeuing the abandoned jobs:", err)
+	}
+	for _, job := range requeued {
+		fmt.Printf("Requeued job %s, since the worker that was running it stopped\n", job.ID)
+	}
+}
+
+// Run starts the workers, which each run one job at a time until the context is cancelled. While they're idle, they
+// requeue the jobs of the workers that stopped.
+func (w JobWorker) Run(ctx context.Context, workers int) {
+	var wait sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for ctx.Err() == nil {
+				ran, err := w.RunNext(ctx)
+				if err != nil {
+					fmt.Println("Error running a job:", err)
+				}
+				if !ran {
+					w.RequeueAbandoned()
+					select {
+					case <-ctx.Done():
+					case <-time.After(JobPollSeconds * time.Second):
+					}
+				}
+			}
+		}()
+	}
+	wait.Wait()
+}
+
+// ExecuteJobRun runs the job's categorization in a child process, with its output appended to the job's log. The run
+// and any processes it starts are in their own process group, which we kill if the context is cancelled, so a worker
+// that stops doesn't leave the run behind.
+func ExecuteJobRun(ctx context.Context, store *JobStore, job Job) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	logPath := store.LogPath(job.ID)
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+	options := job.Options
+	options.JobID = job.ID
+	options.JobWorker = job.Worker
+	options.JobAttempt = job.Attempts
+	fmt.Fprintf(logFile, "Attempt %d started at %s\n", job.Attempts, time.Now().UTC().Format(time.RFC3339))
+	command := exec.CommandContext(ctx, executable, options.CommandLineArgs()...)
+	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	command.Cancel = func() error {
+		return syscall.Kill(-command.Process.Pid, syscall.SIGKILL)
+	}
+	command.Stdout = logFile
+	command.Stderr = logFile
+	if err := command.Run(); err != nil {
+		if lastLine := LastLogLine(logPath); lastLine != "" {
+			return fmt.Errorf("%v: %s", err, lastLine)
+		}
+		return err
+	}
+	return nil
+}
+
+// LastLogLine is the last line of output in the log, which for a failed run is usually the reason it failed
+func LastLogLine(logPath string) string {
+	file, err := os.Open(logPath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	const tailBytes = 4096
+	if info, err := file.Stat(); err == nil && info.Size() > tailBytes {
+		file.Seek(-tailBytes, io.SeekEnd)
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// WithAbsolutePaths makes the local paths in the options absolute, so a worker started in another directory reads the
+// same snippets. With a git ref, the start directory is a path in the repository, so we leave it as it is.
+func (options RunOptions) WithAbsolutePaths() (RunOptions, error) {
+	makeAbsolute := func(path *string) error {
+		if *path == "" {
+			return nil
+		}
+		absolutePath, err := filepath.Abs(*path)
+		if err == nil {
+			*path = absolutePath
+		}
+		return err
+	}
+	paths := []*string{&options.BaseReport}
+	if options.GitRef == "" {
+		paths = append(paths, &options.StartDir)
+	} else {
+		paths = append(paths, &options.GitRepo)
+	}
+	for _, path := range paths {
+		if err := makeAbsolute(path); err != nil {
+			return options, err
+		}
+	}
+	return options, nil
+}
+
+// RunJobsCommand submits categorization runs to the job queue, runs the queued jobs, and reports on them
+func RunJobsCommand(args []string) {
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "Usage: go run . jobs submit [run flags]")
+		fmt.Fprintln(os.Stderr, "       go run . jobs work [-workers n]")
+		fmt.Fprintln(os.Stderr, "       go run . jobs list")
+		fmt.Fprintln(os.Stderr, "       go run . jobs status JOB_ID")
+	}
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+	store := NewJobStore(JobsDirectory)
+	switch args[0] {
+	case "submit":
+		options, err := ParseRunOptions(flag.NewFlagSet("jobs submit", flag.ExitOnError), args[1:])
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		options, err = options.WithAbsolutePaths()
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		job, err := store.Submit(options, time.Now())
+		if err != nil {
+			log.Fatalf("failed to queue the job: %v", err)
+		}
+		fmt.Printf("Queued job %s for %s\n", job.ID, options.ProjectName)
+	case "work":
+		flags := flag.NewFlagSet("jobs work", flag.ExitOnError)
+		workers := flags.Int("workers", JobWorkers, "how many jobs to run at once")
+		flags.Parse(args[1:])
+		worker := NewJobWorker(store)
+		worker.RequeueAbandoned()
+		// On an interrupt or a SIGTERM, the workers stop their runs and put the jobs back in the queue
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		fmt.Printf("Running queued jobs from %s with %d workers as %s\n", store.Dir, *workers, store.WorkerID)
+		worker.Run(ctx, *workers)
+		fmt.Println("Stopped the workers")
+	case "list":
+		jobs, err := store.List()
+		if err != nil {
+			log.Fatalf("failed to read the jobs: %v", err)
+		}
+		for _, job := range jobs {
+			fmt.Printf("%s  %-9s  %-20s  %s\n", job.ID, job.Status, job.Options.ProjectName, job.Progress)
+		}
+	case "status":
+		if len(args) != 2 {
+			usage()
+			os.Exit(2)
+		}
+		job, err := store.Load(args[1])
+		if err != nil {
+			log.Fatalf("failed to read job %s: %v", args[1], err)
+		}
+		PrintJobStatus(job, store.LogPath(job.ID))
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+// PrintJobStatus prints the job, and for a job that succeeded, the category totals from its counts report
+func PrintJobStatus(job Job, logPath string) {
+	fmt.Printf("Job:       %s\n", job.ID)
+	fmt.Printf("Status:    %s\n", job.Status)
+	fmt.Printf("Project:   %s\n", job.Options.ProjectName)
+	fmt.Printf("Progress:  %s\n", job.Progress)
+	fmt.Printf("Submitted: %s\n", job.SubmittedAt.Format(time.RFC3339))
+	if job.StartedAt != nil {
+		fmt.Printf("Started:   %s (attempt %d)\n", job.StartedAt.Format(time.RFC3339), job.Attempts)
+	}
+	if job.Status == JobRunning && job.HeartbeatAt != nil {
+		fmt.Printf("Worker:    %s (last heartbeat %s)\n", job.Worker, job.HeartbeatAt.Format(time.RFC3339))
+	}
+	if job.FinishedAt != nil {
+		fmt.Printf("Finished:  %s\n", job.FinishedAt.Format(time.RFC3339))
+	}
+	fmt.Printf("Log:       %s\n", logPath)
+	if job.Error != "" {
+		fmt.Printf("Error:     %s\n", job.Error)
+	}
+	if job.RunDir == "" {
+		return
+	}
+	fmt.Printf("Reports:   %s\n", job.RunDir)
+	repoReport, err := ReadRepoReport(filepath.Join(job.RunDir, "language_category_counts.json"))
+	if err != nil {
+		fmt.Println("Error reading the counts report:", err)
+		return
+	}
+	counts := repoReport.TypedCounts()
+	categories := make([]string, 0, len(counts.Categories))
+	for category := range counts.Categories {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	fmt.Printf("Snippets:  %d\n", counts.Total)
+	for _, category := range categories {
+		fmt.Printf("  %s: %d (%.2f%%)\n", category, counts.Categories[category].Total, counts.Categories[category].Percentage)
+	}
+}
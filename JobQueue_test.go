@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJobWorkerRunsQueuedJobsInOrder(t *testing.T) {
+	store := NewJobStore(t.TempDir())
+	submittedAt := time.Date(2026, 10, 19, 14, 5, 2, 0, time.UTC)
+	first, err := store.Submit(RunOptions{ProjectName: "first"}, submittedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.Submit(RunOptions{ProjectName: "second"}, submittedAt.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ran []string
+	worker := JobWorker{Store: store, Execute: func(ctx context.Context, job Job) error {
+		ran = append(ran, job.Options.ProjectName)
+		if job.ID == second.ID {
+			return errors.New("exit status 1: failed to connect to ollama")
+		}
+		_, err := store.Update(job.ID, func(job *Job) {
+			job.RunDir = "output/first/20261019T140502Z"
+			job.Progress = JobProgress{Processed: 3, Total: 3}
+		})
+		return err
+	}}
+	for {
+		didRun, err := worker.RunNext(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !didRun {
+			break
+		}
+	}
+	expected := []string{"first", "second"}
+	if !reflect.DeepEqual(ran, expected) {
+		t.Errorf("got %q want %q", ran, expected)
+	}
+
+	got, err := store.Load(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != JobSucceeded || got.Attempts != 1 || got.RunDir == "" || got.FinishedAt == nil || got.Progress.Processed != 3 {
+		t.Errorf("got %+v, the first job should have succeeded with its run directory", got)
+	}
+	got, err = store.Load(second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != JobFailed || got.Error != "exit status 1: failed to connect to ollama" {
+		t.Errorf("got status %q and error %q, the second job should have failed", got.Status, got.Error)
+	}
+}
+
+func TestRequeueAbandonedJobs(t *testing.T) {
+	store := NewJobStore(t.TempDir())
+	submitted, err := store.Submit(RunOptions{ProjectName: "proj"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	startedAt := time.Now()
+	claimed, _, err := store.ClaimNext(startedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Update(claimed.ID, func(job *Job) {
+		job.Progress = JobProgress{Processed: 40, Total: 100}
+	})
+
+	// A worker in another process has its own store on the same directory
+	other := NewJobStore(store.Dir)
+	requeued, err := other.RequeueAbandoned(startedAt.Add(JobHeartbeatSeconds * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requeued) != 0 {
+		t.Fatalf("got %v, a job with a live worker shouldn't be requeued", requeued)
+	}
+	if _, claimedAgain, err := other.ClaimNext(time.Now()); err != nil || claimedAgain {
+		t.Fatalf("got %t, %v, the other worker shouldn't claim a running job", claimedAgain, err)
+	}
+
+	// The first worker stops, so its heartbeat does too
+	requeued, err = other.RequeueAbandoned(startedAt.Add((JobHeartbeatTimeoutSeconds + 1) * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requeued) != 1 || requeued[0].ID != submitted.ID {
+		t.Fatalf("got %v, the abandoned job should be requeued", requeued)
+	}
+	job, claimedAgain, err := other.ClaimNext(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !claimedAgain || job.ID != submitted.ID || job.Attempts != 2 || job.Progress != (JobProgress{}) || job.Worker != other.WorkerID {
+		t.Errorf("got %+v, the requeued job should run again from the start in the other worker", job)
+	}
+
+	// The first worker's heartbeat no longer touches the job
+	if err := store.Heartbeat(job.ID, claimed.Attempts, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := other.Load(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.HeartbeatAt.Equal(*job.HeartbeatAt) {
+		t.Errorf("got heartbeat %s want %s, a worker that lost the job shouldn't record its heartbeat", got.HeartbeatAt, job.HeartbeatAt)
+	}
+}
+
+func TestAttachRunToJobOnlyUpdatesItsOwnAttempt(t *testing.T) {
+	store := NewJobStore(t.TempDir())
+	submitted, err := store.Submit(RunOptions{ProjectName: "proj"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimed, _, err := store.ClaimNext(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The run is a child process with its own store
+	options := RunOptions{JobID: submitted.ID, JobWorker: claimed.Worker, JobAttempt: claimed.Attempts}
+	AttachRunToJob(NewJobStore(store.Dir), &options)
+	options.OnProgress(40, 100)
+	got, err := store.Load(submitted.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Progress != (JobProgress{Processed: 40, Total: 100}) {
+		t.Errorf("got progress %v want 40/100 from the attempt that owns the job", got.Progress)
+	}
+
+	// The worker stops, and another worker requeues the job and runs it again, while the first run keeps going
+	other := NewJobStore(store.Dir)
+	if _, err := other.RequeueAbandoned(time.Now().Add((JobHeartbeatTimeoutSeconds + 1) * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	reclaimed, _, err := other.ClaimNext(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	options.OnFinish("output/proj/20261019T140502Z", 100)
+	got, err = store.Load(submitted.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.RunDir != "" || got.Progress != (JobProgress{}) || !got.OwnedBy(other.WorkerID, reclaimed.Attempts) {
+		t.Errorf("got %+v, the first attempt shouldn't update the job once the second attempt owns it", got)
+	}
+}
+
+func TestJobWorkerRequeuesTheJobWhenItStops(t *testing.T) {
+	store := NewJobStore(t.TempDir())
+	submitted, err := store.Submit(RunOptions{ProjectName: "proj"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	worker := JobWorker{Store: store, Execute: func(ctx context.Context, job Job) error {
+		// The worker gets an interrupt while the run is going
+		cancel()
+		<-ctx.Done()
+		return errors.New("signal: killed")
+	}}
+	if _, err := worker.RunNext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Load(submitted.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != JobQueued || got.Worker != "" || got.Error != "" || got.FinishedAt != nil {
+		t.Errorf("got %+v, the stopped job should be back in the queue", got)
+	}
+}
+
+func TestTwoStoresClaimEachJobOnce(t *testing.T) {
+	first := NewJobStore(t.TempDir())
+	second := NewJobStore(first.Dir)
+	submittedAt := time.Now()
+	for i := 0; i < 20; i++ {
+		if _, err := first.Submit(RunOptions{ProjectName: "proj"}, submittedAt.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mutex sync.Mutex
+	claims := make(map[string]int)
+	var wait sync.WaitGroup
+	for _, store := range []*JobStore{first, second, first, second} {
+		wait.Add(1)
+		go func(store *JobStore) {
+			defer wait.Done()
+			for {
+				job, claimed, err := store.ClaimNext(time.Now())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !claimed {
+					return
+				}
+				mutex.Lock()
+				claims[job.ID]++
+				mutex.Unlock()
+			}
+		}(store)
+	}
+	wait.Wait()
+	if len(claims) != 20 {
+		t.Errorf("got %d jobs claimed want 20", len(claims))
+	}
+	for id, count := range claims {
+		if count != 1 {
+			t.Errorf("got job %s claimed %d times want once", id, count)
+		}
+	}
+}
+
+func TestRunOptionsCommandLineArgsRoundTrip(t *testing.T) {
+	options := RunOptions{
+		ProjectName: "atlas-cli",
+		Source:      SourceDocs,
+		StartDir:    "source",
+		GitRepo:     "/repos/docs-atlas-cli",
+		GitRef:      "v1.20",
+		DocsURL:     "https://www.mongodb.com/docs/atlas/cli/current/",
+		Stream:      true,
+		KeepRuns:    3,
+		Formats:     []string{FormatJSON},
+		JobID:       "20261019T140502Z-a1b2c3",
+		JobWorker:   "worker-host-1234-a1b2c3",
+		JobAttempt:  2,
+	}
+	got, err := ParseRunOptions(flag.NewFlagSet("test", flag.ContinueOnError), options.CommandLineArgs())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, options) {
+		t.Errorf("got %+v want %+v", got, options)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// LanguageDefinition describes how to recognize a language from a file name, and which language category we use to
+// pick the string matchers and prompt for snippets in that language. Extensions may be compound, such as `.sh.go`,
+// and filename patterns are shell-style globs matched against the base name of the file, such as `Dockerfile*`.
+// Aliases are the other names docs writers use for the language in code-block and fenced code tags, such as `sh`.
+type LanguageDefinition struct {
+	Name             string   `json:"name"`
+	Category         string   `json:"category"`
+	Extensions       []string `json:"extensions"`
+	FilenamePatterns []string `json:"filename_patterns,omitempty"`
+	Aliases          []string `json:"aliases,omitempty"`
+}
+
+type LanguageRegistry struct {
+	Languages []LanguageDefinition `json:"languages"`
+
+	extensions map[string]string
+	categories map[string]string
+	aliases    map[string]string
+}
+
+var (
+	languageRegistry     *LanguageRegistry
+	languageRegistryOnce sync.Once
+)
+
+// DefaultLanguageDefinitions are the languages we recognize when no registry file is present. A registry file can
+// replace any of these by declaring a language with the same name, or add new languages.
+func DefaultLanguageDefinitions() []LanguageDefinition {
+	return []LanguageDefinition{
+		{Name: C, Category: DRIVERS_MINUS_JS, Extensions: []string{".c", ".h"}},
+		{Name: CPP, Category: DRIVERS_MINUS_JS, Extensions: []string{".cpp", ".cc", ".cxx", ".hpp"}, Aliases: []string{"c++"}},
+		{Name: CSHARP, Category: DRIVERS_MINUS_JS, Extensions: []string{".cs"}, Aliases: []string{"c#", "cs"}},
+		{Name: GO, Category: DRIVERS_MINUS_JS, Extensions: []string{".go"}, Aliases: []string{"golang"}},
+		{Name: JAVA, Category: DRIVERS_MINUS_JS, Extensions: []string{".java"}},
+		{Name: JAVASCRIPT, Category: JAVASCRIPT, Extensions: []string{".js", ".mjs", ".cjs", ".jsx"}, Aliases: []string{"js", "node", "nodejs", "mongosh"}},
+		{Name: JSON, Category: JSON_LIKE, Extensions: []string{".json"}},
+		{Name: KOTLIN, Category: DRIVERS_MINUS_JS, Extensions: []string{".kt", ".kts"}, Aliases: []string{"kt"}},
+		{Name: PHP, Category: DRIVERS_MINUS_JS, Extensions: []string{".php"}},
+		{Name: PYTHON, Category: DRIVERS_MINUS_JS, Extensions: []string{".py"}, Aliases: []string{"py", "python3"}},
+		{Name: RUBY, Category: DRIVERS_MINUS_JS, Extensions: []string{".rb"}, Aliases: []string{"rb"}},
+		{Name: RUST, Category: DRIVERS_MINUS_JS, Extensions: []string{".rs"}, Aliases: []string{"rs"}},
+		{Name: SCALA, Category: DRIVERS_MINUS_JS, Extensions: []string{".scala"}},
+		// The code-block extraction names Atlas CLI snippets from Go pages `<name>.sh.go`, so the compound extension
+		// has to win over the trailing `.go`
+		{Name: SHELL, Category: SHELL, Extensions: []string{".sh", ".bash", ".zsh", ".sh.go"}, Aliases: []string{"sh", "bash", "zsh", "console", "shell-session"}},
+		{Name: POWERSHELL, Category: SHELL, Extensions: []string{".ps1"}, Aliases: []string{"ps1", "pwsh"}},
+		{Name: SQL, Category: TEXT, Extensions: []string{".sql"}},
+		{Name: SWIFT, Category: DRIVERS_MINUS_JS, Extensions: []string{".swift"}},
+		{Name: TEXT, Category: TEXT, Extensions: []string{".txt"}, Aliases: []string{"none", "plaintext", "txt"}},
+		{Name: TOML, Category: JSON_LIKE, Extensions: []string{".toml"}},
+		{Name: INI, Category: JSON_LIKE, Extensions: []string{".ini", ".cfg", ".properties"}},
+		{Name: TYPESCRIPT, Category: DRIVERS_MINUS_JS, Extensions: []string{".ts", ".tsx", ".mts"}, Aliases: []string{"ts"}},
+		{Name: XML, Category: JSON_LIKE, Extensions: []string{".xml"}},
+		{Name: YAML, Category: JSON_LIKE, Extensions: []string{".yaml", ".yml"}, Aliases: []string{"yml"}},
+		{Name: MARKDOWN, Category: TEXT, Extensions: []string{".md", ".markdown"}, Aliases: []string{"md"}},
+		{Name: RST, Category: TEXT, Extensions: []string{".rst"}, Aliases: []string{"restructuredtext"}},
+		{Name: DOCKERFILE, Category: TEXT, FilenamePatterns: []string{"Dockerfile", "Dockerfile.*", "*.dockerfile"}},
+	}
+}
+
+// NewLanguageRegistry builds the lookup tables for a set of language definitions. When two definitions claim the same
+// extension, the later definition wins.
+func NewLanguageRegistry(definitions []LanguageDefinition) *LanguageRegistry {
+	registry := &LanguageRegistry{
+		Languages:  definitions,
+		extensions: make(map[string]string),
+		categories: make(map[string]string),
+		aliases:    make(map[string]string),
+	}
+	for _, definition := range definitions {
+		registry.categories[definition.Name] = definition.Category
+		for _, ext := range definition.Extensions {
+			registry.extensions[strings.ToLower(ext)] = definition.Name
+		}
+		for _, alias := range definition.Aliases {
+			registry.aliases[strings.ToLower(alias)] = definition.Name
+		}
+	}
+	return registry
+}
+
+// LoadLanguageRegistry merges the language definitions in the registry file at the given path over the built-in
+// defaults. If the file doesn't exist, we use the defaults as-is.
+func LoadLanguageRegistry(path string) (*LanguageRegistry, error) {
+	definitions := DefaultLanguageDefinitions()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewLanguageRegistry(definitions), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var configured LanguageRegistry
+	if err := json.Unmarshal(data, &configured); err != nil {
+		return nil, err
+	}
+	for _, definition := range configured.Languages {
+		replaced := false
+		for i, existing := range definitions {
+			if existing.Name == definition.Name {
+				definitions[i] = definition
+				replaced = true
+			}
+		}
+		if !replaced {
+			definitions = append(definitions, definition)
+		}
+	}
+	return NewLanguageRegistry(definitions), nil
+}
+
+// GetLanguageRegistry loads the registry from the LanguageRegistryFile the first time it's called, and returns the
+// same registry on every later call
+func GetLanguageRegistry() *LanguageRegistry {
+	languageRegistryOnce.Do(func() {
+		registry, err := LoadLanguageRegistry(LanguageRegistryFile)
+		if err != nil {
+			log.Fatalf("failed to load the language registry from %s: %v", LanguageRegistryFile, err)
+		}
+		languageRegistry = registry
+	})
+	return languageRegistry
+}
+
+// LanguageForFile matches filename patterns first, then extensions from the longest compound extension down to the
+// final extension, so `atlasCli.sh.go` checks `.sh.go` before `.go`
+func (r *LanguageRegistry) LanguageForFile(path string) string {
+	base := filepath.Base(path)
+	for _, definition := range r.Languages {
+		for _, pattern := range definition.FilenamePatterns {
+			if matched, _ := filepath.Match(pattern, base); matched {
+				return definition.Name
+			}
+		}
+	}
+	lowerBase := strings.ToLower(base)
+	for i := 1; i < len(lowerBase); i++ {
+		if lowerBase[i] != '.' {
+			continue
+		}
+		if lang, exists := r.extensions[lowerBase[i:]]; exists {
+			return lang
+		}
+	}
+	return ""
+}
+
+func (r *LanguageRegistry) LanguageForExtension(ext string) string {
+	return r.extensions[strings.ToLower(ext)]
+}
+
+// CategoryForLanguage returns an empty string for languages the registry doesn't know about
+func (r *LanguageRegistry) CategoryForLanguage(lang string) string {
+	return r.categories[lang]
+}
+
+// LanguageForTag resolves the language tag on a code block, such as `sh` in `.. code-block:: sh`. We check language
+// names, then aliases, then treat the tag as an extension. Unrecognized tags come back unchanged, so the snippet
+// report still shows what the writer declared.
+func (r *LanguageRegistry) LanguageForTag(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" {
+		return ""
+	}
+	if _, exists := r.categories[tag]; exists {
+		return tag
+	}
+	if lang, exists := r.aliases[tag]; exists {
+		return lang
+	}
+	if lang, exists := r.extensions["."+tag]; exists {
+		return lang
+	}
+	return tag
+}
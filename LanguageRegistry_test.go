@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLanguageForFileCompoundExtension(t *testing.T) {
+	registry := NewLanguageRegistry(DefaultLanguageDefinitions())
+	got := registry.LanguageForFile("examples/other/atlasCli.sh.go")
+	if got != SHELL {
+		t.Errorf("got %q want %q", got, SHELL)
+	}
+}
+
+func TestLanguageForFileFinalExtension(t *testing.T) {
+	registry := NewLanguageRegistry(DefaultLanguageDefinitions())
+	got := registry.LanguageForFile("examples/manage-indexes/drop-index.go")
+	if got != GO {
+		t.Errorf("got %q want %q", got, GO)
+	}
+}
+
+func TestLanguageForFileFilenamePattern(t *testing.T) {
+	registry := NewLanguageRegistry(DefaultLanguageDefinitions())
+	got := registry.LanguageForFile("deploy/Dockerfile")
+	if got != DOCKERFILE {
+		t.Errorf("got %q want %q", got, DOCKERFILE)
+	}
+}
+
+func TestLanguageForFileUnknownExtension(t *testing.T) {
+	registry := NewLanguageRegistry(DefaultLanguageDefinitions())
+	got := registry.LanguageForFile("notes.unknown")
+	if got != "" {
+		t.Errorf("got %q want no language", got)
+	}
+}
+
+func TestLoadLanguageRegistryMergesConfiguredLanguages(t *testing.T) {
+	registryPath := filepath.Join(t.TempDir(), "languages.json")
+	config := `{"languages": [
+		{"name": "yaml", "category": "text", "extensions": [".yaml"]},
+		{"name": "hcl", "category": "json_like", "extensions": [".tf", ".hcl"]}
+	]}`
+	if err := os.WriteFile(registryPath, []byte(config), 0644); err != nil {
+		t.Fatalf("failed to write registry file %v", err)
+	}
+	registry, err := LoadLanguageRegistry(registryPath)
+	if err != nil {
+		t.Fatalf("failed to load registry %v", err)
+	}
+	if got := registry.LanguageForFile("main.tf"); got != "hcl" {
+		t.Errorf("got %q want %q", got, "hcl")
+	}
+	if got := registry.CategoryForLanguage(YAML); got != TEXT {
+		t.Errorf("got %q want %q", got, TEXT)
+	}
+	// Replacing the yaml definition drops the extensions it no longer lists
+	if got := registry.LanguageForFile("config.yml"); got != "" {
+		t.Errorf("got %q want no language", got)
+	}
+	if got := registry.LanguageForFile("main.go"); got != GO {
+		t.Errorf("got %q want %q", got, GO)
+	}
+}
+
+func TestLoadLanguageRegistryWithoutFileUsesDefaults(t *testing.T) {
+	registry, err := LoadLanguageRegistry(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("failed to load registry %v", err)
+	}
+	if got := registry.CategoryForLanguage(YAML); got != JSON_LIKE {
+		t.Errorf("got %q want %q", got, JSON_LIKE)
+	}
+}
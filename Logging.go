@@ -5,8 +5,8 @@ import (
 	"time"
 )
 
-func LogStartInfoToConsole(startTime time.Time, fileCount int) {
-	fmt.Printf("Processing %d files for %s project\n", fileCount, ProjectName)
+func LogStartInfoToConsole(startTime time.Time, fileCount int, projectName string) {
+	fmt.Printf("Processing %d snippets for %s project\n", fileCount, projectName)
 	fmt.Println("Starting at ", startTime)
 	// On an M1 Max laptop from 2021 w/64GB of RAM, a single file takes ~750000000 to process
 	// Adjust processing time as needed based on the hardware running this program
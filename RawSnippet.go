@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RawSnippet is a code example we've read into memory but haven't categorized yet. Snippets from a tree of
+// one-file-per-snippet files only set the Page, DeclaredLanguage and Contents. Snippets we extract from docs sources
+// also record where on the page the code block lives, its caption, and the directive that declared it. For a
+// directive that includes a file, IncludePath is the path of that file in the tree.
+type RawSnippet struct {
+	Page             string
+	DeclaredLanguage string
+	Contents         string
+	StartLine        int
+	EndLine          int
+	Caption          string
+	Directive        string
+	IncludePath      string
+}
+
+// ReadSnippetFiles reads each file in a one-file-per-snippet tree into memory
+func ReadSnippetFiles(tree SnippetTree, projectName string) ([]RawSnippet, []IngestionDiagnostic) {
+	files, diagnostics := DiscoverFiles(tree, DefaultDiscoveryOptions())
+	var rawSnippets []RawSnippet
+	for _, file := range files {
+		contents, err := tree.ReadFile(file)
+		if err != nil {
+			fmt.Printf("failed to read file: %v\n", err)
+			diagnostics = append(diagnostics, IngestionDiagnostic{Path: tree.DisplayPath(file), Reason: DiagnosticUnreadable, Detail: err.Error(), Skipped: true})
+			continue
+		}
+		if strings.TrimSpace(string(contents)) == "" {
+			diagnostics = append(diagnostics, IngestionDiagnostic{Path: tree.DisplayPath(file), Reason: DiagnosticEmpty, Detail: "contains only whitespace", Skipped: true})
+			continue
+		}
+		rawSnippets = append(rawSnippets, RawSnippet{
+			Page:             PagePath(file, projectName),
+			DeclaredLanguage: GetLangFromFilename(file),
+			Contents:         string(contents),
+		})
+	}
+	return rawSnippets, diagnostics
+}
+
+// PagePath prefixes the path of the file in the snippet tree with the project name, which is how pages appear in the
+// reports
+func PagePath(name string, projectName string) string {
+	return projectName + "/" + name
+}
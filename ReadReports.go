@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"unicode"
+)
+
+// ReadSnippetReport reads every snippet from the snippet report of an earlier run, in any of the forms ReadSnippets reads
+func ReadSnippetReport(filePath string) ([]SnippetInfo, error) {
+	snippets := []SnippetInfo{}
+	err := ReadSnippets(filePath, func(snippet SnippetInfo) error {
+		snippets = append(snippets, snippet)
+		return nil
+	})
+	return snippets, err
+}
+
+// ReadSnippets calls the function with each snippet in a snippet report, one at a time, so we can re-aggregate a very
+// large report without holding it in memory. It reads snippets.ndjson, with one snippet per line, snippets.json with a
+// schema version, and the bare arrays of snippets from before we versioned the reports.
+func ReadSnippets(filePath string, each func(SnippetInfo) error) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	first, err := firstNonSpaceByte(reader)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(reader)
+	if first == '[' {
+		if _, err := decoder.Token(); err != nil {
+			return err
+		}
+		for decoder.More() {
+			var snippet SnippetInfo
+			if err := decoder.Decode(&snippet); err != nil {
+				return fmt.Errorf("%s: %v", filePath, err)
+			}
+			if err := each(snippet); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// Each value is either a report with a schema version and a list of snippets, or a snippet on its own line
+	for {
+		var value struct {
+			SnippetInfo
+			SchemaVersion int           `json:"schema_version"`
+			Snippets      []SnippetInfo `json:"snippets"`
+		}
+		err := decoder.Decode(&value)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", filePath, err)
+		}
+		snippets := []SnippetInfo{value.SnippetInfo}
+		if value.SchemaVersion != 0 {
+			snippets = value.Snippets
+		}
+		for _, snippet := range snippets {
+			if err := each(snippet); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// SnippetReportPath finds the snippet report in a run directory. Runs with -stream write snippets.ndjson instead of
+// snippets.json.
+func SnippetReportPath(runDir string) string {
+	streamPath := filepath.Join(runDir, SnippetStreamReportFile)
+	if _, err := os.Stat(streamPath); err == nil {
+		return streamPath
+	}
+	return filepath.Join(runDir, SnippetReportFile)
+}
+
+func firstNonSpaceByte(reader *bufio.Reader) (byte, error) {
+	for {
+		next, err := reader.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		if !unicode.IsSpace(rune(next[0])) {
+			return next[0], nil
+		}
+		reader.ReadByte()
+	}
+}
+
+// ReadRepoReport reads the language_category_counts.json report from an earlier run
+func ReadRepoReport(filePath string) (RepoReport, error) {
+	var repoReport RepoReport
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return repoReport, err
+	}
+	err = json.Unmarshal(data, &repoReport)
+	return repoReport, err
+}
+
+// ReadRollupReport reads the rollups.json report from an earlier run
+func ReadRollupReport(filePath string) (RollupReport, error) {
+	var rollups RollupReport
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return rollups, err
+	}
+	err = json.Unmarshal(data, &rollups)
+	return rollups, err
+}
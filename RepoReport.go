@@ -1,13 +1,45 @@
 package main
 
+// CategorizationDetails counts how the snippets got their categories: by the LLM, by a string match, or by hand with an
+// overrides file. A snippet with an override only counts as overridden, whoever categorized it first.
 type CategorizationDetails struct {
 	LLMCategorizedCount int     `json:"llm_categorized_count"`
 	StringMatchedCount  int     `json:"string_matched_count"`
+	OverriddenCount     int     `json:"overridden_count"`
 	AccuracyEstimate    float64 `json:"accuracy_estimate"`
 }
 
+// SourceCommit identifies the git commit a run read its snippets from
+type SourceCommit struct {
+	Repository string `json:"repository"`
+	Ref        string `json:"ref"`
+	SHA        string `json:"sha"`
+}
+
+// RepoReport is the language_category_counts.json report. CategoryLanguageCounts is the original form of the counts,
+// with each category's total under "totals", which we still write for older readers. Counts has the same counts with
+// typed totals and percentages, from schema version 3.
 type RepoReport struct {
+	ReportHeader
 	TotalCodeBlocks        int                       `json:"total_code_blocks"`
 	CategorizationDetails  CategorizationDetails     `json:"categorization_details"`
 	CategoryLanguageCounts map[string]map[string]int `json:"category_language_counts"`
+	Counts                 *CategoryCounts           `json:"counts,omitempty"`
+	SourceCommit           *SourceCommit             `json:"source_commit,omitempty"`
+}
+
+// TypedCounts returns the report's typed counts, or totals the legacy counts for reports from before we wrote them
+func (r RepoReport) TypedCounts() CategoryCounts {
+	if r.Counts != nil {
+		return *r.Counts
+	}
+	return CategoryCountsFromLegacy(r.CategoryLanguageCounts)
+}
+
+// TaxonomyVersion is the version of the taxonomy the report's categories are in
+func (r RepoReport) TaxonomyVersion() int {
+	if r.Metadata == nil || r.Metadata.TaxonomyVersion == 0 {
+		return UnversionedTaxonomy
+	}
+	return r.Metadata.TaxonomyVersion
 }
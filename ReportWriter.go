@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatJSON     = "json"
+	FormatCSV      = "csv"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// ReportData is everything a ReportWriter can write about a run. The ReportName names the run in report titles, such
+// as atlas-cli@v1.20. If SnippetsStreamed is set, the run already wrote the snippets to snippets.ndjson, and Snippets
+// is empty.
+type ReportData struct {
+	ReportName       string
+	Snippets         []SnippetInfo
+	SnippetsStreamed bool
+	RepoReport       RepoReport
+	Rollups          RollupReport
+	Metadata         *RunMetadata
+}
+
+// ReportWriter writes the reports for a run in one output format to the run directory, and returns the first error
+type ReportWriter interface {
+	Write(data ReportData, runDir string) error
+}
+
+// ReportWriters maps each output format to its writer. To add a format, implement ReportWriter and add it here.
+var ReportWriters = map[string]ReportWriter{
+	FormatJSON:     jsonReportWriter{},
+	FormatCSV:      csvReportWriter{},
+	FormatMarkdown: markdownReportWriter{},
+	FormatHTML:     htmlReportWriter{},
+}
+
+// StreamableFormats are the formats that don't need every snippet at once, so they work when a run streams its snippets
+var StreamableFormats = []string{FormatJSON}
+
+// ParseReportFormats parses a comma-separated list of formats. We always write JSON, because incremental runs read
+// the JSON snippet report from earlier runs.
+func ParseReportFormats(formatList string) ([]string, error) {
+	formats := []string{FormatJSON}
+	for _, format := range strings.Split(formatList, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if format == "" || containsString(formats, format) {
+			continue
+		}
+		if _, exists := ReportWriters[format]; !exists {
+			known := make([]string, 0, len(ReportWriters))
+			for name := range ReportWriters {
+				known = append(known, name)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown report format %q, expected one of %s", format, strings.Join(known, ", "))
+		}
+		formats = append(formats, format)
+	}
+	return formats, nil
+}
+
+type jsonReportWriter struct{}
+
+func (jsonReportWriter) Write(data ReportData, runDir string) error {
+	if !data.SnippetsStreamed {
+		if err := WriteSnippetReport(data.Snippets, data.Metadata, runDir); err != nil {
+			return err
+		}
+	}
+	if err := WriteCategoryCountsReport(data.RepoReport, runDir); err != nil {
+		return err
+	}
+	return WriteRollupReport(data.Rollups, runDir)
+}
+
+// CategoryLanguagePivot lays the category and language counts out as a table, with a row for each category and a
+// column for each language. The categories are in the taxonomy's order, and the languages are sorted by name. After
+// the totals, the table has a column for each of the SubcategoryNames, and if any category has a parent or is a
+// secondary label, columns for the hierarchy or the secondary labels.
+type CategoryLanguagePivot struct {
+	CategoryCounts
+	CategoryNames      []string
+	LanguageNames      []string
+	SubcategoryNames   []string
+	HasHierarchy       bool
+	HasSecondaryLabels bool
+}
+
+func NewCategoryLanguagePivot(counts CategoryCounts) CategoryLanguagePivot {
+	pivot := CategoryLanguagePivot{CategoryCounts: counts}
+	for category, categoryTotal := range counts.Categories {
+		pivot.CategoryNames = append(pivot.CategoryNames, category)
+		for subcategory := range categoryTotal.Subcategories {
+			if !containsString(pivot.SubcategoryNames, subcategory) {
+				pivot.SubcategoryNames = append(pivot.SubcategoryNames, subcategory)
+			}
+		}
+		pivot.HasHierarchy = pivot.HasHierarchy || categoryTotal.Parent != ""
+		pivot.HasSecondaryLabels = pivot.HasSecondaryLabels || categoryTotal.SecondaryLabels != 0
+	}
+	for language := range counts.Languages {
+		pivot.LanguageNames = append(pivot.LanguageNames, language)
+	}
+	GetTaxonomy().SortCategories(pivot.CategoryNames)
+	sort.Strings(pivot.LanguageNames)
+	sort.Strings(pivot.SubcategoryNames)
+	return pivot
+}
+
+// DetailHeader names the subcategory, hierarchy and secondary label columns, in each report format's own words
+func (p CategoryLanguagePivot) DetailHeader(subcategoryColumn func(subcategory string) string, parentColumn string, totalWithChildrenColumn string, secondaryLabelsColumn string) []string {
+	var header []string
+	for _, subcategory := range p.SubcategoryNames {
+		header = append(header, subcategoryColumn(subcategory))
+	}
+	if p.HasHierarchy {
+		header = append(header, parentColumn, totalWithChildrenColumn)
+	}
+	if p.HasSecondaryLabels {
+		header = append(header, secondaryLabelsColumn)
+	}
+	return header
+}
+
+// DetailCells are the category's cells in the DetailHeader columns. A cell is empty if it doesn't apply to the
+// category, such as the subcategories of a category we don't break down, or the total with children of a category
+// without children.
+func (p CategoryLanguagePivot) DetailCells(category string) []string {
+	categoryTotal := p.Categories[category]
+	var cells []string
+	for _, subcategory := range p.SubcategoryNames {
+		if categoryTotal.Subcategories == nil {
+			cells = append(cells, "")
+		} else {
+			cells = append(cells, strconv.Itoa(categoryTotal.Subcategories[subcategory]))
+		}
+	}
+	if p.HasHierarchy {
+		totalWithChildren := ""
+		if categoryTotal.TotalWithChildren != 0 {
+			totalWithChildren = strconv.Itoa(categoryTotal.TotalWithChildren)
+		}
+		cells = append(cells, categoryTotal.Parent, totalWithChildren)
+	}
+	if p.HasSecondaryLabels {
+		cells = append(cells, strconv.Itoa(categoryTotal.SecondaryLabels))
+	}
+	return cells
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseReportFormats(t *testing.T) {
+	got, err := ParseReportFormats("CSV, markdown,json")
+	if err != nil {
+		t.Fatalf("failed to parse the formats %v", err)
+	}
+	expected := []string{FormatJSON, FormatCSV, FormatMarkdown}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v want %v", got, expected)
+	}
+	if _, err := ParseReportFormats("xlsx"); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
+
+func TestNewCategoryLanguagePivot(t *testing.T) {
+	counts := map[string]map[string]int{
+		UsageExample:  {PYTHON: 3, GO: 1},
+		SyntaxExample: {SHELL: 2},
+	}
+	got := NewCategoryLanguagePivot(NewCategoryCounts(counts))
+	if !reflect.DeepEqual(got.CategoryNames, []string{SyntaxExample, UsageExample}) {
+		t.Errorf("got %v want the categories in the taxonomy's order", got.CategoryNames)
+	}
+	if !reflect.DeepEqual(got.LanguageNames, []string{GO, PYTHON, SHELL}) {
+		t.Errorf("got %v want the languages sorted by name", got.LanguageNames)
+	}
+	if got.Categories[UsageExample].Total != 4 || got.Languages[SHELL].Total != 2 || got.Total != 6 {
+		t.Errorf("got %v %v %d want the totals to add up to 6", got.Categories, got.Languages, got.Total)
+	}
+}
+
+func TestMarkdownTable(t *testing.T) {
+	got := MarkdownTable([]string{"Page", "Category"}, [][]string{{"a|b.txt", UsageExample}})
+	expected := "| Page | Category |\n| --- | --- |\n| a\\|b.txt | Task-based usage |\n"
+	if got != expected {
+		t.Errorf("got %q want %q", got, expected)
+	}
+}
+
+func TestWriteRunReportsReturnsWriteErrors(t *testing.T) {
+	// A run directory under a file can't have any reports written to it
+	blocker := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocker, []byte("not a directory"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result := NewRunResult("", SourceFiles, nil)
+	result.AddSnippet(SnippetInfo{Page: "proj/a/b.py", Category: UsageExample, Language: PYTHON})
+	for _, format := range []string{FormatJSON, FormatCSV, FormatMarkdown, FormatHTML} {
+		if err := WriteRunReports(*result, "proj", filepath.Join(blocker, "run"), false, []string{format}); err == nil {
+			t.Errorf("got no error writing the %s reports to a directory that can't exist", format)
+		}
+	}
+}
+
+func TestCategoryLanguagePivotDetails(t *testing.T) {
+	counts := NewCategoryCounts(map[string]map[string]int{
+		AtlasCLICommand: {SHELL: 2},
+		UsageExample:    {PYTHON: 1},
+		"Tutorial step": {PYTHON: 1},
+	})
+	counts.AddSubcategories(map[string]map[string]int{AtlasCLICommand: {SubcategorySyntax: 1, SubcategoryUsage: 1}})
+	counts.AddSecondaryLabels(map[string]int{SyntaxExample: 1})
+	tutorialStep := counts.Categories["Tutorial step"]
+	tutorialStep.Parent = UsageExample
+	counts.Categories["Tutorial step"] = tutorialStep
+	usage := counts.Categories[UsageExample]
+	usage.TotalWithChildren = 2
+	counts.Categories[UsageExample] = usage
+
+	pivot := NewCategoryLanguagePivot(counts)
+	header := pivot.DetailHeader(func(subcategory string) string { return "subcategory_" + subcategory }, "parent", "total_with_children", "secondary_labels")
+	expectedHeader := []string{"subcategory_syntax", "subcategory_usage", "parent", "total_with_children", "secondary_labels"}
+	if !reflect.DeepEqual(header, expectedHeader) {
+		t.Errorf("got %q want %q", header, expectedHeader)
+	}
+	expectedCells := map[string][]string{
+		AtlasCLICommand: {"1", "1", "", "", "0"},
+		UsageExample:    {"", "", "", "2", "0"},
+		"Tutorial step": {"", "", UsageExample, "", "0"},
+		SyntaxExample:   {"", "", "", "", "1"},
+	}
+	for category, expected := range expectedCells {
+		if got := pivot.DetailCells(category); !reflect.DeepEqual(got, expected) {
+			t.Errorf("got %q for %s want %q", got, category, expected)
+		}
+	}
+
+	plain := NewCategoryLanguagePivot(NewCategoryCounts(map[string]map[string]int{UsageExample: {PYTHON: 1}}))
+	if header := plain.DetailHeader(func(subcategory string) string { return subcategory }, "parent", "total_with_children", "secondary_labels"); len(header) != 0 {
+		t.Errorf("got %q want no detail columns without subcategories, a hierarchy or secondary labels", header)
+	}
+}
+
+func TestCsvAndMarkdownReportsShowSubcategoriesAndLabels(t *testing.T) {
+	runDir := t.TempDir()
+	result := NewRunResult("", SourceFiles, nil)
+	result.AddSnippet(SnippetInfo{Page: "proj/a.sh", Category: AtlasCLICommand, Subcategory: SubcategorySyntax, Language: SHELL})
+	result.AddSnippet(SnippetInfo{Page: "proj/b.py", Category: UsageExample, Labels: []string{UsageExample, SyntaxExample}, Language: PYTHON})
+	if err := WriteRunReports(*result, "proj", runDir, false, []string{FormatCSV, FormatMarkdown}); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{
+		"snippets.csv":                 {"subcategory,labels", `proj/b.py,Task-based usage,`, `,"Task-based usage, Syntax example"`},
+		"category_language_counts.csv": {"subcategory_syntax,secondary_labels", "Atlas CLI command,0,1,1,50.00,1,0"},
+		"snippets.md":                  {"| Category | Subcategory | Labels |", "| Atlas CLI command | syntax |  |", "| Task-based usage |  | Task-based usage, Syntax example |"},
+		"category_language_counts.md":  {"| Subcategory syntax | Secondary labels |"},
+	}
+	for name, contents := range expected {
+		data, err := os.ReadFile(filepath.Join(runDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, expectedContents := range contents {
+			if !strings.Contains(string(data), expectedContents) {
+				t.Errorf("got %s\n%s\nwant it to contain %q", name, data, expectedContents)
+			}
+		}
+	}
+}
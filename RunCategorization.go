@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/ollama"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	SourceFiles = "files"
+	SourceDocs  = "docs"
+)
+
+// RunOptions describe where to find the snippets for a categorization run. The Source is either SourceFiles, for a
+// tree of one-file-per-snippet files, or SourceDocs, to extract code blocks from reStructuredText and Markdown pages.
+// If GitRef is set, we read the tree from that commit in the GitRepo, and StartDir is a directory in the repository.
+// If BaseRef is also set, we only categorize the files that changed since the BaseRef, and update the BaseReport from
+// the run against the BaseRef.
+type RunOptions struct {
+	ProjectName string `json:"project_name"`
+	Source      string `json:"source"`
+	StartDir    string `json:"start_dir"`
+	GitRepo     string `json:"git_repo,omitempty"`
+	GitRef      string `json:"git_ref,omitempty"`
+	BaseRef     string `json:"base_ref,omitempty"`
+	BaseReport  string `json:"base_report,omitempty"`
+	DocsURL     string `json:"docs_url,omitempty"`
+	// Stream writes each snippet to snippets.ndjson as we categorize it, instead of keeping every snippet in memory
+	// and writing snippets.json at the end
+	Stream bool `json:"stream"`
+	// KeepRuns is how many run directories to keep for the report name, or zero to keep them all
+	KeepRuns int `json:"keep_runs"`
+	// Formats are the output formats for the snippet and category counts reports, such as FormatCSV
+	Formats []string `json:"formats"`
+	// JobID is the queued job this run is for, if a job worker started it. JobWorker and JobAttempt are the worker and
+	// the attempt that claimed the job, so the run only updates the job while that attempt owns it.
+	JobID      string `json:"-"`
+	JobWorker  string `json:"-"`
+	JobAttempt int    `json:"-"`
+	// OnProgress is called with the number of snippets categorized so far, out of the total for the run
+	OnProgress func(processed int, total int) `json:"-"`
+	// OnFinish is called with the run directory once the run is promoted to latest
+	OnFinish func(runDir string, snippetCount int) `json:"-"`
+}
+
+// RunResult holds everything we learned while categorizing the snippets in a run, which is what the reports are
+// built from. If the result has a Stream, we write each snippet to the stream instead of keeping it in Snippets.
+type RunResult struct {
+	Snippets          []SnippetInfo
+	SnippetCount      int
+	Stream            *SnippetStreamWriter
+	Rollups           *RollupBuilder
+	Mismatches        []LanguageMismatch
+	Diagnostics       []IngestionDiagnostic
+	Counts            map[string]map[string]int
+	SubcategoryCounts map[string]map[string]int
+	// SecondaryLabelCounts is the number of snippets with each category as a label other than their primary one
+	SecondaryLabelCounts map[string]int
+	LLMCategorizedCount  int
+	StringMatchedCount   int
+	OverriddenCount      int
+	SourceCommit         *SourceCommit
+	DocsBaseURL          string
+	Metadata             *RunMetadata
+	OnProgress           func(processed int, total int)
+}
+
+// NewRunResult starts an empty result. The docs base URL links the pages in the rollups to the published docs, and the
+// source is where the snippets came from, which decides what a page is.
+func NewRunResult(docsBaseURL string, source string, stream *SnippetStreamWriter) *RunResult {
+	return &RunResult{
+		Counts:               make(map[string]map[string]int),
+		SubcategoryCounts:    make(map[string]map[string]int),
+		SecondaryLabelCounts: make(map[string]int),
+		Stream:               stream,
+		Rollups:              NewRollupBuilder(docsBaseURL, source),
+		DocsBaseURL:          docsBaseURL,
+	}
+}
+
+func IsDriverProject(projectName string) bool {
+	driversProjects := []string{"c", "cpp-driver", "csharp", "java", "java-rs", "kotlin", "kotlin-sync", "laravel", "node", "php-library", "pymongo", "pymongo-arrow", "ruby-driver", "rust", "scala"}
+	return containsString(driversProjects, projectName)
+}
+
+// RunCategorization gathers the snippets for the run, categorizes them, and writes the reports
+func RunCategorization(options RunOptions) {
+	if options.BaseRef != "" {
+		RunIncrementalCategorization(options)
+		return
+	}
+	isDriverProject := IsDriverProject(options.ProjectName)
+	startTime := time.Now()
+	tree, sourceCommit, err := OpenSnippetTree(options)
+	if err != nil {
+		log.Fatalf("failed to open the snippet tree: %v", err)
+	}
+	if closer, isCloser := tree.(io.Closer); isCloser {
+		defer closer.Close()
+	}
+	reportDir := ReportDirectory(ReportName(options))
+	runDir, err := NewRunDirectory(reportDir, startTime)
+	if err != nil {
+		log.Fatalf("failed to create the run directory: %v", err)
+	}
+	rawSnippets, diagnostics := ReadRawSnippets(tree, options)
+	LogStartInfoToConsole(startTime, len(rawSnippets), options.ProjectName)
+
+	llm, err := NewOllamaLLM()
+	if err != nil {
+		log.Fatalf("failed to connect to ollama: %v", err)
+	}
+	ctx := context.Background()
+
+	metadata := NewRunMetadata(options, startTime)
+	metadata.SourceCommit = sourceCommit
+
+	result := NewRunResult(DocsBaseURL(options.ProjectName, options.DocsURL), options.Source, OpenSnippetStream(options, runDir))
+	result.OnProgress = options.OnProgress
+	if err := CategorizeSnippets(rawSnippets, llm, ctx, isDriverProject, result); err != nil {
+		log.Fatalf("failed to categorize the snippets, so we didn't write the reports: %v", err)
+	}
+	result.Diagnostics = append(diagnostics, result.Diagnostics...)
+	result.SourceCommit = sourceCommit
+	result.Metadata = metadata
+	metadata.Finish(time.Now())
+	if err := WriteRunReports(*result, ReportName(options), runDir, isDriverProject, options.Formats); err != nil {
+		log.Fatalf("failed to write the reports to %s, so we didn't make it the latest run: %v", runDir, err)
+	}
+	FinishRun(reportDir, runDir, options.KeepRuns)
+	if options.OnFinish != nil {
+		options.OnFinish(runDir, result.SnippetCount)
+	}
+	LogFinishInfoToConsole(startTime, result.SnippetCount)
+}
+
+// RunIncrementalCategorization only categorizes the snippets on pages that changed between the BaseRef and the GitRef.
+// It writes a delta report of the new, recategorized and removed snippets, and updates the full reports from the
+// BaseRef run with the changed pages, so the reports for the GitRef are complete.
+func RunIncrementalCategorization(options RunOptions) {
+	if options.GitRef == "" {
+		log.Fatalf("categorizing the changes since %s needs a head ref to compare it with", options.BaseRef)
+	}
+	isDriverProject := IsDriverProject(options.ProjectName)
+	startTime := time.Now()
+	baseReport := options.BaseReport
+	if baseReport == "" {
+		baseReportDir := ReportDirectory(ReportName(RunOptions{ProjectName: options.ProjectName, GitRef: options.BaseRef}))
+		baseReport = SnippetReportPath(ResolveRunDirectory(baseReportDir))
+	}
+	baseSnippets, err := ReadSnippetReport(baseReport)
+	if err != nil {
+		log.Fatalf("failed to read the snippet report for %s, run a full categorization of %s first: %v", options.BaseRef, options.BaseRef, err)
+	}
+	baseSnippets, err = MigrateBaseSnippets(baseSnippets, filepath.Dir(baseReport), options)
+	if err != nil {
+		log.Fatalf("failed to migrate the snippets at %s to taxonomy version %d, run a full categorization instead: %v", options.BaseRef, GetTaxonomy().Version, err)
+	}
+	changes, err := DiffGitRefs(options.GitRepo, options.BaseRef, options.GitRef, options.StartDir)
+	if err != nil {
+		log.Fatalf("failed to diff %s and %s: %v", options.BaseRef, options.GitRef, err)
+	}
+	baseCommit, err := ResolveGitCommit(options.GitRepo, options.BaseRef)
+	if err != nil {
+		log.Fatalf("failed to resolve %s: %v", options.BaseRef, err)
+	}
+	tree, sourceCommit, err := OpenSnippetTree(options)
+	if err != nil {
+		log.Fatalf("failed to open the snippet tree: %v", err)
+	}
+	if closer, isCloser := tree.(io.Closer); isCloser {
+		defer closer.Close()
+	}
+	reportDir := ReportDirectory(ReportName(options))
+	runDir, err := NewRunDirectory(reportDir, startTime)
+	if err != nil {
+		log.Fatalf("failed to create the run directory: %v", err)
+	}
+	fmt.Printf("%d files changed between %s and %s\n", len(changes), options.BaseRef, options.GitRef)
+	// Reading and extracting the snippets is cheap next to categorizing them, so we read the whole tree to apply the
+	// same discovery rules as a full run and to find the pages that include a changed file, and only categorize the
+	// snippets on the changed pages
+	rawSnippets, diagnostics := ReadRawSnippets(tree, options)
+	changedPages := ChangedPages(changes, rawSnippets, options.ProjectName)
+	rawSnippets = FilterRawSnippets(rawSnippets, changedPages)
+	LogStartInfoToConsole(startTime, len(rawSnippets), options.ProjectName)
+
+	llm, err := NewOllamaLLM()
+	if err != nil {
+		log.Fatalf("failed to connect to ollama: %v", err)
+	}
+	ctx := context.Background()
+
+	metadata := NewRunMetadata(options, startTime)
+	metadata.SourceCommit = sourceCommit
+
+	changed := NewRunResult("", options.Source, nil)
+	changed.OnProgress = options.OnProgress
+	if err := CategorizeSnippets(rawSnippets, llm, ctx, isDriverProject, changed); err != nil {
+		log.Fatalf("failed to categorize the snippets, so we didn't write the reports: %v", err)
+	}
+	delta, merged := BuildSnippetDelta(baseSnippets, changed.Snippets, changedPages)
+	delta.Base = &SourceCommit{Repository: options.GitRepo, Ref: options.BaseRef, SHA: baseCommit}
+	delta.Head = sourceCommit
+
+	// The counts and mismatches cover the full, updated list of snippets, not just the ones we categorized in this run
+	result := NewRunResult(DocsBaseURL(options.ProjectName, options.DocsURL), options.Source, OpenSnippetStream(options, runDir))
+	result.Diagnostics = diagnostics
+	result.SourceCommit = sourceCommit
+	result.Metadata = metadata
+	for _, snippet := range merged {
+		result.AddSnippet(snippet)
+	}
+	metadata.Finish(time.Now())
+	delta.ReportHeader = NewReportHeader(metadata)
+	if err := WriteRunReports(*result, ReportName(options), runDir, isDriverProject, options.Formats); err != nil {
+		log.Fatalf("failed to write the reports to %s, so we didn't make it the latest run: %v", runDir, err)
+	}
+	if err := WriteSnippetDeltaReport(delta, runDir); err != nil {
+		log.Fatalf("failed to write the snippet delta report to %s, so we didn't make it the latest run: %v", runDir, err)
+	}
+	FinishRun(reportDir, runDir, options.KeepRuns)
+	if options.OnFinish != nil {
+		options.OnFinish(runDir, changed.SnippetCount)
+	}
+	LogFinishInfoToConsole(startTime, changed.SnippetCount)
+}
+
+// NewOllamaLLM connects to the model in `constants.go`, with the context size we budget our prompts for. We pass the
+// server URL ourselves, so we connect to the server the run metadata records.
+func NewOllamaLLM() (*ollama.LLM, error) {
+	// To change the model, use a different model's string name here
+	return ollama.New(ollama.WithModel(MODEL), ollama.WithRunnerNumCtx(ModelContextTokens), ollama.WithServerURL(OllamaServerURL()))
+}
+
+// OpenSnippetTree opens the directory on disk, or the directory in a git commit, that the run reads snippets from. For a
+// git commit, it also returns the commit we're reading, so we can record it in the report.
+func OpenSnippetTree(options RunOptions) (SnippetTree, *SourceCommit, error) {
+	if options.GitRef == "" {
+		return NewDirTree(options.StartDir), nil, nil
+	}
+	tree, err := NewGitTree(options.GitRepo, options.GitRef, options.StartDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	fmt.Printf("Reading %s at %s (%s)\n", options.GitRepo, options.GitRef, tree.Commit)
+	return tree, &SourceCommit{Repository: options.GitRepo, Ref: options.GitRef, SHA: tree.Commit}, nil
+}
+
+// ReadRawSnippets reads the snippets from the tree, either one snippet per file or extracted from docs pages
+func ReadRawSnippets(tree SnippetTree, options RunOptions) ([]RawSnippet, []IngestionDiagnostic) {
+	switch options.Source {
+	case SourceFiles:
+		return ReadSnippetFiles(tree, options.ProjectName)
+	case SourceDocs:
+		return ExtractDocsSnippets(tree, options.ProjectName)
+	default:
+		log.Fatalf("unknown snippet source %q, expected %q or %q", options.Source, SourceFiles, SourceDocs)
+		return nil, nil
+	}
+}
+
+// ReportName is the name of the directory we write the runs to. Runs against a git ref get their own directory, so
+// categorizing a historical release doesn't overwrite the reports for the current docs.
+func ReportName(options RunOptions) string {
+	if options.GitRef == "" {
+		return options.ProjectName
+	}
+	return options.ProjectName + "@" + strings.ReplaceAll(options.GitRef, "/", "-")
+}
+
+// OpenSnippetStream opens snippets.ndjson in the run directory if the run streams its snippets
+func OpenSnippetStream(options RunOptions, runDir string) *SnippetStreamWriter {
+	if !options.Stream {
+		return nil
+	}
+	stream, err := NewSnippetStreamWriter(filepath.Join(runDir, SnippetStreamReportFile))
+	if err != nil {
+		log.Fatalf("failed to open the snippet stream: %v", err)
+	}
+	return stream
+}
+
+// CategorizeSnippets resolves the language of each snippet, categorizes it, and adds it to the result. If the result
+// has an OnProgress hook, we call it before the first snippet and after each one. It stops at the first snippet the
+// LLM fails to answer for, and returns the error.
+func CategorizeSnippets(rawSnippets []RawSnippet, llm llms.Model, ctx context.Context, isDriverProject bool, result *RunResult) error {
+	//hashes := make(map[string]bool)
+	if result.OnProgress != nil {
+		result.OnProgress(0, len(rawSnippets))
+	}
+	for i, rawSnippet := range rawSnippets {
+		details, err := CategorizeRawSnippet(rawSnippet, llm, ctx, isDriverProject)
+		if err != nil {
+			return fmt.Errorf("failed to categorize a snippet on %s: %w", rawSnippet.Page, err)
+		}
+		//snippetHash := GetSnippetHash(rawSnippet.Contents)
+		//isDuplicate := CheckExampleIsDuplicate(hashes, snippetHash)
+		//if !isDuplicate {
+		//	hashes[snippetHash] = true
+		//}
+		result.AddSnippet(details)
+		if result.SnippetCount%100 == 0 {
+			fmt.Println("Processed ", result.SnippetCount, " snippets")
+		}
+		if result.OnProgress != nil {
+			result.OnProgress(i+1, len(rawSnippets))
+		}
+	}
+	return nil
+}
+
+// AddSnippet adds a categorized snippet to the result, or writes it to the stream, and tallies it in the counts,
+// rollups and language mismatches
+func (result *RunResult) AddSnippet(details SnippetInfo) {
+	if IsLanguageMismatch(details.DeclaredLanguage, details.DetectedLanguage) {
+		result.Mismatches = append(result.Mismatches, LanguageMismatch{
+			Page:             details.Page,
+			DeclaredLanguage: details.DeclaredLanguage,
+			DetectedLanguage: details.DetectedLanguage,
+		})
+	}
+	result.SnippetCount++
+	result.Rollups.Add(details)
+	if result.Stream != nil {
+		if err := result.Stream.Write(details); err != nil {
+			log.Fatalf("failed to write the snippet to %s: %v", result.Stream.FilePath, err)
+		}
+	} else {
+		result.Snippets = append(result.Snippets, details)
+	}
+	if _, exists := result.Counts[details.Category]; !exists {
+		result.Counts[details.Category] = make(map[string]int)
+	}
+	// Increment the language count for the specific category
+	result.Counts[details.Category][details.Language]++
+	if details.Subcategory != "" {
+		if _, exists := result.SubcategoryCounts[details.Category]; !exists {
+			result.SubcategoryCounts[details.Category] = make(map[string]int)
+		}
+		result.SubcategoryCounts[details.Category][details.Subcategory]++
+	}
+	for _, label := range details.SecondaryLabels() {
+		result.SecondaryLabelCounts[label]++
+	}
+	if details.Overridden {
+		result.OverriddenCount++
+	} else if details.LLMCategorized {
+		result.LLMCategorizedCount++
+	} else {
+		result.StringMatchedCount++
+	}
+}
+
+// CategorizeRawSnippet detects the language of a single snippet and categorizes it
+func CategorizeRawSnippet(rawSnippet RawSnippet, llm llms.Model, ctx context.Context, isDriverProject bool) (SnippetInfo, error) {
+	detectedLang := DetectLanguageFromContents(rawSnippet.Contents)
+	lang := ResolveLanguage(rawSnippet.DeclaredLanguage, detectedLang)
+	categorization, err := ProcessSnippet(rawSnippet.Contents, lang, llm, ctx, isDriverProject)
+	if err != nil {
+		return SnippetInfo{}, err
+	}
+	fit := categorization.Fit
+	return SnippetInfo{
+		Page:             rawSnippet.Page,
+		Category:         categorization.Category,
+		Labels:           categorization.Labels,
+		Subcategory:      categorization.Subcategory,
+		Language:         lang,
+		DeclaredLanguage: rawSnippet.DeclaredLanguage,
+		DetectedLanguage: detectedLang,
+		LLMCategorized:   categorization.LLMCategorized(),
+		StartLine:        rawSnippet.StartLine,
+		EndLine:          rawSnippet.EndLine,
+		Caption:          rawSnippet.Caption,
+		Directive:        rawSnippet.Directive,
+		Hash:             GetSnippetHash(rawSnippet.Contents),
+		Truncated:        fit.Truncated,
+		ContextStrategy:  fit.Strategy,
+		//Duplicate: isDuplicate,
+	}, nil
+}
+
+// WriteRunReports writes the snippet, category counts and rollup reports in each of the formats, and the other reports
+// as JSON, to the run directory. It stops at the first report it fails to write, and returns the error, so the caller
+// doesn't promote an incomplete run.
+func WriteRunReports(result RunResult, reportName string, runDir string, isDriverProject bool, formats []string) error {
+	if result.Stream != nil {
+		if err := result.Stream.Close(); err != nil {
+			return fmt.Errorf("failed to write the snippet stream: %v", err)
+		}
+	}
+	data := ReportData{
+		ReportName:       reportName,
+		Snippets:         result.Snippets,
+		SnippetsStreamed: result.Stream != nil,
+		RepoReport:       BuildRepoReport(result, isDriverProject),
+		Rollups:          result.Rollups.Report(),
+		Metadata:         result.Metadata,
+	}
+	data.RepoReport.ReportHeader = NewReportHeader(result.Metadata)
+	data.Rollups.ReportHeader = NewReportHeader(result.Metadata)
+	for _, format := range formats {
+		if err := ReportWriters[format].Write(data, runDir); err != nil {
+			return err
+		}
+	}
+	if err := WriteLanguageMismatchReport(result.Mismatches, result.Metadata, runDir); err != nil {
+		return err
+	}
+	return WriteIngestionDiagnosticsReport(result.Diagnostics, result.Metadata, runDir)
+}
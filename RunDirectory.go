@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// LatestRunLink is the symlink in a report directory that points to the run directory of the last complete run
+const LatestRunLink = "latest"
+
+// ReportDirectory holds every run for one report name, such as output/atlas-cli@v1.20
+func ReportDirectory(reportName string) string {
+	return filepath.Join(BaseReportOutputDir, reportName)
+}
+
+// NewRunDirectory creates the directory a run writes its reports to, named for the time the run started, such as
+// output/atlas-cli/20261019T140502Z. If a run that started in the same second already has the directory, we add a
+// suffix, so two runs never write to the same directory.
+func NewRunDirectory(reportDir string, startTime time.Time) (string, error) {
+	if err := os.MkdirAll(reportDir, 0755); err != nil {
+		return "", err
+	}
+	name := startTime.UTC().Format(RunDirectoryTimeFormat)
+	runDir := filepath.Join(reportDir, name)
+	for attempt := 2; ; attempt++ {
+		err := os.Mkdir(runDir, 0755)
+		if err == nil {
+			return runDir, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		runDir = filepath.Join(reportDir, name+"-"+strconv.Itoa(attempt))
+	}
+}
+
+// WriteFileAtomically writes the data to a temporary file in the same directory, then renames it over the file path.
+// Readers see either the old file or the new one, never a partial file, even if the process dies mid-write.
+func WriteFileAtomically(filePath string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempPath, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tempPath, filePath)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+	}
+	return err
+}
+
+// PromoteRun points the report directory's latest link at the run directory. We only promote a run after it writes all
+// of its reports, so the latest link always points to a complete run.
+func PromoteRun(reportDir string, runDir string) error {
+	linkPath := filepath.Join(reportDir, LatestRunLink)
+	tempLink := filepath.Join(reportDir, "."+LatestRunLink+".tmp-"+strconv.Itoa(os.Getpid()))
+	os.Remove(tempLink)
+	if err := os.Symlink(filepath.Base(runDir), tempLink); err != nil {
+		return err
+	}
+	if err := os.Rename(tempLink, linkPath); err != nil {
+		os.Remove(tempLink)
+		return err
+	}
+	return nil
+}
+
+// ResolveRunDirectory finds the reports in a directory. If it's a report directory with a latest link, that's the last
+// complete run. Otherwise it's a run directory, or a report directory from before we kept each run in its own
+// directory, and the reports are in the directory itself.
+func ResolveRunDirectory(dir string) string {
+	target, err := os.Readlink(filepath.Join(dir, LatestRunLink))
+	if err != nil {
+		return dir
+	}
+	runDir := filepath.Join(dir, target)
+	if info, err := os.Stat(runDir); err == nil && info.IsDir() {
+		return runDir
+	}
+	return dir
+}
+
+// FinishRun promotes the run now that it wrote all of its reports, and removes the runs past the ones we keep
+func FinishRun(reportDir string, runDir string, keepRuns int) {
+	if err := PromoteRun(reportDir, runDir); err != nil {
+		fmt.Printf("Error pointing %s at %s: %v\n", filepath.Join(reportDir, LatestRunLink), runDir, err)
+		return
+	}
+	fmt.Printf("Reports for this run are in %s, linked from %s\n", runDir, filepath.Join(reportDir, LatestRunLink))
+	removed, err := PruneRunDirectories(reportDir, keepRuns)
+	if err != nil {
+		fmt.Println("Error removing old runs:", err)
+	}
+	if len(removed) > 0 {
+		fmt.Printf("Removed %d old runs from %s\n", len(removed), reportDir)
+	}
+}
+
+// RunDirectories lists the run directories in a report directory, oldest first
+func RunDirectories(reportDir string) ([]string, error) {
+	entries, err := os.ReadDir(reportDir)
+	if err != nil {
+		return nil, err
+	}
+	var runs []string
+	for _, entry := range entries {
+		if entry.IsDir() && isRunDirectoryName(entry.Name()) {
+			runs = append(runs, entry.Name())
+		}
+	}
+	// The names start with the UTC start time, so they sort in the order the runs started
+	sort.Strings(runs)
+	return runs, nil
+}
+
+func isRunDirectoryName(name string) bool {
+	timestampLength := len(RunDirectoryTimeFormat)
+	if len(name) < timestampLength {
+		return false
+	}
+	_, err := time.Parse(RunDirectoryTimeFormat, name[:timestampLength])
+	return err == nil
+}
+
+// PruneRunDirectories removes the oldest run directories so only the newest keep runs remain. It never removes the run
+// the latest link points to. A keep of zero or less keeps every run.
+func PruneRunDirectories(reportDir string, keep int) ([]string, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+	runs, err := RunDirectories(reportDir)
+	if err != nil {
+		return nil, err
+	}
+	latest, _ := os.Readlink(filepath.Join(reportDir, LatestRunLink))
+	var removed []string
+	for _, run := range runs[:max(0, len(runs)-keep)] {
+		if run == latest {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(reportDir, run)); err != nil {
+			return removed, fmt.Errorf("failed to remove the run in %s: %v", run, err)
+		}
+		removed = append(removed, run)
+	}
+	return removed, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewRunDirectory(t *testing.T) {
+	reportDir := filepath.Join(t.TempDir(), "proj")
+	startTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	first, err := NewRunDirectory(reportDir, startTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewRunDirectory(reportDir, startTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{filepath.Base(first), filepath.Base(second)}
+	expected := []string{"20240102T030405Z", "20240102T030405Z-2"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q want %q", got, expected)
+	}
+}
+
+func TestWriteFileAtomically(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "snippets.json")
+	if err := os.WriteFile(filePath, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomically(filePath, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "new" {
+		t.Errorf("got %q want %q", got, "new")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files want 1, the temporary file should be gone", len(entries))
+	}
+}
+
+func TestPromoteRunAndResolveRunDirectory(t *testing.T) {
+	reportDir := t.TempDir()
+	if got := ResolveRunDirectory(reportDir); got != reportDir {
+		t.Errorf("got %q want %q for a directory without runs", got, reportDir)
+	}
+	for _, startTime := range []time.Time{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)} {
+		runDir, err := NewRunDirectory(reportDir, startTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := PromoteRun(reportDir, runDir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := ResolveRunDirectory(reportDir)
+	expected := filepath.Join(reportDir, "20240102T000000Z")
+	if got != expected {
+		t.Errorf("got %q want %q", got, expected)
+	}
+}
+
+func TestPruneRunDirectories(t *testing.T) {
+	reportDir := t.TempDir()
+	for _, name := range []string{"20240101T000000Z", "20240102T000000Z", "20240103T000000Z", "20240104T000000Z", "notes"} {
+		if err := os.Mkdir(filepath.Join(reportDir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The latest run is an old one, such as after a newer run failed, so pruning has to keep it
+	if err := PromoteRun(reportDir, filepath.Join(reportDir, "20240101T000000Z")); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := PruneRunDirectories(reportDir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedRemoved := []string{"20240102T000000Z"}
+	if !reflect.DeepEqual(removed, expectedRemoved) {
+		t.Errorf("got %q want %q", removed, expectedRemoved)
+	}
+	got, err := RunDirectories(reportDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"20240101T000000Z", "20240103T000000Z", "20240104T000000Z"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q want %q", got, expected)
+	}
+}
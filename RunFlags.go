@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseRunOptions parses the flags for a categorization run. Both `go run .` and `go run . jobs submit` take these
+// flags, and CommandLineArgs turns the options back into them, so keep the two in step when you add a flag.
+func ParseRunOptions(flags *flag.FlagSet, args []string) (RunOptions, error) {
+	// The defaults come from `constants.go`, so you can still change the constants instead of passing flags
+	projectName := flags.String("project", ProjectName, "the name of the docs project, used in page paths and the report output directory")
+	source := flags.String("source", SourceFiles, "where to find snippets: \"files\" for a one-file-per-snippet tree, or \"docs\" to extract code blocks from reStructuredText and Markdown pages")
+	startDir := flags.String("dir", "", "the directory to categorize (default SnippetsStartDirectory + project), or with -ref, the directory in the repository (default the repository root)")
+	gitRepo := flags.String("repo", ".", "the local git repository to read snippets from when -ref is set")
+	gitRef := flags.String("ref", "", "categorize the snippets in this commit, branch or tag of -repo without checking it out")
+	baseRef := flags.String("base", "", "only categorize the files that changed between this ref and -ref, and write a delta report")
+	baseReport := flags.String("base-report", "", "the snippets.json report from the -base run to update (default the report in the output directory for -base)")
+	docsURL := flags.String("docs-url", "", "the URL of the project's published docs, to link pages in the rollup report (default from DocsBaseURLs in constants.go)")
+	stream := flags.Bool("stream", false, "write each snippet to snippets.ndjson as it's categorized, instead of holding every snippet in memory for snippets.json")
+	keepRuns := flags.Int("keep-runs", RunsToKeep, "how many run directories to keep for the project, or 0 to keep them all")
+	formatList := flags.String("format", "", "comma-separated report formats to write along with JSON: csv, markdown, html")
+	jobID := flags.String("job", "", "the queued job this run is for, so the run records its progress in the job (the job worker sets this)")
+	jobWorker := flags.String("job-worker", "", "the worker that claimed the -job (the job worker sets this)")
+	jobAttempt := flags.Int("job-attempt", 0, "the worker's attempt at the -job (the job worker sets this)")
+	if err := flags.Parse(args); err != nil {
+		return RunOptions{}, err
+	}
+	formats, err := ParseReportFormats(*formatList)
+	if err != nil {
+		return RunOptions{}, err
+	}
+	if *stream {
+		for _, format := range formats {
+			if !containsString(StreamableFormats, format) {
+				return RunOptions{}, fmt.Errorf("the %s reports need every snippet at once, so they don't work with -stream", format)
+			}
+		}
+	}
+	if *startDir == "" && *gitRef == "" {
+		*startDir = SnippetsStartDirectory + *projectName
+	}
+	return RunOptions{
+		ProjectName: *projectName,
+		Source:      *source,
+		StartDir:    *startDir,
+		GitRepo:     *gitRepo,
+		GitRef:      *gitRef,
+		BaseRef:     *baseRef,
+		BaseReport:  *baseReport,
+		DocsURL:     *docsURL,
+		Stream:      *stream,
+		KeepRuns:    *keepRuns,
+		Formats:     formats,
+		JobID:       *jobID,
+		JobWorker:   *jobWorker,
+		JobAttempt:  *jobAttempt,
+	}, nil
+}
+
+// CommandLineArgs turns the options back into the flags that ParseRunOptions parses, so a job worker can start the run
+func (options RunOptions) CommandLineArgs() []string {
+	args := []string{
+		"-project", options.ProjectName,
+		"-source", options.Source,
+		"-dir", options.StartDir,
+		"-repo", options.GitRepo,
+		"-keep-runs", strconv.Itoa(options.KeepRuns),
+	}
+	optional := [][2]string{
+		{"-ref", options.GitRef},
+		{"-base", options.BaseRef},
+		{"-base-report", options.BaseReport},
+		{"-docs-url", options.DocsURL},
+		{"-format", strings.Join(options.Formats, ",")},
+		{"-job", options.JobID},
+		{"-job-worker", options.JobWorker},
+	}
+	for _, flagValue := range optional {
+		if flagValue[1] != "" {
+			args = append(args, flagValue[0], flagValue[1])
+		}
+	}
+	if options.JobAttempt != 0 {
+		args = append(args, "-job-attempt", strconv.Itoa(options.JobAttempt))
+	}
+	if options.Stream {
+		args = append(args, "-stream")
+	}
+	return args
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+const (
+	// ReportSchemaVersion Bump this when a report changes in a way that readers need to know about, and update the
+	// schemas in the `schemas` directory
+	ReportSchemaVersion = 3
+	// LegacySchemaVersion is the version of the reports from before we recorded a schema version, where snippets.json
+	// was a bare array of snippets
+	LegacySchemaVersion = 1
+)
+
+// ReportHeader comes first in every JSON report, so readers can tell which version of a report they have and what
+// produced it
+type ReportHeader struct {
+	SchemaVersion int          `json:"schema_version"`
+	Metadata      *RunMetadata `json:"metadata,omitempty"`
+}
+
+func NewReportHeader(metadata *RunMetadata) ReportHeader {
+	return ReportHeader{SchemaVersion: ReportSchemaVersion, Metadata: metadata}
+}
+
+// RunMetadata records what produced a report: the tool and model versions, the prompt, the input, and when it ran.
+// If we migrated the reports to a newer taxonomy, the TaxonomyVersion is the one the categories are in now, and the
+// MigratedFromTaxonomyVersion is the one the run categorized with.
+type RunMetadata struct {
+	ToolVersion                 string        `json:"tool_version"`
+	Model                       string        `json:"model"`
+	ModelDigest                 string        `json:"model_digest,omitempty"`
+	PromptFingerprint           string        `json:"prompt_fingerprint"`
+	TaxonomyVersion             int           `json:"taxonomy_version,omitempty"`
+	MigratedFromTaxonomyVersion int           `json:"migrated_from_taxonomy_version,omitempty"`
+	ContextTokens               int           `json:"context_tokens"`
+	OversizedSnippetStrategy    string        `json:"oversized_snippet_strategy"`
+	ProjectName                 string        `json:"project_name"`
+	Source                      string        `json:"source"`
+	InputDirectory              string        `json:"input_directory"`
+	SourceCommit                *SourceCommit `json:"source_commit,omitempty"`
+	BaseRef                     string        `json:"base_ref,omitempty"`
+	StartTime                   time.Time     `json:"start_time"`
+	EndTime                     time.Time     `json:"end_time"`
+	DurationSeconds             float64       `json:"duration_seconds"`
+}
+
+// NewRunMetadata records everything we know at the start of a run. We look up the model digest from ollama, so if
+// someone pulls a newer version of the model, the reports show it.
+func NewRunMetadata(options RunOptions, startTime time.Time) *RunMetadata {
+	inputDirectory := options.StartDir
+	if options.GitRef == "" {
+		if absolutePath, err := filepath.Abs(options.StartDir); err == nil {
+			inputDirectory = absolutePath
+		}
+	}
+	digest, err := OllamaModelDigest(MODEL)
+	if err != nil {
+		fmt.Printf("failed to look up the digest of the %s model, the reports won't record it: %v\n", MODEL, err)
+	}
+	return &RunMetadata{
+		ToolVersion:              ToolVersion(),
+		Model:                    MODEL,
+		ModelDigest:              digest,
+		PromptFingerprint:        PromptFingerprint(),
+		TaxonomyVersion:          GetTaxonomy().Version,
+		ContextTokens:            ModelContextTokens,
+		OversizedSnippetStrategy: OversizedSnippetStrategy,
+		ProjectName:              options.ProjectName,
+		Source:                   options.Source,
+		InputDirectory:           inputDirectory,
+		BaseRef:                  options.BaseRef,
+		StartTime:                startTime,
+	}
+}
+
+// Finish records when the run ended
+func (m *RunMetadata) Finish(endTime time.Time) {
+	m.EndTime = endTime
+	m.DurationSeconds = endTime.Sub(m.StartTime).Seconds()
+}
+
+// ToolVersion is the module version, or the git commit we built from. `go build` records the commit, but `go run`
+// doesn't, so runs from `go run` report "(devel)".
+func ToolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	version := info.Main.Version
+	revision, modified := "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return version
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return version + " " + revision
+}
+
+// PromptFingerprint hashes every prompt we might send the LLM, so two reports with the same fingerprint used the
+// same prompts
+func PromptFingerprint() string {
+	hasher := sha256.New()
+	categoryTaxonomy := GetTaxonomy()
+	for _, languageCategory := range categoryTaxonomy.PromptLanguageCategories() {
+		hasher.Write([]byte(BuildCategoryPrompt("", categoryTaxonomy.Question(languageCategory))))
+	}
+	return hex.EncodeToString(hasher.Sum(nil))[:16]
+}
+
+// OllamaServerURL finds the ollama server the same way the ollama client does, from OLLAMA_HOST. If OLLAMA_HOST
+// doesn't have a port, we keep its host and add the default port for its scheme.
+func OllamaServerURL() string {
+	host := strings.TrimSpace(os.Getenv("OLLAMA_HOST"))
+	defaultPort := "11434"
+	scheme, hostport, found := strings.Cut(host, "://")
+	switch {
+	case !found:
+		scheme, hostport = "http", host
+	case scheme == "http":
+		defaultPort = "80"
+	case scheme == "https":
+		defaultPort = "443"
+	}
+	hostport, urlPath, _ := strings.Cut(hostport, "/")
+	hostname, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		hostname, port = "127.0.0.1", defaultPort
+		if ip := net.ParseIP(strings.Trim(hostport, "[]")); ip != nil {
+			hostname = ip.String()
+		} else if hostport != "" {
+			hostname = hostport
+		}
+	}
+	serverURL := scheme + "://" + net.JoinHostPort(hostname, port)
+	if urlPath != "" {
+		serverURL += "/" + urlPath
+	}
+	return serverURL
+}
+
+// OllamaModelDigest asks ollama for the digest of the model it has for the given name
+func OllamaModelDigest(model string) (string, error) {
+	client := http.Client{Timeout: 5 * time.Second}
+	response, err := client.Get(OllamaServerURL() + "/api/tags")
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("ollama returned %s", response.Status)
+	}
+	var tags struct {
+		Models []struct {
+			Name   string `json:"name"`
+			Digest string `json:"digest"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&tags); err != nil {
+		return "", err
+	}
+	// A model name without a tag means the latest tag
+	if !strings.Contains(model, ":") {
+		model += ":latest"
+	}
+	for _, tagged := range tags.Models {
+		if tagged.Name == model {
+			return tagged.Digest, nil
+		}
+	}
+	return "", fmt.Errorf("ollama doesn't have the %s model", model)
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestOllamaServerURL(t *testing.T) {
+	cases := []struct {
+		host string
+		want string
+	}{
+		{"", "http://127.0.0.1:11434"},
+		{"gpu-box", "http://gpu-box:11434"},
+		{"gpu-box:8080", "http://gpu-box:8080"},
+		{"10.0.0.5", "http://10.0.0.5:11434"},
+		{"[::1]", "http://[::1]:11434"},
+		{"https://ollama.example.com", "https://ollama.example.com:443"},
+		{"http://gpu-box/ollama", "http://gpu-box:80/ollama"},
+	}
+	for _, c := range cases {
+		t.Setenv("OLLAMA_HOST", c.host)
+		got := OllamaServerURL()
+		if got != c.want {
+			t.Errorf("OllamaServerURL() with OLLAMA_HOST=%q got %q want %q", c.host, got, c.want)
+		}
+	}
+}
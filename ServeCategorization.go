@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/tmc/langchaingo/llms"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// CategorizeRequest is a snippet to categorize. The Language is the language the writer declared, such as a code-block
+// tag like `sh`, and we detect it from the contents if it's empty. Set the ProjectType to "driver" for driver
+// docs, or set the Project to the docs project's name, and we look up whether it's a driver project.
+type CategorizeRequest struct {
+	Contents    string `json:"contents"`
+	Language    string `json:"language,omitempty"`
+	Project     string `json:"project,omitempty"`
+	ProjectType string `json:"project_type,omitempty"`
+}
+
+// CategorizeResponse is the category of a snippet, and how we got it
+type CategorizeResponse struct {
+	Category    string   `json:"category"`
+	Labels      []string `json:"labels,omitempty"`
+	Subcategory string   `json:"subcategory,omitempty"`
+	Language    string   `json:"language"`
+	Path        string   `json:"path"`
+	Rule        string   `json:"rule,omitempty"`
+	Confidence  float64  `json:"confidence"`
+	Truncated   bool     `json:"truncated"`
+}
+
+type BatchCategorizeRequest struct {
+	Snippets []CategorizeRequest `json:"snippets"`
+}
+
+// BatchCategorizeResponse has a result for each snippet in the request, in the same order
+type BatchCategorizeResponse struct {
+	Results []CategorizeResponse `json:"results"`
+}
+
+const ProjectTypeDriver = "driver"
+
+// IsDriverRequest works out whether the snippet is from a driver project
+func (r CategorizeRequest) IsDriverRequest() bool {
+	if r.ProjectType != "" {
+		return r.ProjectType == ProjectTypeDriver
+	}
+	return IsDriverProject(r.Project)
+}
+
+// CategorizationServer serves the categorizer over HTTP, so docs tooling and editor plugins can categorize snippets
+// while writers author them. We categorize each snippet with its request's context, so a client that disconnects, or
+// the server shutting down, stops the LLM calls for the request. If the LLM fails, we respond with a 502 rather than
+// stop serving.
+type CategorizationServer struct {
+	categorize func(context.Context, CategorizeRequest) (CategorizeResponse, error)
+	metrics    *ServerMetrics
+}
+
+// StatusClientClosedRequest is the status we count in the metrics for a request the client gave up on, or that the
+// server stopped when it shut down, before we could respond
+const StatusClientClosedRequest = 499
+
+// NewCategorizationServer categorizes snippets the same way a run does, with string matching first, then the LLM
+func NewCategorizationServer(llm llms.Model) *CategorizationServer {
+	return &CategorizationServer{
+		categorize: func(ctx context.Context, request CategorizeRequest) (CategorizeResponse, error) {
+			return CategorizeRequestedSnippet(request, llm, ctx)
+		},
+		metrics: NewServerMetrics(),
+	}
+}
+
+// CategorizeRequestedSnippet resolves the snippet's language and categorizes it
+func CategorizeRequestedSnippet(request CategorizeRequest, llm llms.Model, ctx context.Context) (CategorizeResponse, error) {
+	declaredLang := GetLanguageRegistry().LanguageForTag(request.Language)
+	lang := ResolveLanguage(declaredLang, DetectLanguageFromContents(request.Contents))
+	categorization, err := ProcessSnippet(request.Contents, lang, llm, ctx, request.IsDriverRequest())
+	if err != nil {
+		return CategorizeResponse{}, err
+	}
+	return CategorizeResponse{
+		Category:    categorization.Category,
+		Labels:      categorization.Labels,
+		Subcategory: categorization.Subcategory,
+		Language:    lang,
+		Path:        categorization.Path,
+		Rule:        categorization.Rule,
+		Confidence:  categorization.Confidence,
+		Truncated:   categorization.Fit.Truncated,
+	}, nil
+}
+
+// RunServeCommand connects to ollama and serves the categorization API until the process gets an interrupt or a
+// SIGTERM. Then it cancels the requests in flight, and waits up to ServeShutdownSeconds for them to finish.
+func RunServeCommand(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	address := flags.String("addr", DefaultServeAddress, "the address to listen on")
+	flags.Parse(args)
+	llm, err := NewOllamaLLM()
+	if err != nil {
+		log.Fatalf("failed to connect to ollama: %v", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	server := &http.Server{
+		Addr:    *address,
+		Handler: NewCategorizationServer(llm).Handler(),
+		// Every request's context comes from this one, so shutting down cancels the LLM calls in flight
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), ServeShutdownSeconds*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			fmt.Println("Error shutting down the server:", err)
+		}
+	}()
+	fmt.Printf("Serving the categorization API on http://%s\n", *address)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	<-shutdown
+	fmt.Println("Stopped serving the categorization API")
+}
+
+// Handler routes the API endpoints:
+//
+//	POST /categorize        categorize one snippet
+//	POST /categorize/batch  categorize up to MaxBatchSnippets snippets
+//	GET  /metrics           request and categorization counters in the Prometheus text format
+func (s *CategorizationServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /categorize", s.handleCategorize)
+	mux.HandleFunc("POST /categorize/batch", s.handleBatch)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	return mux
+}
+
+func (s *CategorizationServer) handleCategorize(writer http.ResponseWriter, request *http.Request) {
+	var body CategorizeRequest
+	if !s.decodeRequest(writer, request, "categorize", &body) {
+		return
+	}
+	if strings.TrimSpace(body.Contents) == "" {
+		s.writeError(writer, "categorize", http.StatusBadRequest, "the snippet needs contents")
+		return
+	}
+	response, err := s.categorizeAndCount(request.Context(), body)
+	if err != nil {
+		s.writeCategorizeError(writer, request.Context(), "categorize", fmt.Sprintf("failed to categorize the snippet: %v", err))
+		return
+	}
+	s.writeJSON(writer, "categorize", response)
+}
+
+func (s *CategorizationServer) handleBatch(writer http.ResponseWriter, request *http.Request) {
+	var body BatchCategorizeRequest
+	if !s.decodeRequest(writer, request, "batch", &body) {
+		return
+	}
+	if len(body.Snippets) > MaxBatchSnippets {
+		s.writeError(writer, "batch", http.StatusRequestEntityTooLarge, fmt.Sprintf("a batch can have at most %d snippets", MaxBatchSnippets))
+		return
+	}
+	for i, snippet := range body.Snippets {
+		if strings.TrimSpace(snippet.Contents) == "" {
+			s.writeError(writer, "batch", http.StatusBadRequest, fmt.Sprintf("snippet %d needs contents", i+1))
+			return
+		}
+	}
+	response := BatchCategorizeResponse{Results: make([]CategorizeResponse, 0, len(body.Snippets))}
+	for i, snippet := range body.Snippets {
+		result, err := s.categorizeAndCount(request.Context(), snippet)
+		if err != nil {
+			s.writeCategorizeError(writer, request.Context(), "batch", fmt.Sprintf("failed to categorize snippet %d: %v", i+1, err))
+			return
+		}
+		response.Results = append(response.Results, result)
+	}
+	s.writeJSON(writer, "batch", response)
+}
+
+func (s *CategorizationServer) handleMetrics(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.metrics.Write(writer)
+}
+
+// categorizeAndCount categorizes the snippet and counts it in the metrics. If the LLM failed, or the context was
+// cancelled before we finished, we don't count the snippet, and return the error.
+func (s *CategorizationServer) categorizeAndCount(ctx context.Context, request CategorizeRequest) (CategorizeResponse, error) {
+	startTime := time.Now()
+	response, err := s.categorize(ctx, request)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return response, err
+	}
+	s.metrics.CountSnippet(response, time.Since(startTime))
+	return response, nil
+}
+
+// writeCategorizeError responds to a request we failed to categorize a snippet for. If the client gave up, or the
+// server is shutting down, nobody reads the response, so we only count the request. Otherwise the LLM failed, so we
+// respond with a 502.
+func (s *CategorizationServer) writeCategorizeError(writer http.ResponseWriter, ctx context.Context, endpoint string, message string) {
+	if ctx.Err() != nil {
+		s.metrics.CountRequest(endpoint, StatusClientClosedRequest)
+		return
+	}
+	fmt.Println("Error categorizing a snippet:", message)
+	s.writeError(writer, endpoint, http.StatusBadGateway, message)
+}
+
+func (s *CategorizationServer) decodeRequest(writer http.ResponseWriter, request *http.Request, endpoint string, body any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(writer, request.Body, MaxServeRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(body); err != nil {
+		s.writeError(writer, endpoint, http.StatusBadRequest, fmt.Sprintf("the request isn't valid: %v", err))
+		return false
+	}
+	return true
+}
+
+func (s *CategorizationServer) writeJSON(writer http.ResponseWriter, endpoint string, body any) {
+	s.metrics.CountRequest(endpoint, http.StatusOK)
+	writer.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(writer).Encode(body); err != nil {
+		fmt.Println("Error writing the response:", err)
+	}
+}
+
+func (s *CategorizationServer) writeError(writer http.ResponseWriter, endpoint string, status int, message string) {
+	s.metrics.CountRequest(endpoint, status)
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(map[string]string{"error": message})
+}
+
+// ServerMetrics counts the requests and the snippets we categorize, by how we categorized them
+type ServerMetrics struct {
+	mutex            sync.Mutex
+	requests         map[string]int
+	snippets         map[string]int
+	categories       map[string]int
+	truncated        int
+	categorizeTime   time.Duration
+	categorizedCount int
+}
+
+func NewServerMetrics() *ServerMetrics {
+	return &ServerMetrics{
+		requests:   make(map[string]int),
+		snippets:   make(map[string]int),
+		categories: make(map[string]int),
+	}
+}
+
+func (m *ServerMetrics) CountRequest(endpoint string, status int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.requests[fmt.Sprintf(`endpoint=%q,status="%d"`, endpoint, status)]++
+}
+
+func (m *ServerMetrics) CountSnippet(response CategorizeResponse, duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.snippets[fmt.Sprintf("path=%q", response.Path)]++
+	m.categories[fmt.Sprintf("category=%q", response.Category)]++
+	if response.Truncated {
+		m.truncated++
+	}
+	m.categorizeTime += duration
+	m.categorizedCount++
+}
+
+// Write writes the metrics in the Prometheus text format, with the labels sorted so the output is stable
+func (m *ServerMetrics) Write(writer io.Writer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	writeCounter := func(name string, help string, values map[string]int) {
+		fmt.Fprintf(writer, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		labels := make([]string, 0, len(values))
+		for label := range values {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		for _, label := range labels {
+			fmt.Fprintf(writer, "%s{%s} %d\n", name, label, values[label])
+		}
+	}
+	writeCounter("categorizer_requests_total", "HTTP requests by endpoint and status.", m.requests)
+	writeCounter("categorizer_snippets_total", "Snippets categorized by string matching or the LLM.", m.snippets)
+	writeCounter("categorizer_categories_total", "Snippets categorized by category.", m.categories)
+	fmt.Fprintf(writer, "# HELP categorizer_truncated_snippets_total Snippets that didn't fit in the model context.\n# TYPE categorizer_truncated_snippets_total counter\ncategorizer_truncated_snippets_total %d\n", m.truncated)
+	fmt.Fprintf(writer, "# HELP categorizer_categorize_seconds Time spent categorizing snippets.\n# TYPE categorizer_categorize_seconds summary\n")
+	fmt.Fprintf(writer, "categorizer_categorize_seconds_sum %g\ncategorizer_categorize_seconds_count %d\n", m.categorizeTime.Seconds(), m.categorizedCount)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/tmc/langchaingo/llms"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeCategorizeStringMatch(t *testing.T) {
+	// String matches never reach the LLM, so the server doesn't need one
+	server := httptest.NewServer(NewCategorizationServer(nil).Handler())
+	defer server.Close()
+	response, err := http.Post(server.URL+"/categorize", "application/json", strings.NewReader(`{"contents": "atlas clusters list", "language": "sh"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var got CategorizeResponse
+	if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	expected := CategorizeResponse{Category: AtlasCLICommand, Subcategory: SubcategoryUsage, Language: SHELL, Path: PathStringMatch, Rule: `starts with "atlas "`, Confidence: 1}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v want %v", got, expected)
+	}
+}
+
+func TestServeBatchAndMetrics(t *testing.T) {
+	categorizationServer := &CategorizationServer{
+		categorize: func(ctx context.Context, request CategorizeRequest) (CategorizeResponse, error) {
+			return CategorizeResponse{Category: UsageExample, Path: PathLLM, Confidence: LLMConfidence(request.IsDriverRequest())}, nil
+		},
+		metrics: NewServerMetrics(),
+	}
+	server := httptest.NewServer(categorizationServer.Handler())
+	defer server.Close()
+	body := `{"snippets": [{"contents": "a", "project": "pymongo"}, {"contents": "b", "project_type": "docs"}]}`
+	response, err := http.Post(server.URL+"/categorize/batch", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var got BatchCategorizeResponse
+	if err := json.NewDecoder(response.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Results) != 2 || got.Results[0].Confidence != DriverLLMConfidence || got.Results[1].Confidence != OtherLLMConfidence {
+		t.Errorf("got %v want a driver result and a docs result", got.Results)
+	}
+
+	badResponse, err := http.Post(server.URL+"/categorize", "application/json", strings.NewReader(`{"contents": ""}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	badResponse.Body.Close()
+	if badResponse.StatusCode != http.StatusBadRequest {
+		t.Errorf("got status %d want %d for a snippet without contents", badResponse.StatusCode, http.StatusBadRequest)
+	}
+
+	metricsResponse, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer metricsResponse.Body.Close()
+	metrics, err := io.ReadAll(metricsResponse.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`categorizer_requests_total{endpoint="batch",status="200"} 1`,
+		`categorizer_requests_total{endpoint="categorize",status="400"} 1`,
+		`categorizer_snippets_total{path="llm"} 2`,
+		`categorizer_categories_total{category="Task-based usage"} 2`,
+	} {
+		if !strings.Contains(string(metrics), expected) {
+			t.Errorf("expected the metrics to contain %q, got:\n%s", expected, string(metrics))
+		}
+	}
+}
+
+func TestServeCancelsCategorizationWhenTheClientDisconnects(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	categorizationServer := &CategorizationServer{
+		categorize: func(ctx context.Context, request CategorizeRequest) (CategorizeResponse, error) {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+			return CategorizeResponse{}, ctx.Err()
+		},
+		metrics: NewServerMetrics(),
+	}
+	server := httptest.NewServer(categorizationServer.Handler())
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/categorize", strings.NewReader(`{"contents": "a"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		<-started
+		cancel()
+	}()
+	if _, err := http.DefaultClient.Do(request); err == nil {
+		t.Errorf("got a response want the request cancelled")
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the categorizer's context wasn't cancelled when the client disconnected")
+	}
+	var metrics strings.Builder
+	// The handler counts the request after the categorizer returns
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		metrics.Reset()
+		categorizationServer.metrics.Write(&metrics)
+		if strings.Contains(metrics.String(), `status="499"`) {
+			break
+		}
+	}
+	if !strings.Contains(metrics.String(), `categorizer_requests_total{endpoint="categorize",status="499"} 1`) || strings.Contains(metrics.String(), `categorizer_snippets_total{`) {
+		t.Errorf("got metrics %s, want the cancelled request counted and no categorized snippet", metrics.String())
+	}
+}
+
+// failingLLM is an LLM that can't be reached, like an ollama server that isn't running
+type failingLLM struct{}
+
+func (failingLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (failingLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return "", errors.New("connection refused")
+}
+
+func TestServeRespondsWithBadGatewayWhenTheLLMFails(t *testing.T) {
+	categorizationServer := NewCategorizationServer(failingLLM{})
+	server := httptest.NewServer(categorizationServer.Handler())
+	defer server.Close()
+	for _, c := range []struct {
+		path string
+		body string
+	}{
+		{"/categorize", `{"contents": "db.things.find()", "language": "javascript"}`},
+		{"/categorize/batch", `{"snippets": [{"contents": "atlas clusters list"}, {"contents": "db.things.find()", "language": "javascript"}]}`},
+	} {
+		response, err := http.Post(server.URL+c.path, "application/json", strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]string
+		err = json.NewDecoder(response.Body).Decode(&got)
+		response.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode != http.StatusBadGateway {
+			t.Errorf("got status %d want %d for %s", response.StatusCode, http.StatusBadGateway, c.path)
+		}
+		if !strings.Contains(got["error"], "connection refused") {
+			t.Errorf("got error %q want the LLM's error for %s", got["error"], c.path)
+		}
+	}
+	var metrics strings.Builder
+	categorizationServer.metrics.Write(&metrics)
+	for _, expected := range []string{
+		`categorizer_requests_total{endpoint="batch",status="502"} 1`,
+		`categorizer_requests_total{endpoint="categorize",status="502"} 1`,
+		`categorizer_snippets_total{path="string-match"} 1`,
+	} {
+		if !strings.Contains(metrics.String(), expected) {
+			t.Errorf("expected the metrics to contain %q, got:\n%s", expected, metrics.String())
+		}
+	}
+	if strings.Contains(metrics.String(), `path="llm"`) {
+		t.Errorf("got metrics %s, want no snippets the LLM categorized", metrics.String())
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"sort"
+)
+
+// SnippetDelta describes how the snippets changed between two commits: the snippets that are new in the head commit,
+// grouped by category, the snippets whose category changed, and the snippets the head commit removed
+type SnippetDelta struct {
+	ReportHeader
+	Base          *SourceCommit            `json:"base"`
+	Head          *SourceCommit            `json:"head"`
+	ChangedPages  int                      `json:"changed_pages"`
+	NewSnippets   map[string][]SnippetInfo `json:"new_snippets"`
+	Recategorized []RecategorizedSnippet   `json:"recategorized_snippets"`
+	Removed       []SnippetInfo            `json:"removed_snippets"`
+}
+
+// RecategorizedSnippet is a snippet on a changed page that was in a different category in the base report
+type RecategorizedSnippet struct {
+	Page             string `json:"page"`
+	StartLine        int    `json:"start_line,omitempty"`
+	Language         string `json:"language"`
+	PreviousCategory string `json:"previous_category"`
+	Category         string `json:"category"`
+}
+
+// ChangedPages converts the files that changed between two commits to the pages they appear as in the reports. A page
+// that includes a changed file, such as with a `literalinclude`, changed too, even if its own source didn't.
+func ChangedPages(changes []ChangedFile, rawSnippets []RawSnippet, projectName string) map[string]bool {
+	pages := make(map[string]bool)
+	changedFiles := make(map[string]bool)
+	for _, change := range changes {
+		pages[PagePath(change.Path, projectName)] = true
+		changedFiles[change.Path] = true
+	}
+	for _, rawSnippet := range rawSnippets {
+		if rawSnippet.IncludePath != "" && changedFiles[rawSnippet.IncludePath] {
+			pages[rawSnippet.Page] = true
+		}
+	}
+	return pages
+}
+
+// FilterRawSnippets keeps the snippets on the given pages
+func FilterRawSnippets(rawSnippets []RawSnippet, pages map[string]bool) []RawSnippet {
+	var filtered []RawSnippet
+	for _, rawSnippet := range rawSnippets {
+		if pages[rawSnippet.Page] {
+			filtered = append(filtered, rawSnippet)
+		}
+	}
+	return filtered
+}
+
+// BuildSnippetDelta compares the base snippets on the changed pages with the head snippets we categorized from those
+// pages. It also returns the updated full list of snippets: the base snippets on pages that didn't change, plus the
+// head snippets. The head snippets must only come from the changed pages.
+func BuildSnippetDelta(baseSnippets []SnippetInfo, headSnippets []SnippetInfo, changedPages map[string]bool) (SnippetDelta, []SnippetInfo) {
+	delta := SnippetDelta{
+		ChangedPages:  len(changedPages),
+		NewSnippets:   make(map[string][]SnippetInfo),
+		Recategorized: []RecategorizedSnippet{},
+		Removed:       []SnippetInfo{},
+	}
+	var merged []SnippetInfo
+	basePages := make(map[string][]SnippetInfo)
+	for _, snippet := range baseSnippets {
+		if changedPages[snippet.Page] {
+			basePages[snippet.Page] = append(basePages[snippet.Page], snippet)
+		} else {
+			merged = append(merged, snippet)
+		}
+	}
+	headPages := make(map[string][]SnippetInfo)
+	for _, snippet := range headSnippets {
+		headPages[snippet.Page] = append(headPages[snippet.Page], snippet)
+	}
+	merged = append(merged, headSnippets...)
+
+	pages := make([]string, 0, len(changedPages))
+	for page := range changedPages {
+		pages = append(pages, page)
+	}
+	sort.Strings(pages)
+	for _, page := range pages {
+		pairs, removed, added := pairSnippets(basePages[page], headPages[page])
+		for _, pair := range pairs {
+			if pair.base.Category != pair.head.Category {
+				delta.Recategorized = append(delta.Recategorized, RecategorizedSnippet{
+					Page:             page,
+					StartLine:        pair.head.StartLine,
+					Language:         pair.head.Language,
+					PreviousCategory: pair.base.Category,
+					Category:         pair.head.Category,
+				})
+			}
+		}
+		delta.Removed = append(delta.Removed, removed...)
+		for _, snippet := range added {
+			delta.NewSnippets[snippet.Category] = append(delta.NewSnippets[snippet.Category], snippet)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].Page != merged[j].Page {
+			return merged[i].Page < merged[j].Page
+		}
+		return merged[i].StartLine < merged[j].StartLine
+	})
+	return delta, merged
+}
+
+type snippetPair struct {
+	base SnippetInfo
+	head SnippetInfo
+}
+
+// pairSnippets matches the snippets on a page in the base report with the snippets on the same page in the head commit.
+// Snippets with the same contents are the same snippet, even if they moved. We pair the rest in the order they appear
+// on the page, so an edited code block is the same snippet as before. Whatever is left over was removed or added.
+func pairSnippets(base []SnippetInfo, head []SnippetInfo) ([]snippetPair, []SnippetInfo, []SnippetInfo) {
+	baseMatched := make([]bool, len(base))
+	headMatched := make([]bool, len(head))
+	var pairs []snippetPair
+	for h, headSnippet := range head {
+		for b, baseSnippet := range base {
+			if !baseMatched[b] && headSnippet.Hash != "" && headSnippet.Hash == baseSnippet.Hash {
+				pairs = append(pairs, snippetPair{base: baseSnippet, head: headSnippet})
+				baseMatched[b] = true
+				headMatched[h] = true
+				break
+			}
+		}
+	}
+	b := 0
+	var added []SnippetInfo
+	for h, headSnippet := range head {
+		if headMatched[h] {
+			continue
+		}
+		for b < len(base) && baseMatched[b] {
+			b++
+		}
+		if b < len(base) {
+			pairs = append(pairs, snippetPair{base: base[b], head: headSnippet})
+			baseMatched[b] = true
+			continue
+		}
+		added = append(added, headSnippet)
+	}
+	var removed []SnippetInfo
+	for i, baseSnippet := range base {
+		if !baseMatched[i] {
+			removed = append(removed, baseSnippet)
+		}
+	}
+	return pairs, removed, added
+}
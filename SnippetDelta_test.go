@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildSnippetDelta(t *testing.T) {
+	baseSnippets := []SnippetInfo{
+		{Page: "proj/a.txt", Category: UsageExample, Language: GO, Hash: "one", StartLine: 1},
+		{Page: "proj/a.txt", Category: SyntaxExample, Language: SHELL, Hash: "two", StartLine: 10},
+		{Page: "proj/b.txt", Category: ExampleReturnObject, Language: JSON, Hash: "three"},
+		{Page: "proj/c.txt", Category: SyntaxExample, Language: GO, Hash: "four"},
+	}
+	// On a.txt, the first snippet moved down the page unchanged, the second was edited and is now a usage example, and
+	// there's a new snippet. c.txt was removed.
+	headSnippets := []SnippetInfo{
+		{Page: "proj/a.txt", Category: UsageExample, Language: SHELL, Hash: "two-edited", StartLine: 3},
+		{Page: "proj/a.txt", Category: UsageExample, Language: GO, Hash: "one", StartLine: 12},
+		{Page: "proj/a.txt", Category: ExampleConfigurationObject, Language: YAML, Hash: "five", StartLine: 20},
+	}
+	changedPages := map[string]bool{"proj/a.txt": true, "proj/c.txt": true}
+	delta, merged := BuildSnippetDelta(baseSnippets, headSnippets, changedPages)
+
+	expectedRecategorized := []RecategorizedSnippet{
+		{Page: "proj/a.txt", StartLine: 3, Language: SHELL, PreviousCategory: SyntaxExample, Category: UsageExample},
+	}
+	if !reflect.DeepEqual(delta.Recategorized, expectedRecategorized) {
+		t.Errorf("got %v want %v", delta.Recategorized, expectedRecategorized)
+	}
+	if len(delta.NewSnippets) != 1 || len(delta.NewSnippets[ExampleConfigurationObject]) != 1 {
+		t.Errorf("got %v want the YAML snippet as the only new snippet", delta.NewSnippets)
+	}
+	if len(delta.Removed) != 1 || delta.Removed[0].Page != "proj/c.txt" {
+		t.Errorf("got %v want the snippet on c.txt as the only removed snippet", delta.Removed)
+	}
+	var gotHashes []string
+	for _, snippet := range merged {
+		gotHashes = append(gotHashes, snippet.Hash)
+	}
+	expectedHashes := []string{"two-edited", "one", "five", "three"}
+	if !reflect.DeepEqual(gotHashes, expectedHashes) {
+		t.Errorf("got %v want %v", gotHashes, expectedHashes)
+	}
+}
+
+func TestChangedPagesIncludesPagesWithAChangedInclude(t *testing.T) {
+	root := writeTestTree(t, map[string]string{
+		"source/insert.txt":               ".. literalinclude:: /includes/insert.py\n   :language: python\n",
+		"source/find.txt":                 ".. io-code-block::\n\n   .. input:: /includes/find.py\n      :language: python\n",
+		"source/other.txt":                ".. code-block:: python\n\n   print(1)\n",
+		"source/includes/insert.py":       "collection.insert_one({})\n",
+		"source/includes/find.py":         "collection.find_one()\n",
+		"source/includes/not-included.py": "print(2)\n",
+	})
+	rawSnippets, _ := ExtractDocsSnippets(NewDirTree(root), "proj")
+	if len(rawSnippets) != 3 {
+		t.Fatalf("got %d snippets want 3", len(rawSnippets))
+	}
+	// Only the included files changed, not the pages that include them
+	changes := []ChangedFile{
+		{Path: "source/includes/insert.py", Status: FileModified},
+		{Path: "source/includes/find.py", Status: FileModified},
+		{Path: "source/includes/not-included.py", Status: FileModified},
+	}
+	changedPages := ChangedPages(changes, rawSnippets, "proj")
+	for _, page := range []string{"proj/source/insert.txt", "proj/source/find.txt"} {
+		if !changedPages[page] {
+			t.Errorf("expected %s to have changed, got %v", page, changedPages)
+		}
+	}
+	if changedPages["proj/source/other.txt"] {
+		t.Errorf("expected proj/source/other.txt not to have changed, got %v", changedPages)
+	}
+	filtered := FilterRawSnippets(rawSnippets, changedPages)
+	if len(filtered) != 2 {
+		t.Errorf("got %d snippets want the 2 snippets that include a changed file", len(filtered))
+	}
+}
+
+func TestParseNameStatus(t *testing.T) {
+	output := "A\x00source/new.txt\x00M\x00source/changed.txt\x00D\x00source/old.txt\x00"
+	got, err := ParseNameStatus(output, "source")
+	if err != nil {
+		t.Fatalf("failed to parse %v", err)
+	}
+	expected := []ChangedFile{
+		{Path: "new.txt", Status: FileAdded},
+		{Path: "changed.txt", Status: FileModified},
+		{Path: "old.txt", Status: FileRemoved},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v want %v", got, expected)
+	}
+}
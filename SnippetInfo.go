@@ -1,8 +1,59 @@
 package main
 
 type SnippetInfo struct {
-	Page           string `json:"page"`
-	Category       string `json:"category"`
-	Language       string `json:"language"`
-	LLMCategorized bool   `json:"llm_categorized"`
+	Page     string `json:"page"`
+	Category string `json:"category"`
+	// Labels are every category that applies to the snippet, starting with the Category, which is its primary label.
+	// We only set them if a multi-label taxonomy gave the snippet more than one.
+	Labels []string `json:"labels,omitempty"`
+	// Subcategory is SubcategorySyntax or SubcategoryUsage for the CommandCategories
+	Subcategory      string `json:"subcategory,omitempty"`
+	Language         string `json:"language"`
+	DeclaredLanguage string `json:"declared_language"`
+	DetectedLanguage string `json:"detected_language,omitempty"`
+	LLMCategorized   bool   `json:"llm_categorized"`
+	// Truncated is true if the snippet didn't fit in the LLM's context and the LLM only saw part of it. ContextStrategy
+	// is how we fit an oversized snippet in the context.
+	Truncated       bool   `json:"truncated"`
+	ContextStrategy string `json:"context_strategy,omitempty"`
+	// These fields are only set for snippets we extract from docs sources, and locate the code block on its page
+	StartLine int    `json:"start_line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+	Caption   string `json:"caption,omitempty"`
+	Directive string `json:"directive,omitempty"`
+	// Hash lets us match a snippet to the same snippet in an earlier report, even if it moved on the page
+	Hash string `json:"hash,omitempty"`
+	// Overridden is true if someone corrected the category or language by hand with an overrides file
+	Overridden bool `json:"overridden,omitempty"`
+}
+
+// SecondaryLabels returns the snippet's labels other than its primary Category
+func (s SnippetInfo) SecondaryLabels() []string {
+	var labels []string
+	for _, label := range s.Labels {
+		if label != s.Category {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// SnippetReport is the snippets.json report. Before schema version 2, the report was a bare array of snippets.
+type SnippetReport struct {
+	ReportHeader
+	Snippets []SnippetInfo `json:"snippets"`
+}
+
+// LanguageMismatchReport is the language_mismatches.json report
+type LanguageMismatchReport struct {
+	ReportHeader
+	Mismatches []LanguageMismatch `json:"mismatches"`
+}
+
+// LanguageMismatch records a snippet whose declared language, from its file extension or code-block tag, disagrees
+// with the language we detected from its contents
+type LanguageMismatch struct {
+	Page             string `json:"page"`
+	DeclaredLanguage string `json:"declared_language"`
+	DetectedLanguage string `json:"detected_language"`
 }
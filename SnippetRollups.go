@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Rollup totals the snippets on a page, or in a docs section and all the sections under it. The Depth of a section is
+// the number of directories below the project, so the project itself is depth 0.
+type Rollup struct {
+	Path          string         `json:"path"`
+	Depth         int            `json:"depth"`
+	URL           string         `json:"url,omitempty"`
+	TotalSnippets int            `json:"total_snippets"`
+	Categories    map[string]int `json:"categories"`
+	Languages     map[string]int `json:"languages"`
+}
+
+// RollupReport breaks the category and language counts down by page and by docs section, such as manage-indexes or
+// run-queries, so writers can see which parts of the docs need attention
+type RollupReport struct {
+	ReportHeader
+	DocsBaseURL string   `json:"docs_base_url,omitempty"`
+	Pages       []Rollup `json:"pages"`
+	Sections    []Rollup `json:"sections"`
+}
+
+// DocsBaseURL is the URL of the published docs for the project. Pass -docs-url to override it.
+func DocsBaseURL(projectName string, override string) string {
+	if override != "" {
+		return strings.TrimSuffix(override, "/") + "/"
+	}
+	if baseURL, exists := DocsBaseURLs[projectName]; exists {
+		return baseURL
+	}
+	return fmt.Sprintf(DefaultDocsBaseURL, projectName)
+}
+
+// PageURL maps a page in the rollups to its URL in the published docs. For the SourceDocs source, the page is a docs
+// source file, and only reStructuredText and Markdown files have a URL. For the SourceFiles source, the page is the
+// directory with the page's snippet files in it.
+func PageURL(page string, baseURL string, source string) string {
+	if baseURL == "" {
+		return ""
+	}
+	if source == SourceDocs {
+		ext := strings.ToLower(path.Ext(page))
+		if !containsString(rstSourceExtensions, ext) && !containsString(markdownSourceExtensions, ext) {
+			return ""
+		}
+		page = strings.TrimSuffix(page, path.Ext(page))
+	}
+	// Drop the project name, and the source directory if we read the whole repository
+	_, pagePath, _ := strings.Cut(page, "/")
+	pagePath = strings.TrimPrefix(pagePath, "source/")
+	if pagePath == "" || pagePath == "index" {
+		return baseURL
+	}
+	pagePath = strings.TrimSuffix(pagePath, "/index")
+	return baseURL + pagePath + "/"
+}
+
+// BuildRollups totals the snippets by page and by every directory level above each page
+func BuildRollups(snippets []SnippetInfo, baseURL string, source string) RollupReport {
+	builder := NewRollupBuilder(baseURL, source)
+	for _, snippet := range snippets {
+		builder.Add(snippet)
+	}
+	return builder.Report()
+}
+
+// RollupBuilder totals the snippets one at a time, so a streaming run can build the rollups without keeping the
+// snippets
+type RollupBuilder struct {
+	baseURL  string
+	source   string
+	pages    map[string]*Rollup
+	sections map[string]*Rollup
+}
+
+func NewRollupBuilder(baseURL string, source string) *RollupBuilder {
+	return &RollupBuilder{
+		baseURL:  baseURL,
+		source:   source,
+		pages:    make(map[string]*Rollup),
+		sections: make(map[string]*Rollup),
+	}
+}
+
+// Add totals the snippet in its page and every section above it
+func (b *RollupBuilder) Add(snippet SnippetInfo) {
+	pagePath := snippet.Page
+	if b.source == SourceFiles {
+		// A one-file-per-snippet tree has a directory for each page, with a file for each of the page's snippets
+		pagePath = path.Dir(snippet.Page)
+	}
+	page, exists := b.pages[pagePath]
+	if !exists {
+		page = newRollup(pagePath, max(strings.Count(pagePath, "/")-1, 0))
+		page.URL = PageURL(pagePath, b.baseURL, b.source)
+		b.pages[pagePath] = page
+	}
+	page.add(snippet)
+	elements := strings.Split(pagePath, "/")
+	for depth := 0; depth < len(elements)-1; depth++ {
+		sectionPath := strings.Join(elements[:depth+1], "/")
+		section, exists := b.sections[sectionPath]
+		if !exists {
+			section = newRollup(sectionPath, depth)
+			b.sections[sectionPath] = section
+		}
+		section.add(snippet)
+	}
+}
+
+func (b *RollupBuilder) Report() RollupReport {
+	return RollupReport{
+		DocsBaseURL: b.baseURL,
+		Pages:       sortedRollups(b.pages),
+		Sections:    sortedRollups(b.sections),
+	}
+}
+
+func newRollup(rollupPath string, depth int) *Rollup {
+	return &Rollup{
+		Path:       rollupPath,
+		Depth:      depth,
+		Categories: make(map[string]int),
+		Languages:  make(map[string]int),
+	}
+}
+
+func (r *Rollup) add(snippet SnippetInfo) {
+	r.TotalSnippets++
+	r.Categories[snippet.Category]++
+	r.Languages[snippet.Language]++
+}
+
+func sortedRollups(rollups map[string]*Rollup) []Rollup {
+	sorted := make([]Rollup, 0, len(rollups))
+	for _, rollup := range rollups {
+		sorted = append(sorted, *rollup)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Path < sorted[j].Path
+	})
+	return sorted
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildRollups(t *testing.T) {
+	snippets := []SnippetInfo{
+		{Page: "proj/manage-indexes/create.txt", Category: UsageExample, Language: PYTHON},
+		{Page: "proj/manage-indexes/create.txt", Category: SyntaxExample, Language: SHELL},
+		{Page: "proj/manage-indexes/drop.txt", Category: UsageExample, Language: PYTHON},
+		{Page: "proj/index.txt", Category: ExampleReturnObject, Language: JSON},
+	}
+	got := BuildRollups(snippets, "https://example.com/docs/", SourceDocs)
+
+	expectedPages := []Rollup{
+		{Path: "proj/index.txt", Depth: 0, URL: "https://example.com/docs/", TotalSnippets: 1, Categories: map[string]int{ExampleReturnObject: 1}, Languages: map[string]int{JSON: 1}},
+		{Path: "proj/manage-indexes/create.txt", Depth: 1, URL: "https://example.com/docs/manage-indexes/create/", TotalSnippets: 2, Categories: map[string]int{UsageExample: 1, SyntaxExample: 1}, Languages: map[string]int{PYTHON: 1, SHELL: 1}},
+		{Path: "proj/manage-indexes/drop.txt", Depth: 1, URL: "https://example.com/docs/manage-indexes/drop/", TotalSnippets: 1, Categories: map[string]int{UsageExample: 1}, Languages: map[string]int{PYTHON: 1}},
+	}
+	if !reflect.DeepEqual(got.Pages, expectedPages) {
+		t.Errorf("got %v want %v", got.Pages, expectedPages)
+	}
+	expectedSections := []Rollup{
+		{Path: "proj", Depth: 0, TotalSnippets: 4, Categories: map[string]int{UsageExample: 2, SyntaxExample: 1, ExampleReturnObject: 1}, Languages: map[string]int{PYTHON: 2, SHELL: 1, JSON: 1}},
+		{Path: "proj/manage-indexes", Depth: 1, TotalSnippets: 3, Categories: map[string]int{UsageExample: 2, SyntaxExample: 1}, Languages: map[string]int{PYTHON: 2, SHELL: 1}},
+	}
+	if !reflect.DeepEqual(got.Sections, expectedSections) {
+		t.Errorf("got %v want %v", got.Sections, expectedSections)
+	}
+}
+
+func TestPageURL(t *testing.T) {
+	baseURL := "https://example.com/docs/"
+	tests := map[string]string{
+		"proj/source/run-queries/index.txt": "https://example.com/docs/run-queries/",
+		"proj/tutorial.md":                  "https://example.com/docs/tutorial/",
+		"proj/snippets/example.go":          "",
+	}
+	for page, expected := range tests {
+		got := PageURL(page, baseURL, SourceDocs)
+		if got != expected {
+			t.Errorf("got %q want %q", got, expected)
+		}
+	}
+}
+
+func TestBuildRollupsForSnippetFiles(t *testing.T) {
+	snippets := []SnippetInfo{
+		{Page: "proj/manage-indexes/create-index.go", Category: UsageExample, Language: GO},
+		{Page: "proj/manage-indexes/drop-index.go", Category: UsageExample, Language: GO},
+		{Page: "proj/quick-start.sh", Category: SyntaxExample, Language: SHELL},
+	}
+	got := BuildRollups(snippets, "https://example.com/docs/", SourceFiles)
+
+	expectedPages := []Rollup{
+		{Path: "proj", Depth: 0, URL: "https://example.com/docs/", TotalSnippets: 1, Categories: map[string]int{SyntaxExample: 1}, Languages: map[string]int{SHELL: 1}},
+		{Path: "proj/manage-indexes", Depth: 0, URL: "https://example.com/docs/manage-indexes/", TotalSnippets: 2, Categories: map[string]int{UsageExample: 2}, Languages: map[string]int{GO: 2}},
+	}
+	if !reflect.DeepEqual(got.Pages, expectedPages) {
+		t.Errorf("got %v want %v", got.Pages, expectedPages)
+	}
+	expectedSections := []Rollup{
+		{Path: "proj", Depth: 0, TotalSnippets: 2, Categories: map[string]int{UsageExample: 2}, Languages: map[string]int{GO: 2}},
+	}
+	if !reflect.DeepEqual(got.Sections, expectedSections) {
+		t.Errorf("got %v want %v", got.Sections, expectedSections)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// SnippetTree is a read-only tree of files that we discover and read snippets from. It's either a directory on disk or
+// a directory in a git commit. Paths are slash-separated and relative to the root of the tree, as with any fs.FS, and
+// "." is the root.
+type SnippetTree interface {
+	fs.ReadDirFS
+	fs.ReadFileFS
+	fs.StatFS
+	// DisplayPath converts a path in the tree to the path we show in diagnostics, such as an absolute path on disk
+	DisplayPath(name string) string
+	// RealPath resolves any symlinks in the path, so we can tell when two paths lead to the same directory
+	RealPath(name string) (string, error)
+}
+
+// dirTree is a SnippetTree backed by a directory on disk
+type dirTree struct {
+	fs.FS
+	root string
+}
+
+func NewDirTree(root string) SnippetTree {
+	rootPath, _ := filepath.Abs(root)
+	return dirTree{FS: os.DirFS(rootPath), root: rootPath}
+}
+
+func (t dirTree) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(t.FS, name)
+}
+
+func (t dirTree) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(t.FS, name)
+}
+
+func (t dirTree) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(t.FS, name)
+}
+
+func (t dirTree) DisplayPath(name string) string {
+	return filepath.Join(t.root, filepath.FromSlash(name))
+}
+
+func (t dirTree) RealPath(name string) (string, error) {
+	return filepath.EvalSymlinks(t.DisplayPath(name))
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CategoryDefinition declares a category the LLM can answer with. The Definition goes in the prompt for each of the
+// LanguageCategories, such as SHELL or DRIVERS_MINUS_JS, along with any Examples of snippets in the category. To
+// define the category differently in some prompts, such as a return object in the JSON-like prompt, put the
+// definition for those language categories in LanguageDefinitions. A category with a Parent is a kind of the parent
+// category, such as a tutorial step that's a kind of task-based usage.
+type CategoryDefinition struct {
+	Name                string            `json:"name"`
+	Parent              string            `json:"parent,omitempty"`
+	Definition          string            `json:"definition"`
+	LanguageDefinitions map[string]string `json:"language_definitions,omitempty"`
+	LanguageCategories  []string          `json:"language_categories"`
+	Examples            []string          `json:"examples,omitempty"`
+}
+
+// DefinitionFor is the category's definition in the language category's prompt
+func (c CategoryDefinition) DefinitionFor(languageCategory string) string {
+	if definition, ok := c.LanguageDefinitions[languageCategory]; ok {
+		return definition
+	}
+	return c.Definition
+}
+
+// PromptLayout sets the order of the categories in a language category's prompt, and what each line after the first
+// starts with, which is a tab unless you set an Indent. The categories that apply to the language category but aren't
+// in the Order come after the ones that are, in the taxonomy's order.
+type PromptLayout struct {
+	LanguageCategory string   `json:"language_category"`
+	Order            []string `json:"order,omitempty"`
+	Indent           string   `json:"indent,omitempty"`
+}
+
+// Taxonomy is the set of categories we sort snippets into. The prompts, the categories we accept from the LLM, and
+// the order of the categories in the reports all come from the taxonomy, so adding or redefining a category is a
+// change to the taxonomy file. Bump the Version when you change the categories, so reports record which taxonomy
+// they used, and add a migration from the previous version so the migrate command can bring older reports up to date.
+// With MultiLabel, we ask the LLM for every category that applies to a snippet, with the best fit first. The Prompts
+// lay out the prompts for the language categories that don't list their categories in the taxonomy's order.
+type Taxonomy struct {
+	Version    int                  `json:"version"`
+	MultiLabel bool                 `json:"multi_label,omitempty"`
+	Categories []CategoryDefinition `json:"categories"`
+	Prompts    []PromptLayout       `json:"prompts,omitempty"`
+	Migrations []TaxonomyMigration  `json:"migrations,omitempty"`
+}
+
+var (
+	taxonomy     *Taxonomy
+	taxonomyOnce sync.Once
+)
+
+// StringMatchCategories are the categories the string matchers in CategorizeSnippet.go assign, so every taxonomy needs
+// them
+var StringMatchCategories = []string{SyntaxExample, UsageExample, AtlasCLICommand, MongoshCommand, ExampleReturnObject, NonMongoCommand}
+
+// DefaultTaxonomy is the taxonomy we use when no taxonomy file is present. Its prompts are the ones we asked before we
+// had a taxonomy, with the command categories added at the end, so the LLM answers the same way it always has.
+func DefaultTaxonomy() *Taxonomy {
+	const syntaxExampleDefinition = "One-line or only a few lines of code that shows the syntax of a command or a method call, but not the initialization of arguments or parameters passed into a command or method call. It demonstrates syntax but is not usable code on its own."
+	return &Taxonomy{
+		Version: 2,
+		Categories: []CategoryDefinition{
+			{
+				Name:       SyntaxExample,
+				Definition: syntaxExampleDefinition,
+				// The text prompt has always had a tab after this definition
+				LanguageDefinitions: map[string]string{TEXT: syntaxExampleDefinition + "\t", JAVASCRIPT: syntaxExampleDefinition + "\t"},
+				LanguageCategories:  []string{SHELL, TEXT, JAVASCRIPT, DRIVERS_MINUS_JS},
+			},
+			{
+				Name:               UsageExample,
+				Definition:         "Longer code snippet that establishes parameters, performs basic set up code, and includes the larger context to demonstrate how to accomplish a task. If an example shows parameters but does not show initializing parameters, it is a syntax example, not a usage example.",
+				LanguageCategories: []string{TEXT, JAVASCRIPT, DRIVERS_MINUS_JS},
+			},
+			{
+				Name:               AtlasCLICommand,
+				Definition:         "One line or only a few lines that run the Atlas CLI, starting with 'atlas ', such as 'atlas clusters list'. It can show the syntax of the command, with placeholders such as '<clusterName>', or a complete command with real values.",
+				LanguageCategories: []string{SHELL, TEXT, JAVASCRIPT},
+			},
+			{
+				Name:               MongoshCommand,
+				Definition:         "One line or only a few lines that start the MongoDB Shell from the command line, starting with 'mongosh ', such as 'mongosh \"mongodb+srv://cluster0.example.mongodb.net\" --apiVersion 1'. JavaScript that runs inside the shell, such as 'db.movies.find()', does not belong in this category.",
+				LanguageCategories: []string{SHELL, TEXT, JAVASCRIPT},
+			},
+			{
+				Name:       ExampleReturnObject,
+				Definition: `Two variants: one is an example object, typically represented in JSON, enumerating fields in the return object and their types. Typically includes an '_id' field and represents one or more example documents. Many pieces of JSON that look similar or repetitive in structure. The second variant looks like text that has been logged to console, such as an error message or status information. May resemble "Backup completed." "Restore completed." or other short status messages.`,
+				LanguageDefinitions: map[string]string{
+					JSON_LIKE: "An example object, typically represented in JSON, enumerating fields in a return object and their types. Typically includes an '_id' field and represents one or more example documents. Many pieces of JSON that look similar or repetitive in structure.",
+				},
+				LanguageCategories: []string{JSON_LIKE, SHELL, TEXT, JAVASCRIPT},
+			},
+			{
+				Name:       ExampleConfigurationObject,
+				Definition: "Example object, typically represented in JSON or YAML, enumerating required/optional parameters and their types. If it shows an '_id' field, it is a return object, not a configuration object.",
+				LanguageDefinitions: map[string]string{
+					JSON_LIKE: "Example configuration object, typically represented in JSON or YAML, enumerating required/optional parameters and their types. If it shows an '_id' field, it is a return object, not a configuration object.",
+				},
+				LanguageCategories: []string{JSON_LIKE, SHELL, TEXT, JAVASCRIPT},
+			},
+			{
+				Name:               NonMongoCommand,
+				Definition:         "One line or only a few lines of code that demonstrate popular command-line commands, such as 'docker ', 'go run', 'jq ', 'vi ', 'mkdir ', 'npm ', 'cd ' or other common command-line command invocations. If it starts with 'atlas ' it does not belong in this category - it is an Atlas CLI command. If it starts with 'mongosh ' it does not belong in this category - it is a mongosh command.",
+				LanguageCategories: []string{SHELL, TEXT, JAVASCRIPT},
+			},
+		},
+		Prompts: []PromptLayout{
+			{LanguageCategory: SHELL, Order: []string{NonMongoCommand, SyntaxExample, ExampleReturnObject, ExampleConfigurationObject}},
+			{LanguageCategory: TEXT, Order: []string{NonMongoCommand, SyntaxExample, ExampleReturnObject, ExampleConfigurationObject, UsageExample}},
+			{LanguageCategory: JAVASCRIPT, Order: []string{NonMongoCommand, SyntaxExample, ExampleReturnObject, ExampleConfigurationObject, UsageExample}},
+			{LanguageCategory: DRIVERS_MINUS_JS, Indent: "\t\t"},
+		},
+		Migrations: DefaultMigrations(),
+	}
+}
+
+// LoadTaxonomy reads the taxonomy file at the given path, which replaces the default taxonomy. If the file doesn't
+// exist, we use the default.
+func LoadTaxonomy(path string) (*Taxonomy, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return DefaultTaxonomy(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var configured Taxonomy
+	if err := json.Unmarshal(data, &configured); err != nil {
+		return nil, err
+	}
+	if err := configured.Validate(); err != nil {
+		return nil, err
+	}
+	return &configured, nil
+}
+
+// Validate checks that every category has a name and a definition, that no two categories share a name, that every
+// parent is a category and no category is its own ancestor, that the taxonomy has the categories the string
+// matchers assign, and that its migrations lead to its categories
+func (t *Taxonomy) Validate() error {
+	if t.Version < 1 {
+		return fmt.Errorf("the taxonomy needs a version of 1 or more")
+	}
+	names := make(map[string]bool)
+	for i, category := range t.Categories {
+		if category.Name == "" || category.Definition == "" {
+			return fmt.Errorf("category %d needs a name and a definition", i+1)
+		}
+		if category.Name == "Uncategorized" {
+			return fmt.Errorf("\"Uncategorized\" is what we call snippets that don't fit any category, so it can't be a category")
+		}
+		if names[category.Name] {
+			return fmt.Errorf("the taxonomy has more than one %q category", category.Name)
+		}
+		// We split the LLM's answer on commas to find each label
+		if t.MultiLabel && strings.Contains(category.Name, ",") {
+			return fmt.Errorf("the %q category has a comma in its name, so it can't be a label", category.Name)
+		}
+		names[category.Name] = true
+	}
+	for _, category := range t.Categories {
+		if category.Parent != "" && !names[category.Parent] {
+			return fmt.Errorf("the parent of %q, %q, isn't a category", category.Name, category.Parent)
+		}
+		// A category is its own ancestor if following the parents gets back to it before the top of the hierarchy
+		for parent, steps := category.Parent, 0; parent != ""; parent, steps = t.Parent(parent), steps+1 {
+			if parent == category.Name || steps > len(t.Categories) {
+				return fmt.Errorf("%q is its own ancestor", category.Name)
+			}
+		}
+	}
+	laidOut := make(map[string]bool)
+	for _, layout := range t.Prompts {
+		if laidOut[layout.LanguageCategory] {
+			return fmt.Errorf("the taxonomy lays out the %q prompt more than once", layout.LanguageCategory)
+		}
+		laidOut[layout.LanguageCategory] = true
+		for _, name := range layout.Order {
+			if !containsString(categoryNames(t.CategoriesFor(layout.LanguageCategory)), name) {
+				return fmt.Errorf("the %q prompt lists %q, which isn't a category for %s", layout.LanguageCategory, name, layout.LanguageCategory)
+			}
+		}
+	}
+	for _, name := range StringMatchCategories {
+		if !names[name] {
+			return fmt.Errorf("the string matchers assign %q, so the taxonomy needs that category", name)
+		}
+	}
+	return t.validateMigrations()
+}
+
+// GetTaxonomy loads the taxonomy from the TaxonomyFile the first time it's called, and returns the same taxonomy on
+// every later call
+func GetTaxonomy() *Taxonomy {
+	taxonomyOnce.Do(func() {
+		loaded, err := LoadTaxonomy(TaxonomyFile)
+		if err != nil {
+			log.Fatalf("failed to load the taxonomy from %s: %v", TaxonomyFile, err)
+		}
+		taxonomy = loaded
+	})
+	return taxonomy
+}
+
+// RunTaxonomyCommand prints the taxonomy we categorize with as JSON, to start a taxonomy file from
+func RunTaxonomyCommand(args []string) {
+	data, err := json.MarshalIndent(GetTaxonomy(), "", "  ")
+	if err != nil {
+		log.Fatalf("failed to marshal the taxonomy: %v", err)
+	}
+	fmt.Println(string(data))
+}
+
+// CategoryNames are the categories the LLM can answer with, in the taxonomy's order. Anything else is "Uncategorized".
+func (t *Taxonomy) CategoryNames() []string {
+	return categoryNames(t.Categories)
+}
+
+func categoryNames(categories []CategoryDefinition) []string {
+	names := make([]string, 0, len(categories))
+	for _, category := range categories {
+		names = append(names, category.Name)
+	}
+	return names
+}
+
+func (t *Taxonomy) HasCategory(name string) bool {
+	return containsString(t.CategoryNames(), name)
+}
+
+// Parent returns the category's parent, or an empty string for a category at the top of the hierarchy
+func (t *Taxonomy) Parent(name string) string {
+	for _, category := range t.Categories {
+		if category.Name == name {
+			return category.Parent
+		}
+	}
+	return ""
+}
+
+// Ancestors returns the category's parent, the parent's parent, and so on up to the top of the hierarchy
+func (t *Taxonomy) Ancestors(name string) []string {
+	var ancestors []string
+	for parent := t.Parent(name); parent != "" && !containsString(ancestors, parent); parent = t.Parent(parent) {
+		ancestors = append(ancestors, parent)
+	}
+	return ancestors
+}
+
+// CategoriesFor returns the categories that apply to snippets in the language category, in the taxonomy's order
+func (t *Taxonomy) CategoriesFor(languageCategory string) []CategoryDefinition {
+	var categories []CategoryDefinition
+	for _, category := range t.Categories {
+		if containsString(category.LanguageCategories, languageCategory) {
+			categories = append(categories, category)
+		}
+	}
+	return categories
+}
+
+// PromptLayout is the layout of the language category's prompt, or the default layout if the taxonomy doesn't have one
+func (t *Taxonomy) PromptLayout(languageCategory string) PromptLayout {
+	layout := PromptLayout{LanguageCategory: languageCategory}
+	for _, configured := range t.Prompts {
+		if configured.LanguageCategory == languageCategory {
+			layout = configured
+		}
+	}
+	if layout.Indent == "" {
+		layout.Indent = "\t"
+	}
+	return layout
+}
+
+// PromptCategories returns the categories that apply to snippets in the language category, in the order its prompt
+// lists them
+func (t *Taxonomy) PromptCategories(languageCategory string) []CategoryDefinition {
+	categories := t.CategoriesFor(languageCategory)
+	order := t.PromptLayout(languageCategory).Order
+	position := func(name string) int {
+		for i, ordered := range order {
+			if ordered == name {
+				return i
+			}
+		}
+		return len(order)
+	}
+	sort.SliceStable(categories, func(i, j int) bool {
+		return position(categories[i].Name) < position(categories[j].Name)
+	})
+	return categories
+}
+
+// PromptLanguageCategories are the language categories that have a prompt, sorted by name
+func (t *Taxonomy) PromptLanguageCategories() []string {
+	var languageCategories []string
+	for _, category := range t.Categories {
+		for _, languageCategory := range category.LanguageCategories {
+			if !containsString(languageCategories, languageCategory) {
+				languageCategories = append(languageCategories, languageCategory)
+			}
+		}
+	}
+	sort.Strings(languageCategories)
+	return languageCategories
+}
+
+// Question is the question we ask the LLM about snippets in the language category, or an empty string if no category
+// applies to them
+func (t *Taxonomy) Question(languageCategory string) string {
+	categories := t.PromptCategories(languageCategory)
+	if len(categories) == 0 {
+		return ""
+	}
+	indent := t.PromptLayout(languageCategory).Indent
+	var builder strings.Builder
+	builder.WriteString("I need to sort code examples into one of these categories:\n")
+	for _, category := range categories {
+		fmt.Fprintf(&builder, "%s%s\n", indent, category.Name)
+	}
+	builder.WriteString(indent + "Use these definitions for each category to help categorize the code example:\n")
+	for _, category := range categories {
+		if category.Parent != "" {
+			fmt.Fprintf(&builder, "%s%s (a kind of %s): %s\n", indent, category.Name, category.Parent, category.DefinitionFor(languageCategory))
+		} else {
+			fmt.Fprintf(&builder, "%s%s: %s\n", indent, category.Name, category.DefinitionFor(languageCategory))
+		}
+		for _, example := range category.Examples {
+			fmt.Fprintf(&builder, "%sAn example of %s:\n%s\n", indent, category.Name, example)
+		}
+	}
+	builder.WriteString(indent)
+	if t.MultiLabel {
+		builder.WriteString("Using these definitions, which categories apply to this code example? List the category that fits best first, then any other categories that also apply, separated by commas. Don't list an explanation, only list the category names.")
+	} else {
+		builder.WriteString("Using these definitions, which category applies to this code example? Don't list an explanation, only list the category name.")
+	}
+	return builder.String()
+}
+
+// ParseCategoryAnswer returns the categories in the LLM's answer, with the primary label first, or nothing if the
+// answer isn't a category. With MultiLabel, the answer is a list of categories separated by commas, and we skip
+// anything in the list that isn't a category.
+func (t *Taxonomy) ParseCategoryAnswer(answer string) []string {
+	if !t.MultiLabel {
+		if t.HasCategory(answer) {
+			return []string{answer}
+		}
+		return nil
+	}
+	var labels []string
+	for _, label := range strings.FieldsFunc(answer, func(r rune) bool { return r == ',' || r == '\n' }) {
+		label = strings.TrimSuffix(strings.TrimSpace(label), ".")
+		if t.HasCategory(label) && !containsString(labels, label) {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// SortCategories puts the category names in the taxonomy's order, followed by any names the taxonomy doesn't have,
+// such as "Uncategorized", sorted by name
+func (t *Taxonomy) SortCategories(names []string) {
+	order := make(map[string]int)
+	for i, name := range t.CategoryNames() {
+		order[name] = i
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		iOrder, iKnown := order[names[i]]
+		jOrder, jKnown := order[names[j]]
+		if iKnown != jKnown {
+			return iKnown
+		}
+		if iKnown {
+			return iOrder < jOrder
+		}
+		return names[i] < names[j]
+	})
+}
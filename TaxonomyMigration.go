@@ -0,0 +1,400 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TaxonomyMigration moves the categories in reports from the FromVersion of the taxonomy to the ToVersion, so we can
+// compare reports from before a change to the categories with reports from after it. Categories without a mapping
+// keep their names.
+type TaxonomyMigration struct {
+	FromVersion int               `json:"from_version"`
+	ToVersion   int               `json:"to_version"`
+	Mappings    []CategoryMapping `json:"mappings"`
+}
+
+// CategoryMapping moves the snippets in the From category to the To category, or leaves them in the From category if
+// there's no To. Mappings from several categories to the same To merge the categories. Splits move the snippets that
+// match them to other categories, and the first split that matches decides the category.
+type CategoryMapping struct {
+	From   string      `json:"from"`
+	To     string      `json:"to,omitempty"`
+	Splits []SplitRule `json:"splits,omitempty"`
+}
+
+// SplitRule matches the snippets in a language, on pages under a path, or whose contents start with a prefix. A
+// rule with more than one of these only matches snippets that match all of them.
+type SplitRule struct {
+	Category   string   `json:"category"`
+	StartsWith []string `json:"starts_with,omitempty"`
+	Languages  []string `json:"languages,omitempty"`
+	PagePrefix string   `json:"page_prefix,omitempty"`
+}
+
+// DefaultMigrations take reports from the first taxonomy to the built-in one. Version 2 added the command categories,
+// for the Atlas CLI and mongosh commands we used to call syntax examples.
+func DefaultMigrations() []TaxonomyMigration {
+	return []TaxonomyMigration{
+		{
+			FromVersion: 1,
+			ToVersion:   2,
+			Mappings: []CategoryMapping{
+				{From: SyntaxExample, Splits: []SplitRule{
+					{Category: AtlasCLICommand, StartsWith: []string{"atlas "}},
+					{Category: MongoshCommand, StartsWith: []string{"mongosh "}},
+				}},
+			},
+		},
+	}
+}
+
+// Matches is true if the snippet matches the rule. The contents are empty if we don't have them, and then a rule that
+// checks the contents can't tell, so the second result is false.
+func (r SplitRule) Matches(snippet SnippetInfo, contents string) (bool, bool) {
+	if len(r.Languages) > 0 && !containsString(r.Languages, snippet.Language) {
+		return false, true
+	}
+	if r.PagePrefix != "" && !strings.HasPrefix(snippet.Page, r.PagePrefix) {
+		return false, true
+	}
+	if len(r.StartsWith) == 0 {
+		return true, true
+	}
+	if contents == "" {
+		return false, false
+	}
+	for _, prefix := range r.StartsWith {
+		if strings.HasPrefix(contents, prefix) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// Category returns the category in the ToVersion for a snippet with the category in the FromVersion. If a split
+// can't tell whether it matches the snippet without its contents, we use the mapping's To, and the second result is
+// false.
+func (m TaxonomyMigration) Category(category string, snippet SnippetInfo, contents string) (string, bool) {
+	for _, mapping := range m.Mappings {
+		if mapping.From != category {
+			continue
+		}
+		target := mapping.From
+		if mapping.To != "" {
+			target = mapping.To
+		}
+		for _, split := range mapping.Splits {
+			matched, known := split.Matches(snippet, contents)
+			if !known {
+				return target, false
+			}
+			if matched {
+				return split.Category, true
+			}
+		}
+		return target, true
+	}
+	return category, true
+}
+
+// NeedsContents is true if any of the migrations split a category by the snippets' contents
+func NeedsContents(migrations []TaxonomyMigration) bool {
+	for _, migration := range migrations {
+		for _, mapping := range migration.Mappings {
+			for _, split := range mapping.Splits {
+				if len(split.StartsWith) > 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// MigrateSnippet moves the snippet's category and labels through each migration in turn. The contents are empty if we
+// don't have them, and then the second result is false if a split needed them.
+func MigrateSnippet(snippet SnippetInfo, contents string, migrations []TaxonomyMigration) (SnippetInfo, bool) {
+	resolved := true
+	for _, migration := range migrations {
+		category, known := migration.Category(snippet.Category, snippet, contents)
+		resolved = resolved && known
+		// The labels start with the category, so the primary label moves with it
+		var labels []string
+		for _, label := range snippet.Labels {
+			label, _ = migration.Category(label, snippet, contents)
+			if !containsString(labels, label) {
+				labels = append(labels, label)
+			}
+		}
+		if len(labels) < 2 {
+			labels = nil
+		}
+		if category != snippet.Category {
+			snippet.Subcategory = ""
+			if contents != "" {
+				snippet.Subcategory = CommandSubcategory(category, contents)
+			}
+		}
+		snippet.Category = category
+		snippet.Labels = labels
+	}
+	return snippet, resolved
+}
+
+// MigrationPath returns the migrations that take reports from the version to the taxonomy's version, in order
+func (t *Taxonomy) MigrationPath(version int) ([]TaxonomyMigration, error) {
+	if version > t.Version {
+		return nil, fmt.Errorf("the reports use taxonomy version %d, which is newer than version %d", version, t.Version)
+	}
+	var path []TaxonomyMigration
+	for version < t.Version {
+		found := false
+		for _, migration := range t.Migrations {
+			if migration.FromVersion == version {
+				path = append(path, migration)
+				version = migration.ToVersion
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("the taxonomy has no migration from version %d", version)
+		}
+	}
+	return path, nil
+}
+
+// validateMigrations checks that each migration moves to a later version, no later than the taxonomy's, and that the
+// migrations to the taxonomy's version only move snippets to its categories
+func (t *Taxonomy) validateMigrations() error {
+	fromVersions := make(map[int]bool)
+	for _, migration := range t.Migrations {
+		if migration.ToVersion <= migration.FromVersion || migration.ToVersion > t.Version {
+			return fmt.Errorf("the migration from version %d to %d has to move to a later version, up to %d", migration.FromVersion, migration.ToVersion, t.Version)
+		}
+		if fromVersions[migration.FromVersion] {
+			return fmt.Errorf("the taxonomy has more than one migration from version %d", migration.FromVersion)
+		}
+		fromVersions[migration.FromVersion] = true
+		checkCategory := func(category string) error {
+			if migration.ToVersion == t.Version && !t.HasCategory(category) {
+				return fmt.Errorf("the migration from version %d moves snippets to %q, which isn't a category", migration.FromVersion, category)
+			}
+			return nil
+		}
+		for _, mapping := range migration.Mappings {
+			if mapping.From == "" {
+				return fmt.Errorf("the migration from version %d has a mapping without a from category", migration.FromVersion)
+			}
+			if mapping.To != "" {
+				if err := checkCategory(mapping.To); err != nil {
+					return err
+				}
+			}
+			for _, split := range mapping.Splits {
+				if len(split.StartsWith) == 0 && len(split.Languages) == 0 && split.PagePrefix == "" {
+					return fmt.Errorf("the migration from version %d splits %q without a rule to match", migration.FromVersion, mapping.From)
+				}
+				if err := checkCategory(split.Category); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// MigrateOptions describe which run to migrate. The SourceDir is the snippet tree to read the snippets' contents from,
+// if it isn't the tree the run recorded, and the FromVersion is the taxonomy version of a run that doesn't record one.
+type MigrateOptions struct {
+	RunDir      string
+	SourceDir   string
+	ProjectName string
+	FromVersion int
+}
+
+// MigrateSummary describes what a migration did. If we couldn't read the snippets' contents, we can't tell which
+// category some snippets split into, so we count them as Unresolved, and leave the reports as they were.
+type MigrateSummary struct {
+	AggregateSummary
+	FromVersion int
+	ToVersion   int
+}
+
+// RunMigrateCommand rewrites the reports of historical runs in the current taxonomy's categories
+func RunMigrateCommand(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	all := flags.Bool("all", false, "migrate every run in the report directory, not only the latest")
+	sourceDir := flags.String("source-dir", "", "the snippet tree to read the snippets' contents from, for splits that check them (default the tree the run recorded)")
+	projectName := flags.String("project", "", "the name of the docs project (default the project the run recorded)")
+	fromVersion := flags.Int("from", UnversionedTaxonomy, "the taxonomy version of runs that don't record one")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: go run . migrate [-all] [-source-dir dir] [-project name] [-from version] DIR")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	runDirs := []string{ResolveRunDirectory(flags.Arg(0))}
+	if *all {
+		names, err := RunDirectories(flags.Arg(0))
+		if err != nil {
+			log.Fatalf("failed to list the runs in %s: %v", flags.Arg(0), err)
+		}
+		// A report directory from before we kept each run in its own directory has the reports in the directory itself
+		if len(names) > 0 {
+			runDirs = nil
+		}
+		for _, name := range names {
+			runDirs = append(runDirs, filepath.Join(flags.Arg(0), name))
+		}
+	}
+	for _, runDir := range runDirs {
+		summary, err := MigrateRun(MigrateOptions{RunDir: runDir, SourceDir: *sourceDir, ProjectName: *projectName, FromVersion: *fromVersion})
+		if err != nil && summary.UnresolvedCount > 0 {
+			log.Fatalf("failed to migrate %s: %v, pass -source-dir with the snippet tree the run read", runDir, err)
+		}
+		if err != nil {
+			log.Fatalf("failed to migrate %s: %v", runDir, err)
+		}
+		if summary.FromVersion == summary.ToVersion {
+			fmt.Printf("%s already uses taxonomy version %d\n", runDir, summary.ToVersion)
+			continue
+		}
+		fmt.Printf("Migrated %s from taxonomy version %d to %d, %d of %d snippets changed category\n", runDir, summary.FromVersion, summary.ToVersion, summary.MigratedCount, summary.SnippetCount)
+	}
+}
+
+// MigrateRun rewrites the snippet, counts, rollup and language mismatch reports in the run directory in the current
+// taxonomy's categories, and records the taxonomy version in the reports' metadata
+func MigrateRun(options MigrateOptions) (MigrateSummary, error) {
+	summary := MigrateSummary{FromVersion: options.FromVersion, ToVersion: GetTaxonomy().Version}
+	previous, err := ReadRepoReport(filepath.Join(options.RunDir, "language_category_counts.json"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return summary, err
+	}
+	if previous.Metadata != nil && previous.Metadata.TaxonomyVersion != 0 {
+		summary.FromVersion = previous.Metadata.TaxonomyVersion
+	}
+	migrations, err := GetTaxonomy().MigrationPath(summary.FromVersion)
+	if err != nil || len(migrations) == 0 {
+		return summary, err
+	}
+	var contents map[string]string
+	if NeedsContents(migrations) {
+		contents, err = ReadSnippetContents(MigrationSourceOptions(previous.Metadata, options.SourceDir, options.ProjectName))
+		if err != nil {
+			fmt.Printf("Failed to read the snippets' contents, so we can't split categories by them: %v\n", err)
+		}
+	}
+	summary.AggregateSummary, err = AggregateRun(AggregateOptions{
+		RunDir:      options.RunDir,
+		ProjectName: options.ProjectName,
+		Migrations:  migrations,
+		Contents:    contents,
+	})
+	if err == nil && previous.Metadata == nil {
+		fmt.Printf("%s doesn't record its metadata, so its reports can't say they use taxonomy version %d\n", options.RunDir, summary.ToVersion)
+	}
+	return summary, err
+}
+
+// MigrateBaseSnippets moves the snippets of the run an incremental run updates into the current taxonomy, so the delta
+// and the merged reports don't mix categories from two taxonomies. The base run's counts report records its taxonomy
+// version, and we read the contents the splits need from the base ref. If we can't tell which category a snippet
+// splits into, we return an error instead of merging it.
+func MigrateBaseSnippets(baseSnippets []SnippetInfo, baseRunDir string, options RunOptions) ([]SnippetInfo, error) {
+	baseReport, err := ReadRepoReport(filepath.Join(baseRunDir, "language_category_counts.json"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	version := baseReport.TaxonomyVersion()
+	migrations, err := GetTaxonomy().MigrationPath(version)
+	if err != nil || len(migrations) == 0 {
+		return baseSnippets, err
+	}
+	var contents map[string]string
+	if NeedsContents(migrations) {
+		baseOptions := options
+		baseOptions.GitRef = options.BaseRef
+		baseOptions.BaseRef = ""
+		contents, err = ReadSnippetContents(baseOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the snippets at %s to migrate them: %v", options.BaseRef, err)
+		}
+	}
+	migrated := make([]SnippetInfo, 0, len(baseSnippets))
+	unresolved := 0
+	for _, snippet := range baseSnippets {
+		snippet, resolved := MigrateSnippet(snippet, contents[snippet.Hash], migrations)
+		if !resolved {
+			unresolved++
+		}
+		migrated = append(migrated, snippet)
+	}
+	if unresolved > 0 {
+		return nil, fmt.Errorf("%d of the snippets at %s need their contents to tell which category they split into", unresolved, options.BaseRef)
+	}
+	fmt.Printf("Migrated the snippets at %s from taxonomy version %d to %d\n", options.BaseRef, version, GetTaxonomy().Version)
+	return migrated, nil
+}
+
+// MigrationSourceOptions describe the snippet tree the run read, so we can read the snippets' contents again: the
+// commit it categorized, or the directory, unless the sourceDir replaces it
+func MigrationSourceOptions(metadata *RunMetadata, sourceDir string, projectName string) RunOptions {
+	options := RunOptions{ProjectName: projectName, Source: SourceFiles, StartDir: sourceDir}
+	if metadata == nil {
+		return options
+	}
+	if options.ProjectName == "" {
+		options.ProjectName = metadata.ProjectName
+	}
+	if metadata.Source != "" {
+		options.Source = metadata.Source
+	}
+	if sourceDir == "" {
+		options.StartDir = metadata.InputDirectory
+		if metadata.SourceCommit != nil {
+			options.GitRepo = metadata.SourceCommit.Repository
+			options.GitRef = metadata.SourceCommit.SHA
+		}
+	}
+	return options
+}
+
+// ReadSnippetContents reads the snippets from the tree, and returns their contents by hash, which is how the reports
+// identify a snippet
+func ReadSnippetContents(options RunOptions) (map[string]string, error) {
+	// With a git ref, an empty start directory is the root of the repository
+	if options.StartDir == "" && options.GitRef == "" {
+		return nil, errors.New("the run doesn't record its snippet tree")
+	}
+	if options.GitRef == "" {
+		if _, err := os.Stat(options.StartDir); err != nil {
+			return nil, err
+		}
+	}
+	tree, _, err := OpenSnippetTree(options)
+	if err != nil {
+		return nil, err
+	}
+	if closer, isCloser := tree.(io.Closer); isCloser {
+		defer closer.Close()
+	}
+	rawSnippets, _ := ReadRawSnippets(tree, options)
+	contents := make(map[string]string, len(rawSnippets))
+	for _, rawSnippet := range rawSnippets {
+		contents[GetSnippetHash(rawSnippet.Contents)] = rawSnippet.Contents
+	}
+	return contents, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMigrateSnippet(t *testing.T) {
+	migrations := DefaultTaxonomy().Migrations
+	snippet := SnippetInfo{Page: "proj/a.sh", Category: SyntaxExample, Language: SHELL}
+	got, resolved := MigrateSnippet(snippet, "atlas clusters describe <clusterName>", migrations)
+	if got.Category != AtlasCLICommand || got.Subcategory != SubcategorySyntax || !resolved {
+		t.Errorf("got %v, %t want a resolved %s with the syntax subcategory", got, resolved, AtlasCLICommand)
+	}
+	got, resolved = MigrateSnippet(snippet, "db.collection.find(<filter>)", migrations)
+	if got.Category != SyntaxExample || !resolved {
+		t.Errorf("got %v, %t want a resolved %s", got, resolved, SyntaxExample)
+	}
+	got, resolved = MigrateSnippet(snippet, "", migrations)
+	if got.Category != SyntaxExample || resolved {
+		t.Errorf("got %v, %t want an unresolved %s without the contents", got, resolved, SyntaxExample)
+	}
+
+	merge := []TaxonomyMigration{{FromVersion: 1, ToVersion: 2, Mappings: []CategoryMapping{
+		{From: "Configuration example", To: ExampleConfigurationObject},
+		{From: "Settings example", To: ExampleConfigurationObject},
+		{From: UsageExample, Splits: []SplitRule{{Category: NonMongoCommand, Languages: []string{SHELL}, PagePrefix: "proj/install/"}}},
+	}}}
+	labeled := SnippetInfo{Page: "proj/install/a.sh", Category: "Configuration example", Labels: []string{"Configuration example", "Settings example", UsageExample}, Language: SHELL}
+	got, _ = MigrateSnippet(labeled, "", merge)
+	expectedLabels := []string{ExampleConfigurationObject, NonMongoCommand}
+	if got.Category != ExampleConfigurationObject || !reflect.DeepEqual(got.Labels, expectedLabels) {
+		t.Errorf("got %q with labels %q want %q with labels %q", got.Category, got.Labels, ExampleConfigurationObject, expectedLabels)
+	}
+}
+
+func TestMigrationPath(t *testing.T) {
+	taxonomy := DefaultTaxonomy()
+	if got, err := taxonomy.MigrationPath(1); err != nil || len(got) != 1 || got[0].ToVersion != taxonomy.Version {
+		t.Errorf("got %v, %v want the migration from version 1", got, err)
+	}
+	if got, err := taxonomy.MigrationPath(taxonomy.Version); err != nil || len(got) != 0 {
+		t.Errorf("got %v, %v want no migrations for the current version", got, err)
+	}
+	if _, err := taxonomy.MigrationPath(taxonomy.Version + 1); err == nil {
+		t.Errorf("got no error for a newer version than the taxonomy's")
+	}
+
+	taxonomy.Version = 3
+	if _, err := taxonomy.MigrationPath(1); err == nil {
+		t.Errorf("got no error without a migration from version 2")
+	}
+	taxonomy.Migrations = append(taxonomy.Migrations, TaxonomyMigration{FromVersion: 2, ToVersion: 3, Mappings: []CategoryMapping{{From: SyntaxExample, To: "Not a category"}}})
+	if err := taxonomy.Validate(); err == nil {
+		t.Errorf("got no error for a migration to a category that isn't in the taxonomy")
+	}
+}
+
+func TestMigrateRun(t *testing.T) {
+	sourceDir := t.TempDir()
+	snippetContents := map[string]string{"a.sh": "atlas clusters list", "b.sh": "mongosh --port 28015", "c.sh": "db.collection.find(<filter>)"}
+	var snippets []SnippetInfo
+	for _, name := range []string{"a.sh", "b.sh", "c.sh"} {
+		if err := os.WriteFile(filepath.Join(sourceDir, name), []byte(snippetContents[name]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		snippets = append(snippets, SnippetInfo{Page: "proj/" + name, Category: SyntaxExample, Language: SHELL, Hash: GetSnippetHash(snippetContents[name])})
+	}
+	runDir := t.TempDir()
+	metadata := &RunMetadata{ProjectName: "proj", Model: MODEL, TaxonomyVersion: 1, Source: SourceFiles, InputDirectory: sourceDir}
+	WriteSnippetReport(snippets, metadata, runDir)
+	WriteCategoryCountsReport(RepoReport{ReportHeader: NewReportHeader(metadata), TotalCodeBlocks: 3}, runDir)
+
+	summary, err := MigrateRun(MigrateOptions{RunDir: runDir, FromVersion: UnversionedTaxonomy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.FromVersion != 1 || summary.MigratedCount != 2 || summary.UnresolvedCount != 0 {
+		t.Errorf("got %+v want 2 snippets migrated from version 1", summary)
+	}
+	repoReport, err := ReadRepoReport(filepath.Join(runDir, "language_category_counts.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := repoReport.TypedCounts()
+	if counts.Categories[AtlasCLICommand].Total != 1 || counts.Categories[MongoshCommand].Total != 1 || counts.Categories[SyntaxExample].Total != 1 {
+		t.Errorf("got counts %v, the command snippets should move to the command categories", counts.Categories)
+	}
+	if got := repoReport.Metadata; got.TaxonomyVersion != DefaultTaxonomy().Version || got.MigratedFromTaxonomyVersion != 1 {
+		t.Errorf("got metadata %+v, the report should record both taxonomy versions", got)
+	}
+
+	summary, err = MigrateRun(MigrateOptions{RunDir: runDir, FromVersion: UnversionedTaxonomy})
+	if err != nil || summary.FromVersion != summary.ToVersion || summary.SnippetCount != 0 {
+		t.Errorf("got %+v, %v want nothing to migrate the second time", summary, err)
+	}
+}
+
+func TestMigrateRunWithoutContentsLeavesTheReports(t *testing.T) {
+	sourceDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(sourceDir, "a.sh"), []byte("atlas clusters list"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	snippets := []SnippetInfo{{Page: "proj/a.sh", Category: SyntaxExample, Language: SHELL, Hash: GetSnippetHash("atlas clusters list")}}
+	runDir := t.TempDir()
+	// The run's snippet tree moved since it ran
+	metadata := &RunMetadata{ProjectName: "proj", Model: MODEL, TaxonomyVersion: 1, Source: SourceFiles, InputDirectory: filepath.Join(sourceDir, "moved")}
+	WriteSnippetReport(snippets, metadata, runDir)
+	WriteCategoryCountsReport(RepoReport{ReportHeader: NewReportHeader(metadata), TotalCodeBlocks: 1}, runDir)
+
+	summary, err := MigrateRun(MigrateOptions{RunDir: runDir, FromVersion: UnversionedTaxonomy})
+	if err == nil || summary.UnresolvedCount != 1 {
+		t.Fatalf("got %+v, %v want an error for the snippet we can't split without its contents", summary, err)
+	}
+	repoReport, err := ReadRepoReport(filepath.Join(runDir, "language_category_counts.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := repoReport.Metadata; got.TaxonomyVersion != 1 || got.MigratedFromTaxonomyVersion != 0 {
+		t.Errorf("got metadata %+v, the reports should stay in taxonomy version 1", got)
+	}
+
+	// Once we know where the snippets are, the retry migrates them
+	summary, err = MigrateRun(MigrateOptions{RunDir: runDir, SourceDir: sourceDir, FromVersion: UnversionedTaxonomy})
+	if err != nil || summary.FromVersion != 1 || summary.MigratedCount != 1 || summary.UnresolvedCount != 0 {
+		t.Fatalf("got %+v, %v want the snippet migrated from version 1", summary, err)
+	}
+	migrated, err := ReadSnippetReport(SnippetReportPath(runDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated[0].Category != AtlasCLICommand {
+		t.Errorf("got %q want %q", migrated[0].Category, AtlasCLICommand)
+	}
+}
+
+func TestMigrateBaseSnippets(t *testing.T) {
+	repo := initTestRepo(t, map[string]string{"a.sh": "atlas clusters list", "b.sh": "db.collection.find(<filter>)"})
+	baseSnippets := []SnippetInfo{
+		{Page: "proj/a.sh", Category: SyntaxExample, Language: SHELL, Hash: GetSnippetHash("atlas clusters list")},
+		{Page: "proj/b.sh", Category: SyntaxExample, Language: SHELL, Hash: GetSnippetHash("db.collection.find(<filter>)")},
+	}
+	baseRunDir := t.TempDir()
+	metadata := &RunMetadata{ProjectName: "proj", Model: MODEL, TaxonomyVersion: 1, Source: SourceFiles}
+	WriteCategoryCountsReport(RepoReport{ReportHeader: NewReportHeader(metadata), TotalCodeBlocks: 2}, baseRunDir)
+	options := RunOptions{ProjectName: "proj", Source: SourceFiles, GitRepo: repo, BaseRef: "HEAD", GitRef: "HEAD"}
+
+	got, err := MigrateBaseSnippets(baseSnippets, baseRunDir, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Category != AtlasCLICommand || got[1].Category != SyntaxExample {
+		t.Errorf("got %v, the Atlas CLI command should move to its own category", got)
+	}
+
+	removed := append(baseSnippets, SnippetInfo{Page: "proj/c.sh", Category: SyntaxExample, Language: SHELL, Hash: GetSnippetHash("mongosh")})
+	if _, err := MigrateBaseSnippets(removed, baseRunDir, options); err == nil {
+		t.Errorf("got no error for a snippet that isn't at the base ref")
+	}
+
+	metadata.TaxonomyVersion = DefaultTaxonomy().Version
+	WriteCategoryCountsReport(RepoReport{ReportHeader: NewReportHeader(metadata), TotalCodeBlocks: 2}, baseRunDir)
+	if got, err := MigrateBaseSnippets(removed, baseRunDir, options); err != nil || !reflect.DeepEqual(got, removed) {
+		t.Errorf("got %v, %v want the snippets unchanged when the base run uses the current taxonomy", got, err)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTaxonomyQuestion(t *testing.T) {
+	question := DefaultTaxonomy().Question(SHELL)
+	expected := []string{SyntaxExample, AtlasCLICommand, MongoshCommand, ExampleReturnObject, ExampleConfigurationObject, NonMongoCommand}
+	var got []string
+	for _, category := range DefaultTaxonomy().CategoriesFor(SHELL) {
+		got = append(got, category.Name)
+		if !strings.Contains(question, category.Name+": "+category.Definition) {
+			t.Errorf("the question should define %q", category.Name)
+		}
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q want %q", got, expected)
+	}
+	if strings.Contains(question, UsageExample) {
+		t.Errorf("the shell question shouldn't offer %q", UsageExample)
+	}
+	if question := DefaultTaxonomy().Question("Unknown language"); question != "" {
+		t.Errorf("got %q want no question for a language category without categories", question)
+	}
+}
+
+// The baseline questions are the ones we asked before we had a taxonomy
+func TestDefaultTaxonomyKeepsTheBaselineQuestions(t *testing.T) {
+	taxonomy := DefaultTaxonomy()
+	// We added the command categories after the baseline, at the end of the prompts they apply to
+	withCommandCategories := func(question string) string {
+		question = strings.Replace(question, "it is an Atlas CLI Command.", "it is an Atlas CLI command.", 1)
+		question = strings.Replace(question, "it is a 'mongosh command'.", "it is a mongosh command.", 1)
+		var names, definitions string
+		for _, category := range taxonomy.Categories {
+			if containsString(CommandCategories, category.Name) {
+				names += "\t" + category.Name + "\n"
+				definitions += "\t" + category.Name + ": " + category.Definition + "\n"
+			}
+		}
+		question = strings.Replace(question, "\tUse these definitions", names+"\tUse these definitions", 1)
+		return strings.Replace(question, "\tUsing these definitions", definitions+"\tUsing these definitions", 1)
+	}
+	expected := map[string]string{
+		JSON_LIKE:        baselineJsonLikeQuestion(),
+		SHELL:            withCommandCategories(baselineShellQuestion()),
+		TEXT:             withCommandCategories(baselineTextQuestion()),
+		JAVASCRIPT:       withCommandCategories(baselineTextQuestion()),
+		DRIVERS_MINUS_JS: baselineDriverLanguageQuestion(),
+	}
+	for languageCategory, question := range expected {
+		if got := taxonomy.Question(languageCategory); got != question {
+			t.Errorf("got the %s question\n%q\nwant\n%q", languageCategory, got, question)
+		}
+	}
+
+	taxonomy.Prompts = append(taxonomy.Prompts, PromptLayout{LanguageCategory: JSON_LIKE, Order: []string{UsageExample}})
+	if err := taxonomy.Validate(); err == nil {
+		t.Errorf("got no error for a prompt that lists a category that doesn't apply to its language category")
+	}
+}
+
+func TestLoadTaxonomy(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "taxonomy.json")
+	configured := DefaultTaxonomy()
+	configured.Version = 2
+	configured.Categories = append(configured.Categories, CategoryDefinition{
+		Name:               "Tutorial step",
+		Definition:         "One step of a tutorial that builds on the steps before it.",
+		LanguageCategories: []string{DRIVERS_MINUS_JS},
+		Examples:           []string{"collection.insert_one(doc)"},
+	})
+	WriteTestTaxonomy(t, filePath, configured)
+	got, err := LoadTaxonomy(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != 2 || !got.HasCategory("Tutorial step") {
+		t.Errorf("got %+v want version 2 with the new category", got)
+	}
+	question := got.Question(DRIVERS_MINUS_JS)
+	if !strings.Contains(question, "Tutorial step: One step") || !strings.Contains(question, "collection.insert_one(doc)") {
+		t.Errorf("got %q, the driver question should define the new category with its example", question)
+	}
+
+	configured.Categories = configured.Categories[1:]
+	WriteTestTaxonomy(t, filePath, configured)
+	if _, err := LoadTaxonomy(filePath); err == nil {
+		t.Errorf("got no error for a taxonomy without %q, which the string matchers assign", SyntaxExample)
+	}
+	if got, err := LoadTaxonomy(filepath.Join(dir, "missing.json")); err != nil || got.Version != DefaultTaxonomy().Version {
+		t.Errorf("got %v, %v want the default taxonomy when there's no file", got, err)
+	}
+}
+
+func WriteTestTaxonomy(t *testing.T, filePath string, configured *Taxonomy) {
+	data, err := json.Marshal(configured)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSortCategories(t *testing.T) {
+	got := []string{"Uncategorized", NonMongoCommand, "Tutorial step", SyntaxExample}
+	DefaultTaxonomy().SortCategories(got)
+	expected := []string{SyntaxExample, NonMongoCommand, "Tutorial step", "Uncategorized"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q want %q", got, expected)
+	}
+}
+
+func TestPromptLanguageCategory(t *testing.T) {
+	if got := PromptLanguageCategory(JAVASCRIPT, true); got != DRIVERS_MINUS_JS {
+		t.Errorf("got %q want %q for JavaScript in driver docs", got, DRIVERS_MINUS_JS)
+	}
+	if got := PromptLanguageCategory(JAVASCRIPT, false); got != JAVASCRIPT {
+		t.Errorf("got %q want %q for JavaScript in other docs", got, JAVASCRIPT)
+	}
+}
+
+func TestCommandSubcategory(t *testing.T) {
+	tests := []struct {
+		category string
+		contents string
+		expected string
+	}{
+		{AtlasCLICommand, "atlas clusters describe <clusterName> [--projectId projectId]", SubcategorySyntax},
+		{AtlasCLICommand, "atlas clusters list [options]", SubcategorySyntax},
+		{AtlasCLICommand, "atlas clusters list --projectId 5e2211c17a3e5a48f5497de3", SubcategoryUsage},
+		{MongoshCommand, `mongosh "mongodb+srv://cluster0.example.mongodb.net" --eval "db.movies.find({ genres: ['Drama'] })"`, SubcategoryUsage},
+		{SyntaxExample, "db.collection.find(<filter>)", ""},
+	}
+	for _, test := range tests {
+		if got := CommandSubcategory(test.category, test.contents); got != test.expected {
+			t.Errorf("%s: got %q want %q", test.contents, got, test.expected)
+		}
+	}
+}
+
+func TestTaxonomyHierarchy(t *testing.T) {
+	taxonomy := DefaultTaxonomy()
+	taxonomy.Categories = append(taxonomy.Categories,
+		CategoryDefinition{Name: "Tutorial step", Parent: UsageExample, Definition: "One step of a tutorial.", LanguageCategories: []string{DRIVERS_MINUS_JS}},
+		CategoryDefinition{Name: "Quick start step", Parent: "Tutorial step", Definition: "One step of a quick start.", LanguageCategories: []string{DRIVERS_MINUS_JS}},
+	)
+	if err := taxonomy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	got := taxonomy.Ancestors("Quick start step")
+	expected := []string{"Tutorial step", UsageExample}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q want %q", got, expected)
+	}
+	if question := taxonomy.Question(DRIVERS_MINUS_JS); !strings.Contains(question, "Tutorial step (a kind of "+UsageExample+"): One step") {
+		t.Errorf("got %q, the question should say which category a child is a kind of", question)
+	}
+
+	taxonomy.Categories[len(taxonomy.Categories)-2].Parent = "Quick start step"
+	if err := taxonomy.Validate(); err == nil {
+		t.Errorf("got no error for categories that are each other's parents")
+	}
+	taxonomy.Categories[len(taxonomy.Categories)-2].Parent = "Walkthrough"
+	if err := taxonomy.Validate(); err == nil {
+		t.Errorf("got no error for a parent that isn't a category")
+	}
+}
+
+func TestParseCategoryAnswer(t *testing.T) {
+	taxonomy := DefaultTaxonomy()
+	if got := taxonomy.ParseCategoryAnswer(UsageExample + ", " + SyntaxExample); got != nil {
+		t.Errorf("got %q want no categories for a list from a single-label taxonomy", got)
+	}
+	taxonomy.MultiLabel = true
+	got := taxonomy.ParseCategoryAnswer(UsageExample + ", Not a category,\n" + SyntaxExample + ".\n" + UsageExample)
+	expected := []string{UsageExample, SyntaxExample}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q want %q", got, expected)
+	}
+	if question := taxonomy.Question(SHELL); !strings.Contains(question, "separated by commas") {
+		t.Errorf("got %q, a multi-label question should ask for every category that applies", question)
+	}
+	taxonomy.Categories = append(taxonomy.Categories, CategoryDefinition{Name: "Install, upgrade", Definition: "Installs a package.", LanguageCategories: []string{SHELL}})
+	if err := taxonomy.Validate(); err == nil {
+		t.Errorf("got no error for a category with a comma in a multi-label taxonomy")
+	}
+}
+
+// The baseline questions are copies of the questions we asked before we had a taxonomy
+func baselineJsonLikeQuestion() string {
+	const questionTemplate = `I need to sort code examples into one of these categories:
+	%s
+	%s
+	Use these definitions for each category to help categorize the code example:
+	%s: An example object, typically represented in JSON, enumerating fields in a return object and their types. Typically includes an '_id' field and represents one or more example documents. Many pieces of JSON that look similar or repetitive in structure.
+	%s: Example configuration object, typically represented in JSON or YAML, enumerating required/optional parameters and their types. If it shows an '_id' field, it is a return object, not a configuration object.
+	Using these definitions, which category applies to this code example? Don't list an explanation, only list the category name.`
+	return fmt.Sprintf(questionTemplate,
+		ExampleReturnObject,
+		ExampleConfigurationObject,
+		ExampleReturnObject,
+		ExampleConfigurationObject,
+	)
+}
+
+func baselineShellQuestion() string {
+	const questionTemplate = `I need to sort code examples into one of these categories:
+	%s
+	%s
+	%s
+	%s
+	Use these definitions for each category to help categorize the code example:
+	%s: One line or only a few lines of code that demonstrate popular command-line commands, such as 'docker ', 'go run', 'jq ', 'vi ', 'mkdir ', 'npm ', 'cd ' or other common command-line command invocations. If it starts with 'atlas ' it does not belong in this category - it is an Atlas CLI Command. If it starts with 'mongosh ' it does not belong in this category - it is a 'mongosh command'.
+	%s: One-line or only a few lines of code that shows the syntax of a command or a method call, but not the initialization of arguments or parameters passed into a command or method call. It demonstrates syntax but is not usable code on its own.
+	%s: Two variants: one is an example object, typically represented in JSON, enumerating fields in the return object and their types. Typically includes an '_id' field and represents one or more example documents. Many pieces of JSON that look similar or repetitive in structure. The second variant looks like text that has been logged to console, such as an error message or status information. May resemble "Backup completed." "Restore completed." or other short status messages.
+	%s: Example object, typically represented in JSON or YAML, enumerating required/optional parameters and their types. If it shows an '_id' field, it is a return object, not a configuration object.
+	Using these definitions, which category applies to this code example? Don't list an explanation, only list the category name.`
+	return fmt.Sprintf(questionTemplate,
+		NonMongoCommand,
+		SyntaxExample,
+		ExampleReturnObject,
+		ExampleConfigurationObject,
+		NonMongoCommand,
+		SyntaxExample,
+		ExampleReturnObject,
+		ExampleConfigurationObject,
+	)
+}
+
+// baselineTextQuestion lists its five categories once. The template we used to ask had seven placeholders in the list,
+// which shifted every category name after them and left two definitions without a name.
+func baselineTextQuestion() string {
+	const questionTemplate = `I need to sort code examples into one of these categories:
+	%s
+	%s
+	%s
+	%s
+	%s
+	Use these definitions for each category to help categorize the code example:
+	%s: One line or only a few lines of code that demonstrate popular command-line commands, such as 'docker ', 'go run', 'jq ', 'vi ', 'mkdir ', 'npm ', 'cd ' or other common command-line command invocations. If it starts with 'atlas ' it does not belong in this category - it is an Atlas CLI Command. If it starts with 'mongosh ' it does not belong in this category - it is a 'mongosh command'.
+	%s: One-line or only a few lines of code that shows the syntax of a command or a method call, but not the initialization of arguments or parameters passed into a command or method call. It demonstrates syntax but is not usable code on its own.	
+	%s: Two variants: one is an example object, typically represented in JSON, enumerating fields in the return object and their types. Typically includes an '_id' field and represents one or more example documents. Many pieces of JSON that look similar or repetitive in structure. The second variant looks like text that has been logged to console, such as an error message or status information. May resemble "Backup completed." "Restore completed." or other short status messages.
+	%s: Example object, typically represented in JSON or YAML, enumerating required/optional parameters and their types. If it shows an '_id' field, it is a return object, not a configuration object.
+	%s: Longer code snippet that establishes parameters, performs basic set up code, and includes the larger context to demonstrate how to accomplish a task. If an example shows parameters but does not show initializing parameters, it is a syntax example, not a usage example.
+	Using these definitions, which category applies to this code example? Don't list an explanation, only list the category name.`
+	return fmt.Sprintf(questionTemplate,
+		NonMongoCommand,
+		SyntaxExample,
+		ExampleReturnObject,
+		ExampleConfigurationObject,
+		UsageExample,
+		NonMongoCommand,
+		SyntaxExample,
+		ExampleReturnObject,
+		ExampleConfigurationObject,
+		UsageExample,
+	)
+}
+
+func baselineDriverLanguageQuestion() string {
+	const questionTemplate = `I need to sort code examples into one of these categories:
+		%s
+		%s
+		Use these definitions for each category to help categorize the code example:
+		%s: One-line or only a few lines of code that shows the syntax of a command or a method call, but not the initialization of arguments or parameters passed into a command or method call. It demonstrates syntax but is not usable code on its own.
+		%s: Longer code snippet that establishes parameters, performs basic set up code, and includes the larger context to demonstrate how to accomplish a task. If an example shows parameters but does not show initializing parameters, it is a syntax example, not a usage example.
+		Using these definitions, which category applies to this code example? Don't list an explanation, only list the category name.`
+	return fmt.Sprintf(questionTemplate,
+		SyntaxExample,
+		UsageExample,
+		SyntaxExample,
+		UsageExample,
+	)
+}
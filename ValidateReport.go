@@ -0,0 +1,295 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The schemas directory holds the published JSON Schemas for the reports. They're embedded so the validate command
+// works from the binary alone.
+//
+//go:embed schemas/*.schema.json
+var reportSchemas embed.FS
+
+const (
+	SnippetsSchema       = "snippets"
+	CategoryCountsSchema = "language_category_counts"
+)
+
+// RunValidateCommand checks each report against its JSON Schema, and exits with an error if any report is invalid
+func RunValidateCommand(args []string) {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	schemaName := flags.String("schema", "", "the schema to validate against: snippets or language_category_counts (default from each file name)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: go run . validate [-schema name] REPORT_FILE...")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	allValid := true
+	for _, filePath := range flags.Args() {
+		name := *schemaName
+		if name == "" {
+			name = SchemaForReport(filePath)
+		}
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			fmt.Printf("INVALID %s: %v\n", filePath, err)
+			allValid = false
+			continue
+		}
+		validate := ValidateReport
+		if filepath.Ext(filePath) == ".ndjson" {
+			validate = ValidateSnippetStream
+		}
+		problems, err := validate(data, name)
+		if err != nil {
+			fmt.Printf("INVALID %s: %v\n", filePath, err)
+			allValid = false
+			continue
+		}
+		if len(problems) > 0 {
+			fmt.Printf("INVALID %s against the %s schema:\n", filePath, name)
+			for _, problem := range problems {
+				fmt.Println("  " + problem)
+			}
+			allValid = false
+			continue
+		}
+		fmt.Printf("VALID %s (schema version %d)\n", filePath, ReportSchemaVersionOf(data))
+	}
+	if !allValid {
+		os.Exit(1)
+	}
+}
+
+// SchemaForReport picks the schema from the report's file name
+func SchemaForReport(filePath string) string {
+	return strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+}
+
+// ReportSchemaVersionOf returns the schema_version of a report, or LegacySchemaVersion if it doesn't have one
+func ReportSchemaVersionOf(data []byte) int {
+	var header ReportHeader
+	if err := json.Unmarshal(data, &header); err != nil || header.SchemaVersion == 0 {
+		return LegacySchemaVersion
+	}
+	return header.SchemaVersion
+}
+
+// ValidateReport returns a description of each way the report doesn't match the schema
+func ValidateReport(data []byte, schemaName string) ([]string, error) {
+	schema, err := loadReportSchema(schemaName)
+	if err != nil {
+		return nil, err
+	}
+	var report any
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("the report isn't valid JSON: %v", err)
+	}
+	validator := schemaValidator{root: schema}
+	return validator.validate(report, schema, "$"), nil
+}
+
+// ValidateSnippetStream validates each line of a snippets.ndjson report against the snippet definition in the schema.
+// The stream has no schema version or metadata of its own, because the other reports from the run record them.
+func ValidateSnippetStream(data []byte, schemaName string) ([]string, error) {
+	schema, err := loadReportSchema(schemaName)
+	if err != nil {
+		return nil, err
+	}
+	validator := schemaValidator{root: schema}
+	snippetSchema := map[string]any{"$ref": "#/$defs/snippet"}
+	var problems []string
+	for i, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var snippet any
+		if err := json.Unmarshal([]byte(line), &snippet); err != nil {
+			return nil, fmt.Errorf("line %d isn't valid JSON: %v", i+1, err)
+		}
+		problems = append(problems, validator.validate(snippet, snippetSchema, fmt.Sprintf("line %d", i+1))...)
+	}
+	return problems, nil
+}
+
+func loadReportSchema(schemaName string) (map[string]any, error) {
+	schemaData, err := reportSchemas.ReadFile("schemas/" + schemaName + ".schema.json")
+	if err != nil {
+		return nil, fmt.Errorf("there's no %s schema", schemaName)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(schemaData, &schema); err != nil {
+		return nil, fmt.Errorf("the %s schema isn't valid JSON: %v", schemaName, err)
+	}
+	return schema, nil
+}
+
+// schemaValidator supports the JSON Schema keywords our schemas use: $ref to $defs, type, enum, minimum, required,
+// properties, additionalProperties, items, minItems and oneOf. If you use another keyword in a schema, add it here.
+type schemaValidator struct {
+	root map[string]any
+}
+
+func (v schemaValidator) validate(value any, schema map[string]any, location string) []string {
+	if ref, exists := schema["$ref"].(string); exists {
+		resolved, err := v.resolve(ref)
+		if err != nil {
+			return []string{fmt.Sprintf("%s: %v", location, err)}
+		}
+		return v.validate(value, resolved, location)
+	}
+	if options, exists := schema["oneOf"].([]any); exists {
+		return v.validateOneOf(value, options, location)
+	}
+	var problems []string
+	if expectedType, exists := schema["type"].(string); exists && !hasJSONType(value, expectedType) {
+		return []string{fmt.Sprintf("%s: expected %s, got %s", location, expectedType, jsonTypeName(value))}
+	}
+	if allowed, exists := schema["enum"].([]any); exists && !containsJSONValue(allowed, value) {
+		problems = append(problems, fmt.Sprintf("%s: %v isn't one of %v", location, value, allowed))
+	}
+	if minimum, exists := schema["minimum"].(float64); exists {
+		if number, isNumber := value.(float64); isNumber && number < minimum {
+			problems = append(problems, fmt.Sprintf("%s: %v is less than the minimum of %v", location, number, minimum))
+		}
+	}
+	switch typed := value.(type) {
+	case map[string]any:
+		problems = append(problems, v.validateObject(typed, schema, location)...)
+	case []any:
+		if minItems, exists := schema["minItems"].(float64); exists && float64(len(typed)) < minItems {
+			problems = append(problems, fmt.Sprintf("%s: has %d items, fewer than the minimum of %v", location, len(typed), minItems))
+		}
+		if items, exists := schema["items"].(map[string]any); exists {
+			for i, item := range typed {
+				problems = append(problems, v.validate(item, items, fmt.Sprintf("%s[%d]", location, i))...)
+			}
+		}
+	}
+	return problems
+}
+
+func (v schemaValidator) validateObject(object map[string]any, schema map[string]any, location string) []string {
+	var problems []string
+	if required, exists := schema["required"].([]any); exists {
+		for _, name := range required {
+			if _, present := object[name.(string)]; !present {
+				problems = append(problems, fmt.Sprintf("%s: missing the required %q", location, name))
+			}
+		}
+	}
+	properties, _ := schema["properties"].(map[string]any)
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		propertyLocation := location + "." + name
+		if propertySchema, exists := properties[name].(map[string]any); exists {
+			problems = append(problems, v.validate(object[name], propertySchema, propertyLocation)...)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				problems = append(problems, fmt.Sprintf("%s: isn't an allowed property", propertyLocation))
+			}
+		case map[string]any:
+			problems = append(problems, v.validate(object[name], additional, propertyLocation)...)
+		}
+	}
+	return problems
+}
+
+// validateOneOf passes if exactly one of the options matches. If none match, we report the problems with the option
+// that came closest, which is usually the one the report was meant to be. An option of the right type is always
+// closer than an option of the wrong type.
+func (v schemaValidator) validateOneOf(value any, options []any, location string) []string {
+	var closest []string
+	closestTypeMatches := false
+	matches := 0
+	for _, option := range options {
+		optionSchema := option.(map[string]any)
+		problems := v.validate(value, optionSchema, location)
+		if len(problems) == 0 {
+			matches++
+			continue
+		}
+		expectedType, hasType := optionSchema["type"].(string)
+		typeMatches := !hasType || hasJSONType(value, expectedType)
+		if closest == nil || (typeMatches && !closestTypeMatches) || (typeMatches == closestTypeMatches && len(problems) < len(closest)) {
+			closest = problems
+			closestTypeMatches = typeMatches
+		}
+	}
+	switch matches {
+	case 1:
+		return nil
+	case 0:
+		return closest
+	default:
+		return []string{fmt.Sprintf("%s: matches more than one of the allowed forms", location)}
+	}
+}
+
+func (v schemaValidator) resolve(ref string) (map[string]any, error) {
+	name, isLocal := strings.CutPrefix(ref, "#/$defs/")
+	if !isLocal {
+		return nil, fmt.Errorf("can't resolve %s, only references to $defs are supported", ref)
+	}
+	definitions, _ := v.root["$defs"].(map[string]any)
+	definition, exists := definitions[name].(map[string]any)
+	if !exists {
+		return nil, fmt.Errorf("there's no definition for %s", ref)
+	}
+	return definition, nil
+}
+
+func hasJSONType(value any, expectedType string) bool {
+	switch expectedType {
+	case "integer":
+		number, isNumber := value.(float64)
+		return isNumber && number == math.Trunc(number)
+	default:
+		return jsonTypeName(value) == expectedType
+	}
+}
+
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func containsJSONValue(values []any, value any) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
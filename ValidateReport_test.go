@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testMetadata() *RunMetadata {
+	start := time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)
+	metadata := &RunMetadata{
+		ToolVersion:       "(devel)",
+		Model:             MODEL,
+		ModelDigest:       "sha256:1234",
+		PromptFingerprint: PromptFingerprint(),
+		ProjectName:       "proj",
+		Source:            SourceDocs,
+		InputDirectory:    "source",
+		SourceCommit:      &SourceCommit{Repository: ".", Ref: "main", SHA: "abc"},
+		StartTime:         start,
+	}
+	metadata.Finish(start.Add(time.Minute))
+	return metadata
+}
+
+func TestValidateReportAcceptsCurrentReports(t *testing.T) {
+	// Every field in the Go types has to be in the schemas, so set them all
+	snippets := []SnippetInfo{{
+		Page: "proj/a.txt", Category: UsageExample, Language: GO, DeclaredLanguage: GO, DetectedLanguage: GO,
+		LLMCategorized: true, Truncated: true, ContextStrategy: StrategyHeadTail, StartLine: 1, EndLine: 4,
+		Caption: "Example", Directive: DirectiveCodeBlock, Hash: "abc", Labels: []string{UsageExample, SyntaxExample},
+	}}
+	snippetData, _ := json.Marshal(SnippetReport{ReportHeader: NewReportHeader(testMetadata()), Snippets: snippets})
+	problems, err := ValidateReport(snippetData, SnippetsSchema)
+	if err != nil || len(problems) > 0 {
+		t.Errorf("got %v %v want a valid snippet report", problems, err)
+	}
+
+	repoReport := RepoReport{
+		ReportHeader:           NewReportHeader(testMetadata()),
+		TotalCodeBlocks:        1,
+		CategorizationDetails:  CategorizationDetails{LLMCategorizedCount: 1, AccuracyEstimate: 65},
+		CategoryLanguageCounts: map[string]map[string]int{UsageExample: {GO: 1, "totals": 1}},
+		SourceCommit:           &SourceCommit{Repository: ".", Ref: "main", SHA: "abc"},
+	}
+	countsData, _ := json.Marshal(repoReport)
+	problems, err = ValidateReport(countsData, CategoryCountsSchema)
+	if err != nil || len(problems) > 0 {
+		t.Errorf("got %v %v want a valid category counts report", problems, err)
+	}
+	if version := ReportSchemaVersionOf(countsData); version != ReportSchemaVersion {
+		t.Errorf("got %d want %d", version, ReportSchemaVersion)
+	}
+}
+
+func TestValidateReportAcceptsLegacyReports(t *testing.T) {
+	legacySnippets := `[{"page": "proj/a.go", "category": "Task-based usage", "language": "go", "llm_categorized": false}]`
+	problems, err := ValidateReport([]byte(legacySnippets), SnippetsSchema)
+	if err != nil || len(problems) > 0 {
+		t.Errorf("got %v %v want a valid legacy snippet report", problems, err)
+	}
+	if version := ReportSchemaVersionOf([]byte(legacySnippets)); version != LegacySchemaVersion {
+		t.Errorf("got %d want %d", version, LegacySchemaVersion)
+	}
+	legacyCounts := `{"total_code_blocks": 1, "categorization_details": {"llm_categorized_count": 0, "string_matched_count": 1, "accuracy_estimate": 100}, "category_language_counts": {"Task-based usage": {"go": 1, "totals": 1}}}`
+	problems, err = ValidateReport([]byte(legacyCounts), CategoryCountsSchema)
+	if err != nil || len(problems) > 0 {
+		t.Errorf("got %v %v want a valid legacy category counts report", problems, err)
+	}
+}
+
+func TestValidateReportFindsProblems(t *testing.T) {
+	report := `{"schema_version": 2, "snippets": [{"page": "proj/a.go", "language": "go", "labels": ["Task-based usage"], "llm_categorized": "no", "start_line": 0}]}`
+	problems, err := ValidateReport([]byte(report), SnippetsSchema)
+	if err != nil {
+		t.Fatalf("failed to validate %v", err)
+	}
+	expected := []string{
+		`$.snippets[0]: missing the required "category"`,
+		`$.snippets[0].labels: has 1 items, fewer than the minimum of 2`,
+		`$.snippets[0].llm_categorized: expected boolean, got string`,
+		`$.snippets[0].start_line: 0 is less than the minimum of 1`,
+	}
+	if strings.Join(problems, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got %q want %q", problems, expected)
+	}
+}
+
+func TestReadSnippetReportReadsBothVersions(t *testing.T) {
+	root := writeTestTree(t, map[string]string{
+		"legacy.json":  `[{"page": "proj/a.go", "category": "Task-based usage", "language": "go", "llm_categorized": false}]`,
+		"current.json": `{"schema_version": 2, "snippets": [{"page": "proj/a.go", "category": "Task-based usage", "language": "go", "llm_categorized": false}]}`,
+	})
+	for _, name := range []string{"legacy.json", "current.json"} {
+		snippets, err := ReadSnippetReport(root + "/" + name)
+		if err != nil || len(snippets) != 1 || snippets[0].Page != "proj/a.go" {
+			t.Errorf("got %v %v want the snippet from %s", snippets, err, name)
+		}
+	}
+}
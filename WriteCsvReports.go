@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// csvReportWriter writes reports that open straight in a spreadsheet
+type csvReportWriter struct{}
+
+func (w csvReportWriter) Write(data ReportData, runDir string) error {
+	if err := w.writeSnippets(data.Snippets, runDir); err != nil {
+		return err
+	}
+	return w.writeCategoryCounts(data.RepoReport, runDir)
+}
+
+func (csvReportWriter) writeSnippets(snippets []SnippetInfo, runDir string) error {
+	fmt.Println("Writing CSV snippet report")
+	rows := [][]string{{"page", "category", "language", "declared_language", "detected_language", "llm_categorized", "truncated", "start_line", "end_line", "caption", "directive", "subcategory", "labels"}}
+	for _, snippet := range snippets {
+		rows = append(rows, []string{
+			snippet.Page,
+			snippet.Category,
+			snippet.Language,
+			snippet.DeclaredLanguage,
+			snippet.DetectedLanguage,
+			strconv.FormatBool(snippet.LLMCategorized),
+			strconv.FormatBool(snippet.Truncated),
+			formatLine(snippet.StartLine),
+			formatLine(snippet.EndLine),
+			snippet.Caption,
+			snippet.Directive,
+			snippet.Subcategory,
+			strings.Join(snippet.Labels, ", "),
+		})
+	}
+	return writeCsvFile(filepath.Join(runDir, "snippets.csv"), rows)
+}
+
+// writeCategoryCounts writes the category × language pivot, with a total and a percentage for each row and column,
+// and the subcategory, hierarchy and secondary label columns for the categories that have them
+func (csvReportWriter) writeCategoryCounts(repoReport RepoReport, runDir string) error {
+	fmt.Println("Writing CSV category and language counts report")
+	pivot := NewCategoryLanguagePivot(repoReport.TypedCounts())
+	detailHeader := pivot.DetailHeader(func(subcategory string) string { return "subcategory_" + subcategory }, "parent", "total_with_children", "secondary_labels")
+	header := append(append(append([]string{"category"}, pivot.LanguageNames...), "total", "percentage"), detailHeader...)
+	rows := [][]string{header}
+	for _, category := range pivot.CategoryNames {
+		categoryTotal := pivot.Categories[category]
+		row := []string{category}
+		for _, language := range pivot.LanguageNames {
+			row = append(row, strconv.Itoa(categoryTotal.Languages[language]))
+		}
+		row = append(row, strconv.Itoa(categoryTotal.Total), formatPercentage(categoryTotal.Percentage))
+		rows = append(rows, append(row, pivot.DetailCells(category)...))
+	}
+	totals := []string{"total"}
+	percentages := []string{"percentage"}
+	for _, language := range pivot.LanguageNames {
+		totals = append(totals, strconv.Itoa(pivot.Languages[language].Total))
+		percentages = append(percentages, formatPercentage(pivot.Languages[language].Percentage))
+	}
+	blankDetails := make([]string, len(detailHeader))
+	totals = append(append(totals, strconv.Itoa(pivot.Total), ""), blankDetails...)
+	percentages = append(append(percentages, "", ""), blankDetails...)
+	rows = append(rows, totals, percentages)
+	return writeCsvFile(filepath.Join(runDir, "category_language_counts.csv"), rows)
+}
+
+func writeCsvFile(filePath string, rows [][]string) error {
+	var contents bytes.Buffer
+	writer := csv.NewWriter(&contents)
+	if err := writer.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to format %s: %v", filePath, err)
+	}
+	if err := WriteFileAtomically(filePath, contents.Bytes()); err != nil {
+		return err
+	}
+	fmt.Println("CSV report successfully written to", filePath)
+	return nil
+}
+
+func formatPercentage(percentage float64) string {
+	return strconv.FormatFloat(percentage, 'f', 2, 64)
+}
+
+// formatLine leaves the line empty for snippets that don't come from a docs page
+func formatLine(line int) string {
+	if line == 0 {
+		return ""
+	}
+	return strconv.Itoa(line)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"time"
+)
+
+//go:embed templates/dashboard.html
+var dashboardTemplate string
+
+// htmlReportWriter writes a dashboard.html file with the charts, tables and snippet search built in, and the report
+// data embedded in the page, so we can share it as a single file that works offline
+type htmlReportWriter struct{}
+
+// dashboardData is the data the dashboard's script reads from the page
+type dashboardData struct {
+	Snippets []SnippetInfo  `json:"snippets"`
+	Counts   CategoryCounts `json:"counts"`
+	Rollups  RollupReport   `json:"rollups"`
+}
+
+func (htmlReportWriter) Write(data ReportData, runDir string) error {
+	fmt.Println("Writing HTML dashboard")
+	page, err := RenderDashboard(data, data.ReportName, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to render the HTML dashboard: %v", err)
+	}
+	filePath := filepath.Join(runDir, "dashboard.html")
+	if err := WriteFileAtomically(filePath, page); err != nil {
+		return err
+	}
+	fmt.Println("HTML dashboard successfully written to", filePath)
+	return nil
+}
+
+// RenderDashboard fills in the dashboard template. json.Marshal escapes <, > and &, so a snippet caption can't close
+// the script element we embed the data in.
+func RenderDashboard(data ReportData, projectName string, generatedAt time.Time) ([]byte, error) {
+	tmpl, err := template.New("dashboard").Parse(dashboardTemplate)
+	if err != nil {
+		return nil, err
+	}
+	snippets := data.Snippets
+	if snippets == nil {
+		snippets = []SnippetInfo{}
+	}
+	pageData, err := json.Marshal(dashboardData{
+		Snippets: snippets,
+		Counts:   data.RepoReport.TypedCounts(),
+		Rollups:  data.Rollups,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var page bytes.Buffer
+	err = tmpl.Execute(&page, map[string]any{
+		"ProjectName": projectName,
+		"GeneratedAt": generatedAt.Format("2006-01-02 15:04 MST"),
+		"RepoReport":  data.RepoReport,
+		"Data":        template.JS(pageData),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page.Bytes(), nil
+}
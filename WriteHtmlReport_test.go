@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderDashboard(t *testing.T) {
+	snippets := []SnippetInfo{
+		{Page: "proj/index.txt", Category: UsageExample, Language: PYTHON, Caption: "</script><script>alert(1)</script>"},
+	}
+	data := ReportData{
+		Snippets:   snippets,
+		RepoReport: RepoReport{TotalCodeBlocks: 1, CategoryLanguageCounts: map[string]map[string]int{UsageExample: {PYTHON: 1}}},
+		Rollups:    BuildRollups(snippets, "", SourceDocs),
+	}
+	page, err := RenderDashboard(data, "proj", time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("failed to render the dashboard %v", err)
+	}
+	got := string(page)
+	for _, expected := range []string{"Code example categories in proj", "2024-01-02 03:04 UTC", `"page":"proj/index.txt"`} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("expected the dashboard to contain %q", expected)
+		}
+	}
+	if strings.Contains(got, "<script>alert(1)") {
+		t.Errorf("expected the caption to be escaped inside the data script")
+	}
+}
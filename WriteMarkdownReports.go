@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// markdownReportWriter writes reports as Markdown tables, which we can paste into wiki pages
+type markdownReportWriter struct{}
+
+func (w markdownReportWriter) Write(data ReportData, runDir string) error {
+	if err := w.writeSnippets(data.Snippets, data.ReportName, runDir); err != nil {
+		return err
+	}
+	return w.writeCategoryCounts(data.RepoReport, data.ReportName, runDir)
+}
+
+func (markdownReportWriter) writeSnippets(snippets []SnippetInfo, projectName string, runDir string) error {
+	fmt.Println("Writing Markdown snippet report")
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# Snippets in %s\n\n", projectName)
+	rows := [][]string{}
+	for _, snippet := range snippets {
+		lines := ""
+		if snippet.StartLine != 0 {
+			lines = fmt.Sprintf("%d-%d", snippet.StartLine, snippet.EndLine)
+		}
+		rows = append(rows, []string{snippet.Page, lines, snippet.Category, snippet.Subcategory, strings.Join(snippet.Labels, ", "), snippet.Language, yesOrNo(snippet.LLMCategorized), yesOrNo(snippet.Truncated)})
+	}
+	builder.WriteString(MarkdownTable([]string{"Page", "Lines", "Category", "Subcategory", "Labels", "Language", "LLM categorized", "Truncated"}, rows))
+	return writeMarkdownFile(filepath.Join(runDir, "snippets.md"), builder.String())
+}
+
+func (markdownReportWriter) writeCategoryCounts(repoReport RepoReport, projectName string, runDir string) error {
+	fmt.Println("Writing Markdown category and language counts report")
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# Code example categories in %s\n\n", projectName)
+	if repoReport.SourceCommit != nil {
+		fmt.Fprintf(&builder, "Categorized from `%s` at commit `%s`.\n\n", repoReport.SourceCommit.Ref, repoReport.SourceCommit.SHA)
+	}
+	if metadata := repoReport.Metadata; metadata != nil {
+		fmt.Fprintf(&builder, "Categorized with `%s` on %s, in %.0f seconds.\n\n", metadata.Model, metadata.EndTime.Format("2006-01-02"), metadata.DurationSeconds)
+	}
+	details := repoReport.CategorizationDetails
+	builder.WriteString(MarkdownTable([]string{"Total code blocks", "String matched", "LLM categorized", "Manually overridden", "Accuracy estimate"}, [][]string{{
+		strconv.Itoa(repoReport.TotalCodeBlocks),
+		strconv.Itoa(details.StringMatchedCount),
+		strconv.Itoa(details.LLMCategorizedCount),
+		strconv.Itoa(details.OverriddenCount),
+		fmt.Sprintf("%.2f%%", details.AccuracyEstimate),
+	}}))
+	builder.WriteString("\n## Categories by language\n\n")
+	pivot := NewCategoryLanguagePivot(repoReport.TypedCounts())
+	detailHeader := pivot.DetailHeader(func(subcategory string) string { return "Subcategory " + subcategory }, "Parent", "Total with children", "Secondary labels")
+	var rows [][]string
+	for _, category := range pivot.CategoryNames {
+		categoryTotal := pivot.Categories[category]
+		row := []string{category}
+		for _, language := range pivot.LanguageNames {
+			row = append(row, strconv.Itoa(categoryTotal.Languages[language]))
+		}
+		row = append(row, strconv.Itoa(categoryTotal.Total), fmt.Sprintf("%.2f%%", categoryTotal.Percentage))
+		rows = append(rows, append(row, pivot.DetailCells(category)...))
+	}
+	totals := []string{"**Total**"}
+	shares := []string{"**Share**"}
+	for _, language := range pivot.LanguageNames {
+		totals = append(totals, strconv.Itoa(pivot.Languages[language].Total))
+		shares = append(shares, fmt.Sprintf("%.2f%%", pivot.Languages[language].Percentage))
+	}
+	blankDetails := make([]string, len(detailHeader))
+	totals = append(append(totals, strconv.Itoa(pivot.Total), ""), blankDetails...)
+	shares = append(append(shares, "", ""), blankDetails...)
+	rows = append(rows, totals, shares)
+	header := append(append(append([]string{"Category"}, pivot.LanguageNames...), "Total", "Share"), detailHeader...)
+	builder.WriteString(MarkdownTable(header, rows))
+	return writeMarkdownFile(filepath.Join(runDir, "category_language_counts.md"), builder.String())
+}
+
+// MarkdownTable formats a table, escaping the characters that would break a table cell
+func MarkdownTable(header []string, rows [][]string) string {
+	var builder strings.Builder
+	writeRow := func(cells []string) {
+		builder.WriteString("|")
+		for _, cell := range cells {
+			cell = strings.ReplaceAll(cell, "|", "\\|")
+			cell = strings.ReplaceAll(cell, "\n", " ")
+			builder.WriteString(" " + cell + " |")
+		}
+		builder.WriteString("\n")
+	}
+	writeRow(header)
+	separator := make([]string, len(header))
+	for i := range separator {
+		separator[i] = "---"
+	}
+	writeRow(separator)
+	for _, row := range rows {
+		writeRow(row)
+	}
+	return builder.String()
+}
+
+func yesOrNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+func writeMarkdownFile(filePath string, contents string) error {
+	if err := WriteFileAtomically(filePath, []byte(contents)); err != nil {
+		return err
+	}
+	fmt.Println("Markdown report successfully written to", filePath)
+	return nil
+}
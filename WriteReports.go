@@ -3,32 +3,67 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"os"
+	"path/filepath"
 )
 
-func WriteSnippetReport(snippets []SnippetInfo, projectName string) {
+func WriteSnippetReport(snippets []SnippetInfo, metadata *RunMetadata, runDir string) error {
 	fmt.Println("Writing snippet report")
-	snippetJsonData, marshallingErr := json.MarshalIndent(snippets, "", "  ")
-	if marshallingErr != nil {
-		fmt.Println("Error marshalling JSON:", marshallingErr)
-		return
+	if snippets == nil {
+		snippets = []SnippetInfo{}
 	}
-	reportOutputDir := BaseReportOutputDir + projectName
-	mkdirErr := os.MkdirAll(reportOutputDir, 0755)
-	if mkdirErr != nil {
-		fmt.Println("Error creating directory: ", mkdirErr)
-		return
+	report := SnippetReport{ReportHeader: NewReportHeader(metadata), Snippets: snippets}
+	snippetJsonData, marshallingErr := json.MarshalIndent(report, "", "  ")
+	if marshallingErr != nil {
+		return fmt.Errorf("failed to marshal the report: %v", marshallingErr)
 	}
-	snippetDetailsFilepath := BaseReportOutputDir + projectName + "/snippets.json"
-	writeReportErr := os.WriteFile(snippetDetailsFilepath, snippetJsonData, 0644)
+	snippetDetailsFilepath := filepath.Join(runDir, SnippetReportFile)
+	writeReportErr := WriteFileAtomically(snippetDetailsFilepath, snippetJsonData)
 	if writeReportErr != nil {
-		fmt.Println("Error writing JSON to file:", writeReportErr)
-		return
+		return writeReportErr
 	}
 	fmt.Println("Snippet report successfully written to", snippetDetailsFilepath)
+	return nil
+}
+
+// WriteLanguageMismatchReport lists snippets whose declared language disagrees with the language detected from their
+// contents, so docs writers can fix the language tag on the code block
+func WriteLanguageMismatchReport(mismatches []LanguageMismatch, metadata *RunMetadata, runDir string) error {
+	fmt.Println("Writing language mismatch report")
+	if mismatches == nil {
+		mismatches = []LanguageMismatch{}
+	}
+	report := LanguageMismatchReport{ReportHeader: NewReportHeader(metadata), Mismatches: mismatches}
+	mismatchJsonData, marshallingErr := json.MarshalIndent(report, "", "  ")
+	if marshallingErr != nil {
+		return fmt.Errorf("failed to marshal the report: %v", marshallingErr)
+	}
+	filePath := filepath.Join(runDir, "language_mismatches.json")
+	writeReportErr := WriteFileAtomically(filePath, mismatchJsonData)
+	if writeReportErr != nil {
+		return writeReportErr
+	}
+	fmt.Printf("Language mismatch report with %d snippets successfully written to %s\n", len(mismatches), filePath)
+	return nil
+}
+
+// WriteIngestionDiagnosticsReport lists the files we couldn't read, skipped, or categorized with a caveat, with the
+// reason for each
+func WriteIngestionDiagnosticsReport(diagnostics []IngestionDiagnostic, metadata *RunMetadata, runDir string) error {
+	fmt.Println("Writing ingestion diagnostics report")
+	diagnosticsJsonData, marshallingErr := json.MarshalIndent(NewIngestionReport(diagnostics, metadata), "", "  ")
+	if marshallingErr != nil {
+		return fmt.Errorf("failed to marshal the report: %v", marshallingErr)
+	}
+	filePath := filepath.Join(runDir, "ingestion_diagnostics.json")
+	writeReportErr := WriteFileAtomically(filePath, diagnosticsJsonData)
+	if writeReportErr != nil {
+		return writeReportErr
+	}
+	fmt.Printf("Ingestion diagnostics report with %d entries successfully written to %s\n", len(diagnostics), filePath)
+	return nil
 }
 
-func CalculateAccuracyPercentages(totalCodeCount int, llmCategorizedCount int, stringMatchedCount int, isDriversProject bool) float64 {
+func CalculateAccuracyPercentages(totalCodeCount int, llmCategorizedCount int, stringMatchedCount int, overriddenCount int, isDriversProject bool) float64 {
 	if totalCodeCount == 0 {
 		fmt.Println("Total code count is zero, cannot perform calculations.")
 		return 0
@@ -36,48 +71,93 @@ func CalculateAccuracyPercentages(totalCodeCount int, llmCategorizedCount int, s
 	// Calculate the percentage contribution of stringMatchedCount and llmCategorizedCount
 	stringMatchedPercentage := (float64(stringMatchedCount) / float64(totalCodeCount)) * 100
 	llmCategorizedPercentage := (float64(llmCategorizedCount) / float64(totalCodeCount)) * 100
+	overriddenPercentage := (float64(overriddenCount) / float64(totalCodeCount)) * 100
 	// Calculate accuracy estimate
-	stringMatchAccuracy := float64(stringMatchedCount) // 100% accuracy
-	var llmCategorizedAccuracy float64
-	if isDriversProject {
-		llmCategorizedAccuracy = float64(llmCategorizedCount) * 0.80 // 80% accuracy
-	} else {
-		llmCategorizedAccuracy = float64(llmCategorizedCount) * 0.65 // 65% accuracy
-	}
+	stringMatchAccuracy := float64(stringMatchedCount) * StringMatchConfidence
+	llmCategorizedAccuracy := float64(llmCategorizedCount) * LLMConfidence(isDriversProject)
+	overriddenAccuracy := float64(overriddenCount) * OverrideConfidence
 	// Combined accuracy calculation
-	totalAccuracyEstimate := (stringMatchAccuracy + llmCategorizedAccuracy) / float64(totalCodeCount) * 100
+	totalAccuracyEstimate := (stringMatchAccuracy + llmCategorizedAccuracy + overriddenAccuracy) / float64(totalCodeCount) * 100
 	// Print the results
 	fmt.Printf("String Matched Percentage: %.2f%%\n", stringMatchedPercentage)
 	fmt.Printf("LLM Categorized Percentage: %.2f%%\n", llmCategorizedPercentage)
+	fmt.Printf("Manually Overridden Percentage: %.2f%%\n", overriddenPercentage)
 	fmt.Printf("Overall Accuracy Estimate: %.2f%%\n", totalAccuracyEstimate)
 	return totalAccuracyEstimate
 }
 
-func WriteCategoryCountsReport(totalCodeBlocks int, counts map[string]map[string]int, llmCategorised int, stringMatched int, projectName string, isDriversProject bool) {
-	categorySums := GetCategorySums(counts)
-	accuracyEstimate := CalculateAccuracyPercentages(totalCodeBlocks, llmCategorised, stringMatched, isDriversProject)
+// BuildRepoReport totals the category and language counts, and estimates the accuracy of the run
+func BuildRepoReport(result RunResult, isDriversProject bool) RepoReport {
+	accuracyEstimate := CalculateAccuracyPercentages(result.SnippetCount, result.LLMCategorizedCount, result.StringMatchedCount, result.OverriddenCount, isDriversProject)
+	typedCounts := NewCategoryCounts(result.Counts)
+	typedCounts.AddCategories(GetTaxonomy().CategoryNames())
+	typedCounts.AddSubcategories(result.SubcategoryCounts)
+	typedCounts.AddSecondaryLabels(result.SecondaryLabelCounts)
+	typedCounts.AddHierarchy(GetTaxonomy())
 	catDetails := CategorizationDetails{
-		LLMCategorizedCount: llmCategorised,
-		StringMatchedCount:  stringMatched,
+		LLMCategorizedCount: result.LLMCategorizedCount,
+		StringMatchedCount:  result.StringMatchedCount,
+		OverriddenCount:     result.OverriddenCount,
 		AccuracyEstimate:    accuracyEstimate,
 	}
-	repoReport := RepoReport{
-		TotalCodeBlocks:        totalCodeBlocks,
+	return RepoReport{
+		TotalCodeBlocks:        result.SnippetCount,
 		CategorizationDetails:  catDetails,
-		CategoryLanguageCounts: categorySums,
+		CategoryLanguageCounts: GetCategorySums(result.Counts),
+		Counts:                 &typedCounts,
+		SourceCommit:           result.SourceCommit,
 	}
+}
+
+func WriteCategoryCountsReport(repoReport RepoReport, runDir string) error {
 	repoData, jsonMarshallingErr := json.MarshalIndent(repoReport, "", "  ")
 
 	if jsonMarshallingErr != nil {
-		fmt.Println("Error marshalling JSON:", jsonMarshallingErr)
-		return
+		return fmt.Errorf("failed to marshal the report: %v", jsonMarshallingErr)
 	}
 	fmt.Println("Writing category and language counts report")
-	filePath := BaseReportOutputDir + projectName + "/language_category_counts.json"
-	writeReportErr := os.WriteFile(filePath, repoData, 0644)
+	filePath := filepath.Join(runDir, "language_category_counts.json")
+	writeReportErr := WriteFileAtomically(filePath, repoData)
 	if writeReportErr != nil {
-		fmt.Println("Error writing JSON to file: ", writeReportErr)
-		return
+		return writeReportErr
 	}
 	fmt.Println("Category and language counts report successfully written to", filePath)
+	return nil
+}
+
+// WriteSnippetDeltaReport writes the snippets that were added, recategorized or removed between two commits, which is
+// what a docs pull request reviewer wants to see
+func WriteSnippetDeltaReport(delta SnippetDelta, runDir string) error {
+	fmt.Println("Writing snippet delta report")
+	deltaJsonData, marshallingErr := json.MarshalIndent(delta, "", "  ")
+	if marshallingErr != nil {
+		return fmt.Errorf("failed to marshal the report: %v", marshallingErr)
+	}
+	filePath := filepath.Join(runDir, "snippet_delta.json")
+	writeReportErr := WriteFileAtomically(filePath, deltaJsonData)
+	if writeReportErr != nil {
+		return writeReportErr
+	}
+	newSnippetCount := 0
+	for _, snippets := range delta.NewSnippets {
+		newSnippetCount += len(snippets)
+	}
+	fmt.Printf("Snippet delta report with %d new, %d recategorized and %d removed snippets successfully written to %s\n", newSnippetCount, len(delta.Recategorized), len(delta.Removed), filePath)
+	return nil
+}
+
+// WriteRollupReport writes the category and language counts for each page and docs section
+func WriteRollupReport(rollups RollupReport, runDir string) error {
+	fmt.Println("Writing rollup report")
+	rollupJsonData, marshallingErr := json.MarshalIndent(rollups, "", "  ")
+	if marshallingErr != nil {
+		return fmt.Errorf("failed to marshal the report: %v", marshallingErr)
+	}
+	filePath := filepath.Join(runDir, "rollups.json")
+	writeReportErr := WriteFileAtomically(filePath, rollupJsonData)
+	if writeReportErr != nil {
+		return writeReportErr
+	}
+	fmt.Printf("Rollup report with %d pages and %d sections successfully written to %s\n", len(rollups.Pages), len(rollups.Sections), filePath)
+	return nil
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const (
+	SnippetReportFile       = "snippets.json"
+	SnippetStreamReportFile = "snippets.ndjson"
+)
+
+// SnippetStreamWriter writes each snippet to snippets.ndjson as one line of JSON as soon as we categorize it, so a run
+// over a very large project doesn't have to hold every snippet in memory. Like the other reports, the file is in the
+// run directory, which we only promote to latest once the run finishes, so readers of latest never see a partial file.
+type SnippetStreamWriter struct {
+	FilePath string
+	file     *os.File
+	encoder  *json.Encoder
+	count    int
+}
+
+func NewSnippetStreamWriter(filePath string) (*SnippetStreamWriter, error) {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &SnippetStreamWriter{FilePath: filePath, file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// Write appends the snippet to the file. The encoder writes each line in a single write, so if the run dies, every
+// line in the file is a complete snippet.
+func (w *SnippetStreamWriter) Write(snippet SnippetInfo) error {
+	if err := w.encoder.Encode(snippet); err != nil {
+		return err
+	}
+	w.count++
+	return nil
+}
+
+func (w *SnippetStreamWriter) Close() error {
+	if err := w.file.Sync(); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Snippet stream with %d snippets successfully written to %s\n", w.count, w.FilePath)
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSnippetStreamRoundTrip(t *testing.T) {
+	runDir := t.TempDir()
+	stream, err := NewSnippetStreamWriter(filepath.Join(runDir, SnippetStreamReportFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := NewRunResult("", SourceDocs, stream)
+	snippets := []SnippetInfo{
+		{Page: "proj/a.txt", Category: UsageExample, Language: PYTHON, Hash: "one"},
+		{Page: "proj/b.txt", Category: SyntaxExample, Language: SHELL, LLMCategorized: true, Hash: "two"},
+	}
+	for _, snippet := range snippets {
+		result.AddSnippet(snippet)
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if result.Snippets != nil {
+		t.Errorf("got %d snippets in memory want none, the stream should have them", len(result.Snippets))
+	}
+	if result.SnippetCount != 2 || result.Counts[SyntaxExample][SHELL] != 1 || len(result.Rollups.Report().Pages) != 2 {
+		t.Errorf("got count %d, counts %v and rollups %v, the stream should still tally each snippet", result.SnippetCount, result.Counts, result.Rollups.Report())
+	}
+
+	contents, err := os.ReadFile(filepath.Join(runDir, SnippetStreamReportFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(contents), "\n"); lines != 2 {
+		t.Errorf("got %d lines want 2", lines)
+	}
+	got, err := ReadSnippetReport(SnippetReportPath(runDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, snippets) {
+		t.Errorf("got %v want %v", got, snippets)
+	}
+}
+
+func TestReadSnippetsFromEachForm(t *testing.T) {
+	dir := t.TempDir()
+	reports := map[string]string{
+		"legacy.json":    `[{"page": "proj/a.txt", "category": "Syntax example"}, {"page": "proj/b.txt", "category": "Syntax example"}]`,
+		"versioned.json": `{"schema_version": 2, "snippets": [{"page": "proj/a.txt", "category": "Syntax example"}, {"page": "proj/b.txt", "category": "Syntax example"}]}`,
+		"stream.ndjson":  "{\"page\": \"proj/a.txt\", \"category\": \"Syntax example\"}\n{\"page\": \"proj/b.txt\", \"category\": \"Syntax example\"}\n",
+	}
+	for name, contents := range reports {
+		filePath := filepath.Join(dir, name)
+		if err := os.WriteFile(filePath, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		err := ReadSnippets(filePath, func(snippet SnippetInfo) error {
+			got = append(got, snippet.Page)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		expected := []string{"proj/a.txt", "proj/b.txt"}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: got %q want %q", name, got, expected)
+		}
+	}
+}
+
+func TestValidateSnippetStream(t *testing.T) {
+	data := "{\"page\": \"proj/a.txt\", \"category\": \"Syntax example\", \"language\": \"shell\", \"llm_categorized\": false, \"truncated\": false}\n{\"page\": \"proj/b.txt\", \"language\": \"shell\", \"llm_categorized\": false, \"truncated\": false}\n"
+	got, err := ValidateSnippetStream([]byte(data), SnippetsSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{`line 2: missing the required "category"`}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q want %q", got, expected)
+	}
+}
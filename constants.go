@@ -4,12 +4,86 @@ const (
 	MODEL = "qwen2.5-coder"
 	// SnippetsStartDirectory To traverse a different directory on your file system, change the path here
 	//SnippetsStartDirectory = "../go-test-code-example-categorization/examples/"
-	SnippetsStartDirectory     = "/Users/dachary.carey/workspace/code-example-reports/code-blocks/"
-	ProjectName                = "mongocli"
-	BaseReportOutputDir        = "../go-test-code-example-categorization/output/"
+	SnippetsStartDirectory = "/Users/dachary.carey/workspace/code-example-reports/code-blocks/"
+	ProjectName            = "mongocli"
+	BaseReportOutputDir    = "../go-test-code-example-categorization/output/"
+	// RunDirectoryTimeFormat Each run writes its reports to a directory named for its UTC start time in this format
+	RunDirectoryTimeFormat = "20060102T150405Z"
+	// RunsToKeep We remove the oldest run directories for a project past this many. Pass -keep-runs to override it.
+	RunsToKeep = 10
+	// LanguageRegistryFile To recognize more file extensions or change a language's category, add a registry file here
+	LanguageRegistryFile = "languages.json"
+	// TaxonomyFile To add, remove or redefine categories, add a taxonomy file here
+	TaxonomyFile = "taxonomy.json"
+	// UnversionedTaxonomy Reports from before we recorded the taxonomy version used this version of the taxonomy
+	UnversionedTaxonomy = 1
+	// IgnoreFileName Add a file with this name to any directory to skip the paths that match its patterns
+	IgnoreFileName     = ".categorizeignore"
+	IncludeHiddenPaths = false
+	FollowSymlinks     = false
+	// MaxSnippetFileSize We skip files larger than this many bytes, which are almost never code examples
+	MaxSnippetFileSize = 1024 * 1024
+	// ModelContextTokens The context size we ask ollama for. Snippets that don't fit in the prompt get the OversizedSnippetStrategy.
+	ModelContextTokens = 8192
+	// ResponseTokenReserve We leave this many tokens of the context for the model's answer
+	ResponseTokenReserve = 64
+	// TokenizerEncoding The tiktoken encoding we count tokens with
+	TokenizerEncoding = "cl100k_base"
+	// OversizedSnippetStrategy One of StrategyHeadTail, StrategyStructuralSummary or StrategyChunkAndVote
+	OversizedSnippetStrategy = StrategyHeadTail
+	// MaxSnippetChunks With StrategyChunkAndVote, we ask the LLM about at most this many chunks of a snippet
+	MaxSnippetChunks = 8
+	// StringMatchConfidence How often a string match picks the right category
+	StringMatchConfidence = 1.0
+	// OverrideConfidence How often a category someone corrected by hand with an overrides file is right
+	OverrideConfidence = 1.0
+	// DriverLLMConfidence How often we estimate the LLM picks the right category in driver projects
+	DriverLLMConfidence = 0.80
+	// OtherLLMConfidence How often we estimate the LLM picks the right category in other projects
+	OtherLLMConfidence = 0.65
+	// DefaultServeAddress The address `go run . serve` listens on. It's only on localhost unless you pass -addr.
+	DefaultServeAddress = "127.0.0.1:8088"
+	// MaxBatchSnippets The most snippets the API categorizes in one batch request
+	MaxBatchSnippets = 100
+	// MaxServeRequestBytes The API rejects request bodies larger than this many bytes
+	MaxServeRequestBytes = 16 * 1024 * 1024
+	// ServeShutdownSeconds When the API server stops, it waits this long for the requests it cancelled to finish
+	ServeShutdownSeconds = 10
+	// JobsDirectory `go run . jobs` keeps the queued, running and finished jobs, and their logs, in this directory
+	JobsDirectory = "../go-test-code-example-categorization/jobs/"
+	// JobWorkers How many jobs `go run . jobs work` runs at once. Each run has its own ollama requests, so keep it low.
+	JobWorkers = 1
+	// JobPollSeconds How often an idle worker checks for newly queued jobs
+	JobPollSeconds = 5
+	// JobProgressSeconds A running job records its progress at most this often, and when it finishes
+	JobProgressSeconds = 10
+	// JobHeartbeatSeconds How often a worker records that it's still running its job
+	JobHeartbeatSeconds = 30
+	// JobHeartbeatTimeoutSeconds Another worker requeues a running job once its worker hasn't recorded a heartbeat for this long
+	JobHeartbeatTimeoutSeconds = 120
+	// DefaultDocsBaseURL We link report pages to the published docs at this URL, unless the project is in DocsBaseURLs
+	DefaultDocsBaseURL         = "https://www.mongodb.com/docs/%s/current/"
 	SyntaxExample              = "Syntax example"
 	NonMongoCommand            = "Non-MongoDB command"
 	ExampleReturnObject        = "Example return object"
 	ExampleConfigurationObject = "Example configuration object"
 	UsageExample               = "Task-based usage"
+	AtlasCLICommand            = "Atlas CLI command"
+	MongoshCommand             = "mongosh command"
+)
+
+var (
+	// IncludePatterns To only categorize some files, add glob patterns here, such as "**/*.go"
+	IncludePatterns = []string{}
+	// ExcludePatterns To skip files or directories, add glob patterns here
+	ExcludePatterns = []string{".DS_Store"}
+	// DocsBaseURLs To link a project's pages to its published docs, add the project's docs URL here
+	DocsBaseURLs = map[string]string{
+		"atlas-cli":   "https://www.mongodb.com/docs/atlas/cli/current/",
+		"docs":        "https://www.mongodb.com/docs/manual/",
+		"mongocli":    "https://www.mongodb.com/docs/mongocli/current/",
+		"node":        "https://www.mongodb.com/docs/drivers/node/current/",
+		"php-library": "https://www.mongodb.com/docs/php-library/current/",
+		"pymongo":     "https://www.mongodb.com/docs/languages/python/pymongo-driver/current/",
+	}
 )
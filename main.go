@@ -1,14 +1,9 @@
 package main
 
 import (
-	"context"
-	"fmt"
-	"github.com/tmc/langchaingo/llms/ollama"
+	"flag"
 	"log"
 	"os"
-	"path/filepath"
-	"strings"
-	"time"
 )
 
 func containsString(slice []string, value string) bool {
@@ -21,78 +16,44 @@ func containsString(slice []string, value string) bool {
 }
 
 func main() {
-	isDriverProject := false
-	driversProjects := []string{"c", "cpp-driver", "csharp", "java", "java-rs", "kotlin", "kotlin-sync", "laravel", "node", "php-library", "pymongo", "pymongo-arrow", "ruby-driver", "rust", "scala"}
-	for _, driver := range driversProjects {
-		if ProjectName == driver {
-			isDriverProject = true
+	// Commands other than categorizing a project come first on the command line, like `go run . compare`
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "compare":
+			RunCompareCommand(os.Args[2:])
+			return
+		case "validate":
+			RunValidateCommand(os.Args[2:])
+			return
+		case "aggregate":
+			RunAggregateCommand(os.Args[2:])
+			return
+		case "serve":
+			RunServeCommand(os.Args[2:])
+			return
+		case "categorize":
+			RunCategorizeCommand(os.Args[2:])
+			return
+		case "explain":
+			RunExplainCommand(os.Args[2:])
+			return
+		case "taxonomy":
+			RunTaxonomyCommand(os.Args[2:])
+			return
+		case "migrate":
+			RunMigrateCommand(os.Args[2:])
+			return
+		case "jobs":
+			RunJobsCommand(os.Args[2:])
+			return
 		}
 	}
-	startTime := time.Now()
-	files := GetFiles()
-	totalFileCount := len(files)
-	LogStartInfoToConsole(startTime, totalFileCount)
-	//hashes := make(map[string]bool)
-
-	var snippets []SnippetInfo
-	counts := make(map[string]map[string]int)
-	llmCategorizedCount := 0
-	stringMatchedCount := 0
-	filesProcessed := 0
-
-	// To change the model, use a different model's string name here
-	llm, err := ollama.New(ollama.WithModel(MODEL))
+	options, err := ParseRunOptions(flag.CommandLine, os.Args[1:])
 	if err != nil {
-		log.Fatalf("failed to connect to ollama: %v", err)
+		log.Fatalf("%v", err)
 	}
-	ctx := context.Background()
-
-	for _, file := range files {
-		contents, err := os.ReadFile(file)
-		if err != nil {
-			fmt.Printf("failed to read file: %v\n", err)
-			return
-		}
-		// Find the starting index of the project name to strip the earlier parts of the filepath
-		startIndex := strings.Index(file, ProjectName)
-		pagePath := file[startIndex:]
-		ext := filepath.Ext(file)
-		if !strings.Contains(file, ".DS_Store") {
-			lang := GetLangFromExtension(ext)
-
-			category, llmCategorized := ProcessSnippet(string(contents), lang, llm, ctx, isDriverProject)
-			//snippetHash := GetSnippetHash(string(contents))
-			//isDuplicate := CheckExampleIsDuplicate(hashes, snippetHash)
-			//if !isDuplicate {
-			//	hashes[snippetHash] = true
-			//}
-
-			details := SnippetInfo{
-				Page:           pagePath,
-				Category:       category,
-				Language:       lang,
-				LLMCategorized: llmCategorized,
-				//Duplicate: isDuplicate,
-			}
-			snippets = append(snippets, details)
-			if _, exists := counts[details.Category]; !exists {
-				counts[details.Category] = make(map[string]int)
-			}
-			// Increment the language count for the specific category
-			counts[details.Category][details.Language]++
-			if llmCategorized {
-				llmCategorizedCount++
-			} else if llmCategorized == false {
-				stringMatchedCount++
-			}
-		}
-		filesProcessed++
-		if filesProcessed%100 == 0 {
-			fmt.Println("Processed ", filesProcessed, " snippets")
-		}
+	if options.JobID != "" {
+		AttachRunToJob(NewJobStore(JobsDirectory), &options)
 	}
-
-	WriteSnippetReport(snippets, ProjectName)
-	WriteCategoryCountsReport(totalFileCount, counts, llmCategorizedCount, stringMatchedCount, ProjectName, isDriverProject)
-	LogFinishInfoToConsole(startTime, filesProcessed)
+	RunCategorization(options)
 }